@@ -33,7 +33,9 @@ const (
 // NOTE: for files and empty directories status code is "201 Created" and for
 // non-empty directories it is "202 Accepted" which means the operation has
 // been started, but hasn't been finished yet. The application MUST track
-// the status of operation by itself.
+// the status of operation by itself (see WaitOperation). Cached metadata of
+// affected paths is invalidated again once GetOperation or WaitOperation
+// reports that the operation is finished.
 //
 // See: https://tech.yandex.com/disk/api/reference/copy-docpage/
 func (c *Client) Copy(ctx context.Context, src, dst string, overwrite bool) (*Link, int, error) {
@@ -50,10 +52,15 @@ func (c *Client) Copy(ctx context.Context, src, dst string, overwrite bool) (*Li
 	}
 
 	statusCode, err := c.doRequestAndDecode(ctx, methodActionCopy, urlActionCopy, params, nil, &link)
+	c.invalidateCache(dst)
 	if err != nil {
 		return nil, statusCode, err
 	}
 
+	if statusCode == http.StatusAccepted {
+		link.affected = []string{dst}
+	}
+
 	return &link, statusCode, nil
 }
 
@@ -70,7 +77,9 @@ func (c *Client) Copy(ctx context.Context, src, dst string, overwrite bool) (*Li
 // NOTE: for files and empty directories status code is "201 Created" and for
 // non-empty directories it is "202 Accepted" which means the operation has
 // been started, but hasn't been finished yet. The application MUST track
-// the status of operation by itself.
+// the status of operation by itself (see WaitOperation). Cached metadata of
+// affected paths is invalidated again once GetOperation or WaitOperation
+// reports that the operation is finished.
 //
 // See: https://tech.yandex.com/disk/api/reference/move-docpage/
 func (c *Client) Move(ctx context.Context, src, dst string, overwrite bool) (*Link, int, error) {
@@ -87,10 +96,15 @@ func (c *Client) Move(ctx context.Context, src, dst string, overwrite bool) (*Li
 	}
 
 	statusCode, err := c.doRequestAndDecode(ctx, methodActionMove, urlActionMove, params, nil, &link)
+	c.invalidateCache(src, dst)
 	if err != nil {
 		return nil, statusCode, err
 	}
 
+	if statusCode == http.StatusAccepted {
+		link.affected = []string{src, dst}
+	}
+
 	return &link, statusCode, nil
 }
 
@@ -106,7 +120,9 @@ func (c *Client) Move(ctx context.Context, src, dst string, overwrite bool) (*Li
 // NOTE: for files and empty directories status code is "204 No content" and for
 // non-empty directories it is "202 Accepted" which means the operation has
 // been started, but hasn't been finished yet. The application MUST track
-// the status of operation by itself.
+// the status of operation by itself (see WaitOperation). Cached metadata of
+// affected paths is invalidated again once GetOperation or WaitOperation
+// reports that the operation is finished.
 //
 // See: https://tech.yandex.com/disk/api/reference/delete-docpage/
 func (c *Client) Delete(ctx context.Context, path string, permanently bool) (*Link, int, error) {
@@ -122,6 +138,7 @@ func (c *Client) Delete(ctx context.Context, path string, permanently bool) (*Li
 	}
 
 	statusCode, err := c.doRequestAndDecode(ctx, methodActionDelete, urlActionDelete, params, nil, &link)
+	c.invalidateCache(path)
 	if err != nil {
 		return nil, statusCode, err
 	}
@@ -130,6 +147,10 @@ func (c *Client) Delete(ctx context.Context, path string, permanently bool) (*Li
 		return nil, statusCode, nil
 	}
 
+	if statusCode == http.StatusAccepted {
+		link.affected = []string{path}
+	}
+
 	return &link, statusCode, nil
 }
 
@@ -150,6 +171,7 @@ func (c *Client) CreateDirectory(ctx context.Context, path string) (*Link, error
 	}
 
 	_, err := c.doRequestAndDecode(ctx, methodActionCreateDirectory, urlActionCreateDirectory, params, nil, &link)
+	c.invalidateCache(path)
 	if err != nil {
 		return nil, err
 	}
@@ -60,6 +60,7 @@ func TestClient_Copy(t *testing.T) {
 				Href:      "some_href",
 				Method:    "GET",
 				Templated: false,
+				affected:  []string{"/destination/some_file.ext"},
 			},
 
 			error: nil,
@@ -173,6 +174,7 @@ func TestClient_Move(t *testing.T) {
 				Href:      "some_href",
 				Method:    "GET",
 				Templated: false,
+				affected:  []string{"/source/some_file.ext", "/destination/some_file.ext"},
 			},
 
 			error: nil,
@@ -279,6 +281,7 @@ func TestClient_Delete(t *testing.T) {
 				Href:      "some_href",
 				Method:    "GET",
 				Templated: false,
+				affected:  []string{"/some_path/some_file.ext"},
 			},
 
 			error: nil,
@@ -0,0 +1,97 @@
+// Package auth implements OAuth 2.0 login flows for Yandex.Disk API clients.
+//
+// It supports authorization code flow with PKCE and a temporary local
+// callback listener (for desktop applications), Yandex "verification code"
+// flow where user pastes the code into the application (for CLIs), device
+// authorization flow (for headless machines) and helpers to build
+// oauth2.Config with the required scopes.
+//
+// Tokens obtained here could be used with yadisk.NewFromConfigAndToken or
+// saved to a yadisk.TokenStore.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/yandex"
+)
+
+// Yandex.Disk REST API scopes. An application could request only a subset of
+// scopes it has been registered with.
+const (
+	// Reading the entire Disk.
+	ScopeDiskRead = "cloud_api:disk.read"
+
+	// Writing anywhere on Disk.
+	ScopeDiskWrite = "cloud_api:disk.write"
+
+	// Access to the application folder on Disk.
+	ScopeDiskAppFolder = "cloud_api:disk.app_folder"
+
+	// Access to information about Disk.
+	ScopeDiskInfo = "cloud_api:disk.info"
+)
+
+// Create oauth2.Config for Yandex OAuth with the given scopes. If no scopes
+// are specified, all scopes of the registered application are granted.
+func NewConfig(clientID, clientSecret string, scopes ...string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Endpoint:     yandex.Endpoint,
+		Scopes:       scopes,
+	}
+}
+
+// Generate random value for the "state" parameter which protects callback
+// from CSRF.
+func GenerateState() (string, error) {
+	return randomString(16)
+}
+
+// PKCE (RFC 7636) code verifier.
+type Verifier string
+
+// Generate random code verifier.
+func NewVerifier() (Verifier, error) {
+	s, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	return Verifier(s), nil
+}
+
+// Code challenge (S256 method) for the verifier.
+func (v Verifier) Challenge() string {
+	sum := sha256.Sum256([]byte(v))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Options to pass code challenge to the authorization URL.
+func (v Verifier) AuthCodeOptions() []oauth2.AuthCodeOption {
+	return []oauth2.AuthCodeOption{
+		oauth2.SetAuthURLParam("code_challenge", v.Challenge()),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	}
+}
+
+// Options to pass code verifier to the token exchange request.
+func (v Verifier) ExchangeOptions() []oauth2.AuthCodeOption {
+	return []oauth2.AuthCodeOption{
+		oauth2.SetAuthURLParam("code_verifier", string(v)),
+	}
+}
+
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestNewConfig(t *testing.T) {
+	config := NewConfig("id", "secret", ScopeDiskRead, ScopeDiskInfo)
+
+	assert.Equal(t, "id", config.ClientID)
+	assert.Equal(t, "secret", config.ClientSecret)
+	assert.Equal(t, []string{"cloud_api:disk.read", "cloud_api:disk.info"}, config.Scopes)
+	assert.Equal(t, "https://oauth.yandex.com/authorize", config.Endpoint.AuthURL)
+}
+
+func TestVerifier(t *testing.T) {
+	v1, err := NewVerifier()
+	assert.Nil(t, err)
+	v2, err := NewVerifier()
+	assert.Nil(t, err)
+
+	assert.NotEqual(t, v1, v2)
+	// RFC 7636 requires 43 to 128 characters
+	assert.True(t, len(v1) >= 43 && len(v1) <= 128)
+
+	sum := sha256.Sum256([]byte(v1))
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(sum[:]), v1.Challenge())
+}
+
+// Fake token endpoint which accepts only the given code and checks that code
+// verifier matches the challenge sent to authorization URL.
+func newFakeTokenEndpoint(t *testing.T, code string, challenge *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, r.ParseForm())
+		assert.Equal(t, "authorization_code", r.PostForm.Get("grant_type"))
+
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != code || base64.RawURLEncoding.EncodeToString(sum[:]) != *challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant","error_description":"Code has expired"}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"access","token_type":"bearer","refresh_token":"refresh","expires_in":3600}`))
+	}))
+}
+
+func newTestConfig(tokenURL string) *oauth2.Config {
+	config := NewConfig("id", "secret", ScopeDiskAppFolder)
+	config.Endpoint = oauth2.Endpoint{
+		AuthURL:   "https://oauth.example.com/authorize",
+		TokenURL:  tokenURL,
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+	return config
+}
@@ -0,0 +1,257 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Yandex endpoint to request device and user codes.
+const DeviceCodeURL = "https://oauth.yandex.com/device/code"
+
+const (
+	defaultDeviceInterval = 5 * time.Second
+	slowDownIncrement     = 5 * time.Second
+)
+
+// Device and user codes issued by the authorization server.
+type DeviceCode struct {
+	// Code used by the application to poll for the token.
+	DeviceCode string `json:"device_code"`
+
+	// Code user should enter on the verification page.
+	UserCode string `json:"user_code"`
+
+	// URL of the verification page.
+	VerificationURL string `json:"verification_url"`
+
+	// Minimum number of seconds between polling requests.
+	Interval int64 `json:"interval"`
+
+	// Lifetime of the codes in seconds.
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// Options of the device authorization flow.
+type DeviceOptions struct {
+	// Device code endpoint. DeviceCodeURL is used by default.
+	DeviceCodeURL string
+
+	// Unique device identifier, optional.
+	DeviceID string
+
+	// Device name shown to user, optional.
+	DeviceName string
+
+	// Function to show user code and verification URL to user. It is required.
+	ShowCode func(code *DeviceCode) error
+}
+
+// ShowCode implementation which prints instructions to out.
+func PrintDeviceCode(out io.Writer) func(code *DeviceCode) error {
+	return func(code *DeviceCode) error {
+		_, err := fmt.Fprintf(out, "Open %s in any browser and enter the code: %s\n", code.VerificationURL, code.UserCode)
+		return err
+	}
+}
+
+// Error returned by token endpoint, e.g. when user denied access or device
+// code has expired.
+type TokenError struct {
+	// Error code, e.g. "access_denied" or "expired_token".
+	Code string `json:"error"`
+
+	// Human readable description.
+	Description string `json:"error_description"`
+}
+
+func (err TokenError) Error() string {
+	return fmt.Sprintf("auth: %s: %s", err.Code, err.Description)
+}
+
+// Obtain token using device authorization flow. It is useful for headless
+// machines which have neither browser nor reachable callback URL: user enters
+// the code on any other device.
+//
+// Method requests device code, shows it using opts.ShowCode and polls token
+// endpoint (config.Endpoint.TokenURL) until user grants or denies access, the
+// code expires or the context is done.
+//
+// HTTP client could be set to context with oauth2.HTTPClient key.
+func LoginWithDeviceCode(ctx context.Context, config *oauth2.Config, opts DeviceOptions) (*oauth2.Token, error) {
+	if opts.ShowCode == nil {
+		return nil, errors.New("auth: ShowCode is required")
+	}
+
+	code, err := RequestDeviceCode(ctx, config, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := opts.ShowCode(code); err != nil {
+		return nil, err
+	}
+
+	return PollDeviceToken(ctx, config, code)
+}
+
+// Request device and user codes.
+func RequestDeviceCode(ctx context.Context, config *oauth2.Config, opts DeviceOptions) (*DeviceCode, error) {
+	endpoint := opts.DeviceCodeURL
+	if endpoint == "" {
+		endpoint = DeviceCodeURL
+	}
+
+	params := url.Values{}
+	params.Set("client_id", config.ClientID)
+	if opts.DeviceID != "" {
+		params.Set("device_id", opts.DeviceID)
+	}
+	if opts.DeviceName != "" {
+		params.Set("device_name", opts.DeviceName)
+	}
+	if len(config.Scopes) > 0 {
+		params.Set("scope", strings.Join(config.Scopes, " "))
+	}
+
+	var code DeviceCode
+
+	err := postForm(ctx, endpoint, params, &code)
+	if err != nil {
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+// Poll token endpoint until user grants access.
+//
+// Polling interval is the one returned by the device code endpoint and it is
+// increased each time server responds with "slow_down". When the code expires
+// before user grants access, context.DeadlineExceeded is returned.
+func PollDeviceToken(ctx context.Context, config *oauth2.Config, code *DeviceCode) (*oauth2.Token, error) {
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultDeviceInterval
+	}
+
+	if code.ExpiresIn > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(code.ExpiresIn)*time.Second)
+		defer cancel()
+	}
+
+	params := url.Values{}
+	params.Set("grant_type", "device_code")
+	params.Set("code", code.DeviceCode)
+	params.Set("client_id", config.ClientID)
+	params.Set("client_secret", config.ClientSecret)
+
+	for {
+		if err := wait(ctx, interval); err != nil {
+			return nil, err
+		}
+
+		var resp tokenResponse
+
+		err := postForm(ctx, config.Endpoint.TokenURL, params, &resp)
+		if err == nil {
+			return resp.token(), nil
+		}
+
+		tokenErr, ok := err.(TokenError)
+		if !ok {
+			return nil, err
+		}
+
+		switch tokenErr.Code {
+		case "authorization_pending":
+			continue
+		case "slow_down":
+			interval += slowDownIncrement
+		default:
+			return nil, tokenErr
+		}
+	}
+}
+
+// Wait for the given duration or until the context is done. It's a variable
+// to avoid real waiting in tests.
+var wait = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func (r *tokenResponse) token() *oauth2.Token {
+	token := &oauth2.Token{
+		AccessToken:  r.AccessToken,
+		TokenType:    r.TokenType,
+		RefreshToken: r.RefreshToken,
+	}
+
+	if r.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+	}
+
+	return token
+}
+
+func postForm(ctx context.Context, endpoint string, params url.Values, result interface{}) error {
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	client := http.DefaultClient
+	if c, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok {
+		client = c
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		var tokenErr TokenError
+		if err := json.Unmarshal(body, &tokenErr); err != nil || tokenErr.Code == "" {
+			return fmt.Errorf("auth: unexpected response %d: %s", resp.StatusCode, body)
+		}
+		return tokenErr
+	}
+
+	return json.Unmarshal(body, result)
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Fake Yandex OAuth server for device flow. Token endpoint responds with the
+// given sequence of errors and then issues the token.
+func newFakeDeviceServer(t *testing.T, tokenErrors []string) *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/device/code", func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, r.ParseForm())
+		assert.Equal(t, "id", r.PostForm.Get("client_id"))
+		assert.Equal(t, "my-device", r.PostForm.Get("device_name"))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"device_code":"device-code","user_code":"USERCODE","verification_url":"https://ya.ru/device","interval":3,"expires_in":300}`))
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, r.ParseForm())
+		assert.Equal(t, "device_code", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "device-code", r.PostForm.Get("code"))
+		assert.Equal(t, "secret", r.PostForm.Get("client_secret"))
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if len(tokenErrors) > 0 {
+			code := tokenErrors[0]
+			tokenErrors = tokenErrors[1:]
+
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"` + code + `","error_description":"some description"}`))
+			return
+		}
+
+		w.Write([]byte(`{"access_token":"access","token_type":"bearer","refresh_token":"refresh","expires_in":3600}`))
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestLoginWithDeviceCode(t *testing.T) {
+	tests := []struct {
+		name string
+
+		tokenErrors []string
+
+		waits []time.Duration
+		error error
+	}{
+		{
+			name: "granted immediately",
+
+			waits: []time.Duration{3 * time.Second},
+		},
+
+		{
+			name: "pending and slow down",
+
+			tokenErrors: []string{"authorization_pending", "slow_down", "authorization_pending"},
+
+			waits: []time.Duration{3 * time.Second, 3 * time.Second, 8 * time.Second, 8 * time.Second},
+		},
+
+		{
+			name: "access denied",
+
+			tokenErrors: []string{"authorization_pending", "access_denied"},
+
+			waits: []time.Duration{3 * time.Second, 3 * time.Second},
+			error: TokenError{Code: "access_denied", Description: "some description"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newFakeDeviceServer(t, test.tokenErrors)
+			defer server.Close()
+
+			var waits []time.Duration
+
+			originalWait := wait
+			defer func() { wait = originalWait }()
+			wait = func(ctx context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				return nil
+			}
+
+			config := newTestConfig(server.URL + "/token")
+			out := &bytes.Buffer{}
+
+			token, err := LoginWithDeviceCode(context.Background(), config, DeviceOptions{
+				DeviceCodeURL: server.URL + "/device/code",
+				DeviceName:    "my-device",
+				ShowCode:      PrintDeviceCode(out),
+			})
+
+			assert.Equal(t, test.error, err)
+			assert.Equal(t, test.waits, waits)
+			assert.Contains(t, out.String(), "USERCODE")
+			assert.Contains(t, out.String(), "https://ya.ru/device")
+
+			if test.error == nil {
+				assert.Equal(t, "access", token.AccessToken)
+				assert.Equal(t, "refresh", token.RefreshToken)
+				assert.True(t, token.Expiry.After(time.Now()))
+			}
+		})
+	}
+
+	t.Run("without ShowCode", func(t *testing.T) {
+		token, err := LoginWithDeviceCode(context.Background(), newTestConfig("http://127.0.0.1:1/"), DeviceOptions{})
+		assert.Nil(t, token)
+		assert.EqualError(t, err, "auth: ShowCode is required")
+	})
+}
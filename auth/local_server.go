@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	defaultListenAddr   = "127.0.0.1:0"
+	defaultCallbackPath = "/callback"
+
+	defaultSuccessMessage = "Authorization completed, you can close this window now."
+	defaultFailureMessage = "Authorization failed, please return to the application."
+)
+
+var (
+	// ErrStateMismatch is reported when state received by callback differs
+	// from the one that has been sent. LoginWithLocalServer rejects such
+	// requests and keeps waiting for the callback with the correct state.
+	ErrStateMismatch = errors.New("auth: state mismatch")
+
+	// ErrNoCode is returned when callback has no authorization code.
+	ErrNoCode = errors.New("auth: authorization code is missing")
+)
+
+// Error returned by the authorization server to the callback, e.g. when user
+// denied access.
+type CallbackError struct {
+	// Error code, e.g. "access_denied".
+	Code string
+
+	// Human readable description.
+	Description string
+}
+
+func (err CallbackError) Error() string {
+	return fmt.Sprintf("auth: %s: %s", err.Code, err.Description)
+}
+
+// Options of the authorization code flow with local callback listener.
+type LocalServerOptions struct {
+	// Function to show authorization URL to user, e.g. print it or open it in
+	// a browser. It is required.
+	OpenURL func(authURL string) error
+
+	// Message shown in browser after successful authorization.
+	SuccessMessage string
+
+	// Message shown in browser after failed authorization.
+	FailureMessage string
+}
+
+// Obtain token using authorization code flow with PKCE.
+//
+// A temporary HTTP server is started to receive the callback. If
+// config.RedirectURL is set, server listens on its host and port and serves
+// its path (it must match the callback URL registered for the application,
+// e.g. "http://localhost:8000/callback"). Otherwise it listens on a random
+// port of the loopback interface. Redirect URL without path is served at
+// "/".
+//
+// Method blocks until the callback is received or the context is done.
+func LoginWithLocalServer(ctx context.Context, config *oauth2.Config, opts LocalServerOptions) (*oauth2.Token, error) {
+	if opts.OpenURL == nil {
+		return nil, errors.New("auth: OpenURL is required")
+	}
+	if opts.SuccessMessage == "" {
+		opts.SuccessMessage = defaultSuccessMessage
+	}
+	if opts.FailureMessage == "" {
+		opts.FailureMessage = defaultFailureMessage
+	}
+
+	listenAddr, callbackPath := defaultListenAddr, defaultCallbackPath
+	if config.RedirectURL != "" {
+		u, err := url.Parse(config.RedirectURL)
+		if err != nil {
+			return nil, err
+		}
+		listenAddr, callbackPath = u.Host, u.Path
+
+		// Authorization server redirects to the root of such URL
+		if callbackPath == "" {
+			callbackPath = "/"
+		}
+	}
+
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	// Don't modify caller's config
+	cfg := *config
+	if cfg.RedirectURL == "" {
+		cfg.RedirectURL = "http://" + listener.Addr().String() + callbackPath
+	}
+
+	state, err := GenerateState()
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := NewVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		code string
+		err  error
+	}
+
+	results := make(chan result, 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(callbackPath, func(w http.ResponseWriter, r *http.Request) {
+		code, err := parseCallback(r, state)
+
+		// Requests without the state of this login (e.g. prefetched by
+		// browser or made by other local process) are rejected, but they
+		// don't end the login
+		if err == ErrStateMismatch {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(opts.FailureMessage))
+			return
+		}
+
+		select {
+		case results <- result{code: code, err: err}:
+		default:
+			// Result has already been received, ignore repeated requests
+		}
+
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(opts.FailureMessage))
+			return
+		}
+
+		w.Write([]byte(opts.SuccessMessage))
+	})
+
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer server.Close()
+
+	authOpts := verifier.AuthCodeOptions()
+	if err := opts.OpenURL(cfg.AuthCodeURL(state, authOpts...)); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-results:
+		if res.err != nil {
+			return nil, res.err
+		}
+		return cfg.Exchange(ctx, res.code, verifier.ExchangeOptions()...)
+	}
+}
+
+func parseCallback(r *http.Request, state string) (string, error) {
+	q := r.URL.Query()
+
+	if q.Get("state") != state {
+		return "", ErrStateMismatch
+	}
+
+	if code := q.Get("error"); code != "" {
+		return "", CallbackError{Code: code, Description: q.Get("error_description")}
+	}
+
+	code := q.Get("code")
+	if code == "" {
+		return "", ErrNoCode
+	}
+
+	return code, nil
+}
@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginWithLocalServer(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// Modifies callback query sent by "browser"
+		callback func(q url.Values)
+
+		accessToken string
+		error       error
+	}{
+		{
+			name:     "successful login",
+			callback: func(q url.Values) {},
+
+			accessToken: "access",
+			error:       nil,
+		},
+
+		{
+			name: "access denied",
+			callback: func(q url.Values) {
+				q.Del("code")
+				q.Set("error", "access_denied")
+				q.Set("error_description", "User denied access")
+			},
+
+			error: CallbackError{Code: "access_denied", Description: "User denied access"},
+		},
+
+		{
+			name: "missing code",
+			callback: func(q url.Values) {
+				q.Del("code")
+			},
+
+			error: ErrNoCode,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var challenge string
+
+			server := newFakeTokenEndpoint(t, "the-code", &challenge)
+			defer server.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			// Emulates browser: user grants access and gets redirected to callback
+			openURL := func(authURL string) error {
+				u, err := url.Parse(authURL)
+				assert.Nil(t, err)
+
+				params := u.Query()
+				assert.Equal(t, "S256", params.Get("code_challenge_method"))
+				assert.Equal(t, ScopeDiskAppFolder, params.Get("scope"))
+				challenge = params.Get("code_challenge")
+
+				q := url.Values{}
+				q.Set("code", "the-code")
+				q.Set("state", params.Get("state"))
+				test.callback(q)
+
+				go func() {
+					resp, err := http.Get(params.Get("redirect_uri") + "?" + q.Encode())
+					if err == nil {
+						ioutil.ReadAll(resp.Body)
+						resp.Body.Close()
+					}
+				}()
+
+				return nil
+			}
+
+			token, err := LoginWithLocalServer(ctx, newTestConfig(server.URL), LocalServerOptions{OpenURL: openURL})
+
+			assert.Equal(t, test.error, err)
+			if test.error == nil {
+				assert.Equal(t, test.accessToken, token.AccessToken)
+				assert.Equal(t, "refresh", token.RefreshToken)
+			}
+		})
+	}
+
+	t.Run("state mismatch", func(t *testing.T) {
+		var challenge string
+
+		server := newFakeTokenEndpoint(t, "the-code", &challenge)
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		// Forged callback is rejected, login ends with the genuine one
+		openURL := func(authURL string) error {
+			u, _ := url.Parse(authURL)
+			params := u.Query()
+			challenge = params.Get("code_challenge")
+
+			forged := url.Values{"code": {"forged-code"}, "state": {"forged"}}
+			resp, err := http.Get(params.Get("redirect_uri") + "?" + forged.Encode())
+			assert.Nil(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+			go func() {
+				q := url.Values{"code": {"the-code"}, "state": {params.Get("state")}}
+				resp, err := http.Get(params.Get("redirect_uri") + "?" + q.Encode())
+				if err == nil {
+					resp.Body.Close()
+				}
+			}()
+
+			return nil
+		}
+
+		token, err := LoginWithLocalServer(ctx, newTestConfig(server.URL), LocalServerOptions{OpenURL: openURL})
+		assert.Nil(t, err)
+		assert.Equal(t, "access", token.AccessToken)
+	})
+
+	t.Run("redirect URL without path", func(t *testing.T) {
+		var challenge string
+
+		server := newFakeTokenEndpoint(t, "the-code", &challenge)
+		defer server.Close()
+
+		// Find free port
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		addr := listener.Addr().String()
+		listener.Close()
+
+		config := newTestConfig(server.URL)
+		config.RedirectURL = "http://" + addr
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		openURL := func(authURL string) error {
+			u, _ := url.Parse(authURL)
+			params := u.Query()
+			challenge = params.Get("code_challenge")
+			assert.Equal(t, "http://"+addr, params.Get("redirect_uri"))
+
+			go func() {
+				q := url.Values{"code": {"the-code"}, "state": {params.Get("state")}}
+				resp, err := http.Get("http://" + addr + "/?" + q.Encode())
+				if err == nil {
+					resp.Body.Close()
+				}
+			}()
+
+			return nil
+		}
+
+		token, err := LoginWithLocalServer(ctx, config, LocalServerOptions{OpenURL: openURL})
+		assert.Nil(t, err)
+		assert.Equal(t, "access", token.AccessToken)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+
+		token, err := LoginWithLocalServer(ctx, newTestConfig("http://127.0.0.1:1/"), LocalServerOptions{
+			OpenURL: func(string) error {
+				cancel()
+				return nil
+			},
+		})
+
+		assert.Nil(t, token)
+		assert.Equal(t, context.Canceled, err)
+	})
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// Redirect URL of the Yandex page which shows verification code to user
+// instead of redirecting back to the application. Application should be
+// registered with this callback URL to use verification code flow.
+const VerificationCodeRedirectURL = "https://oauth.yandex.ru/verification_code"
+
+// Function which shows authorization URL to user and returns verification
+// code entered by them.
+type CodePrompt func(ctx context.Context, authURL string) (string, error)
+
+// Prompt which prints authorization URL to out and reads code from in.
+func TerminalPrompt(in io.Reader, out io.Writer) CodePrompt {
+	return func(ctx context.Context, authURL string) (string, error) {
+		_, err := fmt.Fprintf(out, "Open the following URL in your browser and grant access:\n\n%s\n\nEnter verification code: ", authURL)
+		if err != nil {
+			return "", err
+		}
+
+		line, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil && !(err == io.EOF && line != "") {
+			return "", err
+		}
+
+		return strings.TrimSpace(line), nil
+	}
+}
+
+// Obtain token using Yandex verification code flow: user opens authorization
+// URL, grants access, copies the code shown by Yandex and pastes it into the
+// application.
+//
+// If config.RedirectURL is empty, VerificationCodeRedirectURL is used.
+func LoginWithVerificationCode(ctx context.Context, config *oauth2.Config, prompt CodePrompt) (*oauth2.Token, error) {
+	// Don't modify caller's config
+	cfg := *config
+	if cfg.RedirectURL == "" {
+		cfg.RedirectURL = VerificationCodeRedirectURL
+	}
+
+	verifier, err := NewVerifier()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := GenerateState()
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := prompt(ctx, cfg.AuthCodeURL(state, verifier.AuthCodeOptions()...))
+	if err != nil {
+		return nil, err
+	}
+
+	if code == "" {
+		return nil, ErrNoCode
+	}
+
+	return cfg.Exchange(ctx, code, verifier.ExchangeOptions()...)
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginWithVerificationCode(t *testing.T) {
+	var challenge string
+
+	server := newFakeTokenEndpoint(t, "1234567", &challenge)
+	defer server.Close()
+
+	prompt := func(ctx context.Context, authURL string) (string, error) {
+		u, err := url.Parse(authURL)
+		assert.Nil(t, err)
+
+		assert.Equal(t, VerificationCodeRedirectURL, u.Query().Get("redirect_uri"))
+		challenge = u.Query().Get("code_challenge")
+
+		return "1234567", nil
+	}
+
+	token, err := LoginWithVerificationCode(context.Background(), newTestConfig(server.URL), prompt)
+	assert.Nil(t, err)
+	assert.Equal(t, "access", token.AccessToken)
+
+	t.Run("wrong code", func(t *testing.T) {
+		prompt := func(ctx context.Context, authURL string) (string, error) {
+			return "7654321", nil
+		}
+
+		token, err := LoginWithVerificationCode(context.Background(), newTestConfig(server.URL), prompt)
+		assert.Nil(t, token)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("empty code", func(t *testing.T) {
+		prompt := func(ctx context.Context, authURL string) (string, error) {
+			return "", nil
+		}
+
+		token, err := LoginWithVerificationCode(context.Background(), newTestConfig(server.URL), prompt)
+		assert.Nil(t, token)
+		assert.Equal(t, ErrNoCode, err)
+	})
+}
+
+func TestTerminalPrompt(t *testing.T) {
+	out := &bytes.Buffer{}
+
+	code, err := TerminalPrompt(strings.NewReader(" 1234567 \n"), out)(context.Background(), "https://auth.url/")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "1234567", code)
+	assert.Contains(t, out.String(), "https://auth.url/")
+}
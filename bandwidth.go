@@ -0,0 +1,146 @@
+package yadisk
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"golang.org/x/time/rate"
+)
+
+const defaultBandwidthBurst = 32 << 10
+
+// Bandwidth cap of transfers.
+type BandwidthLimit struct {
+	// Average transfer rate, zero means no limit.
+	BytesPerSecond int64
+
+	// Number of bytes which could be transferred at once above the average
+	// rate. It's also the size of a single read, so too small values make
+	// transfers slower. 32 KiB by default.
+	Burst int
+}
+
+type bandwidthLimitKey struct{}
+
+// Limit bandwidth of uploads shared by all concurrent transfers of the client
+// (including Upload and all of upload helpers). Limit could be changed at
+// any time, running transfers adopt the new limit. Zero limit removes the cap.
+func (c *Client) SetUploadBandwidthLimit(limit BandwidthLimit) {
+	c.bandwidthMu.Lock()
+	defer c.bandwidthMu.Unlock()
+
+	c.uploadLimiter = newBandwidthLimiter(limit)
+}
+
+// Limit bandwidth of downloads shared by all concurrent transfers of the
+// client (including Download and all of download helpers). Limit could be
+// changed at any time, running transfers adopt the new limit. Zero limit
+// removes the cap.
+func (c *Client) SetDownloadBandwidthLimit(limit BandwidthLimit) {
+	c.bandwidthMu.Lock()
+	defer c.bandwidthMu.Unlock()
+
+	c.downloadLimiter = newBandwidthLimiter(limit)
+}
+
+// Limit bandwidth of transfers made with the returned context. The cap is
+// shared by all transfers made with the context (e.g. by workers of
+// DownloadParallel) and applies in addition to client's caps.
+func WithBandwidthLimit(ctx context.Context, limit BandwidthLimit) context.Context {
+	return context.WithValue(ctx, bandwidthLimitKey{}, newBandwidthLimiter(limit))
+}
+
+func newBandwidthLimiter(limit BandwidthLimit) *rate.Limiter {
+	if limit.BytesPerSecond <= 0 {
+		return nil
+	}
+
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = defaultBandwidthBurst
+	}
+
+	return rate.NewLimiter(rate.Limit(limit.BytesPerSecond), burst)
+}
+
+func (c *Client) currentUploadLimiter() *rate.Limiter {
+	c.bandwidthMu.RLock()
+	defer c.bandwidthMu.RUnlock()
+
+	return c.uploadLimiter
+}
+
+func (c *Client) currentDownloadLimiter() *rate.Limiter {
+	c.bandwidthMu.RLock()
+	defer c.bandwidthMu.RUnlock()
+
+	return c.downloadLimiter
+}
+
+// Throttle the request body if it's sent, otherwise - the response body.
+// Returned function must be called with the response.
+func (c *Client) throttleRequest(ctx context.Context, req *http.Request) func(resp *http.Response) {
+	callLimiter, _ := ctx.Value(bandwidthLimitKey{}).(*rate.Limiter)
+
+	if req.Body != nil {
+		if req.Body != http.NoBody {
+			req.Body = &throttledBody{
+				ReadCloser:    req.Body,
+				ctx:           ctx,
+				clientLimiter: c.currentUploadLimiter,
+				callLimiter:   callLimiter,
+			}
+		}
+
+		return func(*http.Response) {}
+	}
+
+	return func(resp *http.Response) {
+		if resp == nil {
+			return
+		}
+
+		resp.Body = &throttledBody{
+			ReadCloser:    resp.Body,
+			ctx:           ctx,
+			clientLimiter: c.currentDownloadLimiter,
+			callLimiter:   callLimiter,
+		}
+	}
+}
+
+// Body which waits for limiters after every read.
+type throttledBody struct {
+	io.ReadCloser
+	ctx context.Context
+
+	// Client's limiter is requested on every read, so it could be changed
+	clientLimiter func() *rate.Limiter
+	callLimiter   *rate.Limiter
+}
+
+func (b *throttledBody) Read(p []byte) (int, error) {
+	limiters := []*rate.Limiter{b.clientLimiter(), b.callLimiter}
+
+	// Single read must not exceed any burst
+	for _, limiter := range limiters {
+		if limiter != nil && len(p) > limiter.Burst() {
+			p = p[:limiter.Burst()]
+		}
+	}
+
+	n, err := b.ReadCloser.Read(p)
+
+	for _, limiter := range limiters {
+		if limiter == nil || n == 0 {
+			continue
+		}
+
+		if waitErr := limiter.WaitN(b.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+
+	return n, err
+}
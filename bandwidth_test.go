@@ -0,0 +1,110 @@
+package yadisk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_BandwidthLimit(t *testing.T) {
+	content := strings.Repeat("0123456789", 300)
+
+	tests := []struct {
+		name string
+
+		uploadLimit   BandwidthLimit
+		downloadLimit BandwidthLimit
+		callLimit     BandwidthLimit
+
+		minUpload   time.Duration
+		minDownload time.Duration
+	}{
+		{
+			name: "no limits",
+		},
+
+		{
+			// 2000 bytes above the burst take 200ms
+			name:        "client's upload limit",
+			uploadLimit: BandwidthLimit{BytesPerSecond: 10000, Burst: 1000},
+
+			minUpload: 150 * time.Millisecond,
+		},
+
+		{
+			name:          "client's download limit",
+			downloadLimit: BandwidthLimit{BytesPerSecond: 10000, Burst: 1000},
+
+			minDownload: 150 * time.Millisecond,
+		},
+
+		{
+			// Both transfers share the limiter, so download has no burst
+			name:      "call's limit",
+			callLimit: BandwidthLimit{BytesPerSecond: 10000, Burst: 1000},
+
+			minUpload:   150 * time.Millisecond,
+			minDownload: 250 * time.Millisecond,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, disk := newFakeDiskClient()
+			disk.PutFile("/file.bin", []byte(content))
+
+			client.SetUploadBandwidthLimit(test.uploadLimit)
+			client.SetDownloadBandwidthLimit(test.downloadLimit)
+
+			ctx := context.Background()
+			if test.callLimit.BytesPerSecond > 0 {
+				ctx = WithBandwidthLimit(ctx, test.callLimit)
+			}
+
+			started := time.Now()
+			err := client.UploadReader(ctx, strings.NewReader(content), "/uploaded.bin", nil)
+			assert.Nil(t, err)
+			assert.True(t, time.Since(started) >= test.minUpload, "upload took %s", time.Since(started))
+			assert.Equal(t, content, string(disk.File("/uploaded.bin").Content))
+
+			buf := &bytes.Buffer{}
+
+			started = time.Now()
+			_, err = client.DownloadTo(ctx, "/file.bin", buf)
+			assert.Nil(t, err)
+			assert.True(t, time.Since(started) >= test.minDownload, "download took %s", time.Since(started))
+			assert.Equal(t, content, buf.String())
+		})
+	}
+}
+
+func TestClient_SetDownloadBandwidthLimit_RunningTransfer(t *testing.T) {
+	client, disk := newFakeDiskClient()
+	disk.PutFile("/file.bin", []byte(strings.Repeat("0", 3000)))
+
+	// Limit is too low for the transfer to finish unless it's lifted
+	client.SetDownloadBandwidthLimit(BandwidthLimit{BytesPerSecond: 100, Burst: 1000})
+
+	link, err := client.RequestDownloadLink(context.Background(), "/file.bin")
+	assert.Nil(t, err)
+
+	resp, err := client.Download(context.Background(), link)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+
+	buf := make([]byte, 1000)
+	_, err = resp.Body.Read(buf)
+	assert.Nil(t, err)
+
+	client.SetDownloadBandwidthLimit(BandwidthLimit{})
+
+	started := time.Now()
+	rest, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	assert.Len(t, rest, 2000)
+	assert.True(t, time.Since(started) < time.Second)
+}
@@ -0,0 +1,266 @@
+package yadisk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultBatchWorkers = 8
+
+// ErrBatchIncomplete is returned by batch operations when some of the
+// operations have failed, are still in progress or haven't been started, see
+// BatchReport for details.
+var ErrBatchIncomplete = errors.New("yadisk: some of the operations have not succeeded")
+
+// Source and destination of copy or move.
+type PathPair struct {
+	Src string
+	Dst string
+}
+
+// State of a single operation of a batch.
+type BatchStatus int
+
+const (
+	// Operation hasn't been started because batch has been stopped on error
+	// or cancelled.
+	BatchNotStarted BatchStatus = iota
+
+	// Operation has succeeded.
+	BatchSucceeded
+
+	// Operation has failed, see BatchResult.Err.
+	BatchFailed
+
+	// Asynchronous operation hasn't finished in time, its status could be
+	// tracked with BatchResult.Operation.
+	BatchPending
+)
+
+// Result of a single operation of a batch.
+type BatchResult struct {
+	// Source path of copy or move, it's empty for delete.
+	Src string
+
+	// Destination path of copy or move, deleted path for delete.
+	Path string
+
+	Status BatchStatus
+
+	// Error of failed operation.
+	Err error
+
+	// Link to status of asynchronous operation, nil if operation has been
+	// completed at once.
+	Operation *Link
+}
+
+// Report of a batch.
+type BatchReport struct {
+	// Results in the order of requested operations.
+	Results []BatchResult
+}
+
+// Results of operations with the given status.
+func (r *BatchReport) WithStatus(status BatchStatus) []BatchResult {
+	var results []BatchResult
+	for _, result := range r.Results {
+		if result.Status == status {
+			results = append(results, result)
+		}
+	}
+
+	return results
+}
+
+// Options of CopyMany, MoveMany and DeleteMany.
+type BatchOptions struct {
+	// Number of simultaneous requests. 8 by default.
+	Workers int
+
+	// Overwrite existing destination of copy or move.
+	Overwrite bool
+
+	// Delete permanently instead of moving to the Trash.
+	Permanently bool
+
+	// Don't start new operations after the first failure. Operations which
+	// have been started already are completed.
+	StopOnError bool
+
+	// Interval between status requests of asynchronous operations. 1 second
+	// by default.
+	PollInterval time.Duration
+
+	// How long asynchronous operations are waited for, unfinished operations
+	// are reported as pending. Zero means they're waited until context is
+	// done.
+	WaitTimeout time.Duration
+}
+
+// Copy several files or directories.
+//
+// pairs - Sources and destinations.
+// opts - Batch options, nil means default options.
+//
+// Operations are performed simultaneously, asynchronous operations (copy of
+// non-empty directories) are waited for.
+//
+// Method returns report of every operation. If some of them haven't
+// succeeded, the report is returned along with ErrBatchIncomplete. If context
+// is done, the report is returned along with context's error.
+func (c *Client) CopyMany(ctx context.Context, pairs []PathPair, opts *BatchOptions) (*BatchReport, error) {
+	if opts == nil {
+		opts = &BatchOptions{}
+	}
+
+	report := &BatchReport{Results: make([]BatchResult, len(pairs))}
+	for i, pair := range pairs {
+		report.Results[i] = BatchResult{Src: pair.Src, Path: pair.Dst}
+	}
+
+	return c.runBatch(ctx, report, opts, func(ctx context.Context, result *BatchResult) (*Link, int, error) {
+		return c.Copy(ctx, result.Src, result.Path, opts.Overwrite)
+	})
+}
+
+// Move several files or directories.
+//
+// pairs - Sources and destinations.
+// opts - Batch options, nil means default options.
+//
+// See CopyMany for details.
+func (c *Client) MoveMany(ctx context.Context, pairs []PathPair, opts *BatchOptions) (*BatchReport, error) {
+	if opts == nil {
+		opts = &BatchOptions{}
+	}
+
+	report := &BatchReport{Results: make([]BatchResult, len(pairs))}
+	for i, pair := range pairs {
+		report.Results[i] = BatchResult{Src: pair.Src, Path: pair.Dst}
+	}
+
+	return c.runBatch(ctx, report, opts, func(ctx context.Context, result *BatchResult) (*Link, int, error) {
+		return c.Move(ctx, result.Src, result.Path, opts.Overwrite)
+	})
+}
+
+// Delete several files or directories.
+//
+// paths - The paths to the resources to delete.
+// opts - Batch options, nil means default options.
+//
+// See CopyMany for details.
+func (c *Client) DeleteMany(ctx context.Context, paths []string, opts *BatchOptions) (*BatchReport, error) {
+	if opts == nil {
+		opts = &BatchOptions{}
+	}
+
+	report := &BatchReport{Results: make([]BatchResult, len(paths))}
+	for i, p := range paths {
+		report.Results[i] = BatchResult{Path: p}
+	}
+
+	return c.runBatch(ctx, report, opts, func(ctx context.Context, result *BatchResult) (*Link, int, error) {
+		return c.Delete(ctx, result.Path, opts.Permanently)
+	})
+}
+
+type batchFunc func(ctx context.Context, result *BatchResult) (*Link, int, error)
+
+func (c *Client) runBatch(ctx context.Context, report *BatchReport, opts *BatchOptions, do batchFunc) (*BatchReport, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = defaultBatchWorkers
+	}
+
+	jobs := make(chan *BatchResult)
+
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for result := range jobs {
+				c.runBatchOperation(ctx, result, opts, do)
+
+				if result.Status == BatchFailed && opts.StopOnError {
+					stopOnce.Do(func() { close(stop) })
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range report.Results {
+		select {
+		case <-stop:
+			break feed
+		case <-ctx.Done():
+			break feed
+		default:
+		}
+
+		select {
+		case jobs <- &report.Results[i]:
+		case <-stop:
+			break feed
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return report, ctx.Err()
+	}
+
+	for _, result := range report.Results {
+		if result.Status != BatchSucceeded {
+			return report, ErrBatchIncomplete
+		}
+	}
+
+	return report, nil
+}
+
+func (c *Client) runBatchOperation(ctx context.Context, result *BatchResult, opts *BatchOptions, do batchFunc) {
+	link, statusCode, err := do(ctx, result)
+	if err != nil {
+		result.Status, result.Err = BatchFailed, err
+		return
+	}
+
+	if statusCode != http.StatusAccepted {
+		result.Status = BatchSucceeded
+		return
+	}
+
+	result.Operation = link
+
+	waitCtx := ctx
+	if opts.WaitTimeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, opts.WaitTimeout)
+		defer cancel()
+	}
+
+	err = c.WaitOperation(waitCtx, link, opts.PollInterval)
+	switch {
+	case err == nil:
+		result.Status = BatchSucceeded
+	case waitCtx.Err() != nil:
+		result.Status = BatchPending
+	default:
+		result.Status, result.Err = BatchFailed, err
+	}
+}
@@ -0,0 +1,180 @@
+package yadisk
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/go-yandex-disk/internal/testhelpers"
+)
+
+func newBatchTestDisk() (*Client, *testhelpers.FakeDisk) {
+	client, disk := newFakeDiskClient()
+	disk.PutFile("/src/a.txt", []byte("A"))
+	disk.PutFile("/src/b.txt", []byte("B"))
+	disk.PutFile("/src/dir/c.txt", []byte("C"))
+	disk.PutDir("/dst")
+
+	return client, disk
+}
+
+func batchStatuses(report *BatchReport) []BatchStatus {
+	var statuses []BatchStatus
+	for _, result := range report.Results {
+		statuses = append(statuses, result.Status)
+	}
+
+	return statuses
+}
+
+func TestClient_CopyMany(t *testing.T) {
+	pairs := []PathPair{
+		{Src: "/src/a.txt", Dst: "/dst/a.txt"},
+		{Src: "/src/missing.txt", Dst: "/dst/missing.txt"},
+		{Src: "/src/dir", Dst: "/dst/dir"},
+		{Src: "/src/b.txt", Dst: "/dst/b.txt"},
+	}
+
+	tests := []struct {
+		name string
+
+		opts            BatchOptions
+		asyncOperations int
+		failOperations  bool
+
+		statuses []BatchStatus
+		files    []string
+		error    error
+	}{
+		{
+			name: "continue on error",
+
+			statuses: []BatchStatus{BatchSucceeded, BatchFailed, BatchSucceeded, BatchSucceeded},
+			files:    []string{"/dst/a.txt", "/dst/b.txt", "/dst/dir/c.txt"},
+			error:    ErrBatchIncomplete,
+		},
+
+		{
+			name: "stop on error",
+			opts: BatchOptions{Workers: 1, StopOnError: true},
+
+			statuses: []BatchStatus{BatchSucceeded, BatchFailed, BatchNotStarted, BatchNotStarted},
+			files:    []string{"/dst/a.txt"},
+			error:    ErrBatchIncomplete,
+		},
+
+		{
+			name:            "finished asynchronous operation",
+			opts:            BatchOptions{PollInterval: time.Millisecond},
+			asyncOperations: 2,
+
+			statuses: []BatchStatus{BatchSucceeded, BatchFailed, BatchSucceeded, BatchSucceeded},
+			files:    []string{"/dst/a.txt", "/dst/b.txt", "/dst/dir/c.txt"},
+			error:    ErrBatchIncomplete,
+		},
+
+		{
+			name:            "pending asynchronous operation",
+			opts:            BatchOptions{PollInterval: time.Millisecond, WaitTimeout: 20 * time.Millisecond},
+			asyncOperations: 1000000,
+
+			statuses: []BatchStatus{BatchSucceeded, BatchFailed, BatchPending, BatchSucceeded},
+			files:    []string{"/dst/a.txt", "/dst/b.txt", "/dst/dir/c.txt"},
+			error:    ErrBatchIncomplete,
+		},
+
+		{
+			name:            "failed asynchronous operation",
+			opts:            BatchOptions{PollInterval: time.Millisecond},
+			asyncOperations: 1,
+			failOperations:  true,
+
+			statuses: []BatchStatus{BatchSucceeded, BatchFailed, BatchFailed, BatchSucceeded},
+			files:    []string{"/dst/a.txt", "/dst/b.txt", "/dst/dir/c.txt"},
+			error:    ErrBatchIncomplete,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, disk := newBatchTestDisk()
+			disk.AsyncOperations = test.asyncOperations
+
+			if test.failOperations {
+				disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+					if !strings.Contains(r.URL.Path, "/operations/") {
+						return false
+					}
+
+					w.WriteHeader(http.StatusOK)
+					w.Write([]byte(`{"status":"failure"}`))
+					return true
+				}
+			}
+
+			opts := test.opts
+
+			report, err := client.CopyMany(context.Background(), pairs, &opts)
+			assert.Equal(t, test.error, err)
+			assert.Equal(t, test.statuses, batchStatuses(report))
+			assert.Equal(t, test.files, filesUnder(disk, "/dst/"))
+
+			for _, result := range report.WithStatus(BatchPending) {
+				assert.NotNil(t, result.Operation)
+			}
+			if test.failOperations {
+				assert.Equal(t, ErrOperationFailed, report.Results[2].Err)
+			}
+		})
+	}
+}
+
+// Paths of files with the given prefix.
+func filesUnder(disk *testhelpers.FakeDisk, prefix string) []string {
+	var files []string
+	for _, p := range disk.Files() {
+		if strings.HasPrefix(p, prefix) {
+			files = append(files, p)
+		}
+	}
+
+	return files
+}
+
+func TestClient_MoveMany(t *testing.T) {
+	client, disk := newBatchTestDisk()
+	disk.AsyncOperations = 1
+
+	report, err := client.MoveMany(context.Background(), []PathPair{
+		{Src: "/src/a.txt", Dst: "/dst/a.txt"},
+		{Src: "/src/dir", Dst: "/dst/dir"},
+	}, &BatchOptions{PollInterval: time.Millisecond})
+	assert.Nil(t, err)
+	assert.Equal(t, []BatchStatus{BatchSucceeded, BatchSucceeded}, batchStatuses(report))
+	assert.Equal(t, []string{"/dst/a.txt", "/dst/dir/c.txt", "/src/b.txt"}, disk.Files())
+}
+
+func TestClient_DeleteMany(t *testing.T) {
+	client, disk := newBatchTestDisk()
+
+	report, err := client.DeleteMany(context.Background(), []string{"/src/a.txt", "/src/missing.txt", "/src/dir"}, nil)
+	assert.Equal(t, ErrBatchIncomplete, err)
+	assert.Equal(t, []BatchStatus{BatchSucceeded, BatchFailed, BatchSucceeded}, batchStatuses(report))
+	assert.Equal(t, []string{"/src/b.txt"}, disk.Files())
+	assert.Equal(t, http.StatusNotFound, report.WithStatus(BatchFailed)[0].Err.(ApiError).StatusCode)
+}
+
+func TestClient_DeleteMany_Cancelled(t *testing.T) {
+	client, disk := newBatchTestDisk()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report, err := client.DeleteMany(ctx, []string{"/src/a.txt", "/src/b.txt"}, nil)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, []BatchStatus{BatchNotStarted, BatchNotStarted}, batchStatuses(report))
+	assert.Equal(t, []string{"/src/a.txt", "/src/b.txt", "/src/dir/c.txt"}, disk.Files())
+}
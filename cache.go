@@ -0,0 +1,260 @@
+package yadisk
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheTTL         = 30 * time.Second
+	defaultCacheNegativeTTL = 5 * time.Second
+
+	diskCacheKey = "disk"
+)
+
+// Options of the metadata cache.
+type CacheOptions struct {
+	// How long resource metadata and Disk stats are cached. 30 seconds by
+	// default.
+	TTL time.Duration
+
+	// How long "not found" results are cached. 5 seconds by default, negative
+	// value disables caching of such results.
+	NegativeTTL time.Duration
+}
+
+// Cache of resource metadata (GetResource) and Disk stats (GetDisk).
+//
+// Concurrent identical lookups are deduplicated: only one request is
+// performed and its result is shared, the request is canceled once all of
+// its callers have stopped waiting. Affected paths, their parents and
+// descendants are invalidated automatically by client's Copy, Move, Delete,
+// CreateDirectory and uploads.
+//
+// Cache is safe for concurrent use.
+type Cache struct {
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	group flightGroup
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+
+	// Incremented on every invalidation, so results of lookups started before
+	// invalidation are not cached
+	generation uint64
+
+	now func() time.Time
+}
+
+type cacheEntry struct {
+	path    string
+	value   interface{}
+	err     error
+	expires time.Time
+}
+
+// Create metadata cache. Use Client.SetCache to enable it.
+func NewCache(opts CacheOptions) *Cache {
+	if opts.TTL == 0 {
+		opts.TTL = defaultCacheTTL
+	}
+	if opts.NegativeTTL == 0 {
+		opts.NegativeTTL = defaultCacheNegativeTTL
+	}
+
+	return &Cache{
+		ttl:         opts.TTL,
+		negativeTTL: opts.NegativeTTL,
+		entries:     make(map[string]*cacheEntry),
+		now:         time.Now,
+	}
+}
+
+// Enable metadata cache for the client, nil disables it. The same cache
+// must not be shared by clients of different users.
+func (c *Client) SetCache(cache *Cache) {
+	c.cache = cache
+}
+
+// Invalidate cached metadata of the given paths, their parents and
+// descendants. Disk stats are invalidated as well.
+func (c *Cache) Invalidate(paths ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	delete(c.entries, diskCacheKey)
+
+	for _, p := range paths {
+		p = normalizePath(p)
+		parent := parentPath(p)
+
+		for key, entry := range c.entries {
+			if entry.path == p || entry.path == parent || isDescendant(entry.path, p) {
+				delete(c.entries, key)
+			}
+		}
+	}
+}
+
+// Remove all cached entries.
+func (c *Cache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	c.entries = make(map[string]*cacheEntry)
+}
+
+func (c *Cache) resource(ctx context.Context, p string, limit, offset int, fetch func(ctx context.Context) (*Resource, error)) (*Resource, error) {
+	p = normalizePath(p)
+	key := "resource:" + strconv.Itoa(limit) + ":" + strconv.Itoa(offset) + ":" + p
+
+	value, err := c.get(ctx, key, p, func(ctx context.Context) (interface{}, error) {
+		return fetch(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Callers must not be able to modify cached value
+	return cloneResource(value.(*Resource)), nil
+}
+
+func (c *Cache) disk(ctx context.Context, fetch func(ctx context.Context) (*Disk, error)) (*Disk, error) {
+	value, err := c.get(ctx, diskCacheKey, "", func(ctx context.Context) (interface{}, error) {
+		return fetch(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	disk := *value.(*Disk)
+	disk.SystemFolders = cloneStringMap(disk.SystemFolders)
+	return &disk, nil
+}
+
+// Lookup cached value or fetch it. Concurrent callers share one fetch (see
+// flightGroup), each caller stops waiting once its own context is done.
+func (c *Cache) get(ctx context.Context, key, p string, fetch func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	c.mu.Lock()
+	now := c.now()
+	if entry, ok := c.entries[key]; ok {
+		if now.Before(entry.expires) {
+			c.mu.Unlock()
+			return entry.value, entry.err
+		}
+		delete(c.entries, key)
+	}
+	generation := c.generation
+	c.mu.Unlock()
+
+	// Lookups started after invalidation must not join the stale ones
+	flightKey := strconv.FormatUint(generation, 10) + ":" + key
+
+	return c.group.do(ctx, flightKey, func(ctx context.Context) (interface{}, error) {
+		value, err := fetch(ctx)
+
+		ttl := c.ttl
+		if err != nil {
+			ttl = c.negativeTTL
+			if !isNotFound(err) || ttl < 0 {
+				return value, err
+			}
+		}
+
+		c.mu.Lock()
+		if c.generation == generation {
+			c.entries[key] = &cacheEntry{
+				path:    p,
+				value:   value,
+				err:     err,
+				expires: c.now().Add(ttl),
+			}
+		}
+		c.mu.Unlock()
+
+		return value, err
+	})
+}
+
+// Deep copy of the resource, so that its maps and slices aren't shared.
+func cloneResource(resource *Resource) *Resource {
+	clone := *resource
+	clone.CustomProperties = cloneStringMap(resource.CustomProperties)
+
+	if resource.Embedded.Items != nil {
+		clone.Embedded.Items = make([]Resource, len(resource.Embedded.Items))
+		for i := range resource.Embedded.Items {
+			clone.Embedded.Items[i] = *cloneResource(&resource.Embedded.Items[i])
+		}
+	}
+
+	return &clone
+}
+
+func cloneStringMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+
+	clone := make(map[string]string, len(m))
+	for k, v := range m {
+		clone[k] = v
+	}
+
+	return clone
+}
+
+func (c *Client) invalidateCache(paths ...string) {
+	if c.cache != nil {
+		c.cache.Invalidate(paths...)
+	}
+	if c.linkCache != nil {
+		c.linkCache.invalidate(paths...)
+	}
+}
+
+func isNotFound(err error) bool {
+	apiErr, ok := err.(ApiError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Normalize path to the form "/dir/file" or "app:/dir/file", so that
+// "disk:/dir/", "/dir" and "dir" are the same.
+func normalizePath(p string) string {
+	scheme := ""
+	if i := strings.Index(p, ":/"); i >= 0 {
+		scheme, p = p[:i+1], p[i+1:]
+	}
+	if scheme == "disk:" {
+		scheme = ""
+	}
+
+	return scheme + path.Clean("/"+p)
+}
+
+func parentPath(p string) string {
+	scheme := ""
+	if i := strings.Index(p, ":/"); i >= 0 {
+		scheme, p = p[:i+1], p[i+1:]
+	}
+
+	return scheme + path.Dir(p)
+}
+
+func isDescendant(p, ancestor string) bool {
+	if strings.HasSuffix(ancestor, "/") {
+		return strings.HasPrefix(p, ancestor) && p != ancestor
+	}
+
+	return strings.HasPrefix(p, ancestor+"/")
+}
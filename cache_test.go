@@ -0,0 +1,284 @@
+package yadisk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/go-yandex-disk/internal/testhelpers"
+)
+
+// Client with cache and fake API which counts metadata requests per path
+// (Disk stats requests are counted with empty path).
+// Paths starting with "/missing" don't exist.
+func newCachedTestClient(requests *sync.Map, delay time.Duration) (*Client, *Cache) {
+	client := New(testhelpers.NewTestClient(func(req *http.Request) *http.Response {
+		time.Sleep(delay)
+
+		p := req.URL.Query().Get("path")
+		isMetadata := req.Method == http.MethodGet && (req.URL.Path == "/v1/disk/resources" || req.URL.Path == "/v1/disk/")
+		if isMetadata {
+			counter, _ := requests.LoadOrStore(p, new(int32))
+			atomic.AddInt32(counter.(*int32), 1)
+		}
+
+		switch {
+		case !isMetadata:
+			return &http.Response{
+				StatusCode: 201,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"href":"some_href","method":"GET","templated":false}`)),
+			}
+		case strings.HasPrefix(p, "/missing"):
+			return &http.Response{
+				StatusCode: 404,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"error":"DiskNotFoundError"}`)),
+			}
+		case req.URL.Path == "/v1/disk/":
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"used_space":100}`)),
+			}
+		default:
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"path":"disk:` + p + `","type":"file","custom_properties":{"key":"value"}}`)),
+			}
+		}
+	}))
+
+	cache := NewCache(CacheOptions{TTL: time.Minute, NegativeTTL: time.Second})
+	client.SetCache(cache)
+
+	return client, cache
+}
+
+func requestCount(requests *sync.Map, p string) int32 {
+	counter, ok := requests.Load(p)
+	if !ok {
+		return 0
+	}
+	return atomic.LoadInt32(counter.(*int32))
+}
+
+func TestCache_deduplicatesLookups(t *testing.T) {
+	var requests sync.Map
+	client, _ := newCachedTestClient(&requests, 50*time.Millisecond)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resource, err := client.GetResource(context.Background(), "/dir/file", 0, 0)
+			assert.Nil(t, err)
+			assert.Equal(t, "disk:/dir/file", resource.Path)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), requestCount(&requests, "/dir/file"))
+
+	// Subsequent lookups are served from cache
+	_, err := client.GetResource(context.Background(), "/dir/file", 0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), requestCount(&requests, "/dir/file"))
+}
+
+func TestCache_canceledLookup(t *testing.T) {
+	var requests sync.Map
+	client, _ := newCachedTestClient(&requests, 100*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		// Joins the lookup of the canceled caller
+		time.Sleep(10 * time.Millisecond)
+		resource, err := client.GetResource(context.Background(), "/dir/file", 0, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, "disk:/dir/file", resource.Path)
+	}()
+
+	_, err := client.GetResource(ctx, "/dir/file", 0, 0)
+	assert.Equal(t, context.Canceled, err)
+
+	wg.Wait()
+	assert.Equal(t, int32(1), requestCount(&requests, "/dir/file"))
+}
+
+func TestCache_valuesAreCopied(t *testing.T) {
+	var requests sync.Map
+	client, _ := newCachedTestClient(&requests, 0)
+
+	resource, err := client.GetResource(context.Background(), "/dir/file", 0, 0)
+	assert.Nil(t, err)
+	resource.CustomProperties["key"] = "modified"
+
+	resource, err = client.GetResource(context.Background(), "/dir/file", 0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "value", resource.CustomProperties["key"])
+	assert.Equal(t, int32(1), requestCount(&requests, "/dir/file"))
+}
+
+func TestCache_expiration(t *testing.T) {
+	var requests sync.Map
+	client, cache := newCachedTestClient(&requests, 0)
+
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		_, err := client.GetResource(context.Background(), "/dir/file", 0, 0)
+		assert.Nil(t, err)
+
+		_, err = client.GetResource(context.Background(), "/missing", 0, 0)
+		assert.Equal(t, ApiError{StatusCode: 404, ErrorID: "DiskNotFoundError"}, err)
+	}
+
+	assert.Equal(t, int32(1), requestCount(&requests, "/dir/file"))
+	assert.Equal(t, int32(1), requestCount(&requests, "/missing"))
+
+	// Negative results expire sooner
+	now = now.Add(2 * time.Second)
+
+	client.GetResource(context.Background(), "/dir/file", 0, 0)
+	client.GetResource(context.Background(), "/missing", 0, 0)
+
+	assert.Equal(t, int32(1), requestCount(&requests, "/dir/file"))
+	assert.Equal(t, int32(2), requestCount(&requests, "/missing"))
+
+	now = now.Add(time.Minute)
+
+	client.GetResource(context.Background(), "/dir/file", 0, 0)
+	assert.Equal(t, int32(2), requestCount(&requests, "/dir/file"))
+}
+
+func TestCache_invalidationOnMutation(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		mutate func(client *Client)
+
+		invalidated []string
+		kept        []string
+	}{
+		{
+			name:   "copy",
+			mutate: func(client *Client) { client.Copy(ctx, "/a/file", "/b/file", false) },
+
+			invalidated: []string{"/b/file", "/b"},
+			kept:        []string{"/a/file", "/a"},
+		},
+		{
+			name:   "move",
+			mutate: func(client *Client) { client.Move(ctx, "/a/file", "/b/file", false) },
+
+			invalidated: []string{"/a/file", "/a", "/b/file", "/b"},
+		},
+		{
+			name:   "delete directory",
+			mutate: func(client *Client) { client.Delete(ctx, "disk:/a", false) },
+
+			invalidated: []string{"/a/file", "/a", "/"},
+			kept:        []string{"/b/file", "/b"},
+		},
+		{
+			name:   "create directory",
+			mutate: func(client *Client) { client.CreateDirectory(ctx, "/b/new") },
+
+			invalidated: []string{"/b"},
+			kept:        []string{"/b/file", "/a"},
+		},
+		{
+			name: "upload",
+			mutate: func(client *Client) {
+				link, _ := client.RequestUploadLink(ctx, "/a/file", true)
+				client.Upload(ctx, link, strings.NewReader(""))
+			},
+
+			invalidated: []string{"/a/file", "/a"},
+			kept:        []string{"/b/file", "/b"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests sync.Map
+			client, _ := newCachedTestClient(&requests, 0)
+
+			paths := []string{"/", "/a", "/a/file", "/b", "/b/file"}
+			for _, p := range paths {
+				client.GetResource(ctx, p, 0, 0)
+			}
+			client.GetDisk(ctx)
+
+			test.mutate(client)
+
+			for _, p := range paths {
+				client.GetResource(ctx, p, 0, 0)
+			}
+			client.GetDisk(ctx)
+
+			for _, p := range test.invalidated {
+				assert.Equal(t, int32(2), requestCount(&requests, p), p)
+			}
+			for _, p := range test.kept {
+				assert.Equal(t, int32(1), requestCount(&requests, p), p)
+			}
+
+			// Disk stats are always invalidated
+			assert.Equal(t, int32(2), requestCount(&requests, ""))
+		})
+	}
+}
+
+func TestCache_invalidationOnAsyncOperation(t *testing.T) {
+	ctx := context.Background()
+
+	client, disk := newFakeDiskClient()
+	client.SetCache(NewCache(CacheOptions{}))
+	disk.AsyncOperations = 1
+	disk.PutFile("/a/dir/file", []byte("FILE"))
+	disk.PutDir("/b")
+
+	link, statusCode, err := client.Move(ctx, "/a/dir", "/b/dir", false)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusAccepted, statusCode)
+
+	// Metadata is cached while operation is in progress
+	since := len(disk.Requests)
+	client.GetResource(ctx, "/b/dir", 0, 0)
+	client.GetResource(ctx, "/b/dir", 0, 0)
+	assert.Equal(t, 1, countRequests(disk, since, "GET /v1/disk/resources"))
+
+	assert.Nil(t, client.WaitOperation(ctx, link, time.Millisecond))
+
+	client.GetResource(ctx, "/b/dir", 0, 0)
+	assert.Equal(t, 2, countRequests(disk, since, "GET /v1/disk/resources"))
+}
+
+func TestNormalizePath(t *testing.T) {
+	tests := map[string]string{
+		"disk:/dir/file": "/dir/file",
+		"/dir/file/":     "/dir/file",
+		"dir/file":       "/dir/file",
+		"disk:/":         "/",
+		"app:/file":      "app:/file",
+	}
+	for p, expected := range tests {
+		assert.Equal(t, expected, normalizePath(p), p)
+	}
+}
@@ -7,8 +7,12 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"os"
+	"sync"
+	"time"
 
 	"golang.org/x/oauth2"
+	"golang.org/x/time/rate"
 )
 
 const (
@@ -18,6 +22,18 @@ const (
 type Client struct {
 	client  *http.Client
 	baseUrl *url.URL
+
+	cache     *Cache
+	linkCache *linkCache
+
+	bandwidthMu     sync.RWMutex
+	uploadLimiter   *rate.Limiter
+	downloadLimiter *rate.Limiter
+
+	// Shares concurrent MkdirAll requests
+	mkdirs flightGroup
+
+	now func() time.Time
 }
 
 func New(client *http.Client) *Client {
@@ -26,6 +42,7 @@ func New(client *http.Client) *Client {
 	return &Client{
 		client:  client,
 		baseUrl: baseUrl,
+		now:     time.Now,
 	}
 }
 
@@ -60,6 +77,15 @@ func (c *Client) doRawRequest(
 	ctx context.Context,
 	method, absoluteUrl string,
 	bodyReader io.Reader,
+) (*http.Response, error) {
+	return c.doRawRequestWithHeaders(ctx, method, absoluteUrl, bodyReader, nil)
+}
+
+func (c *Client) doRawRequestWithHeaders(
+	ctx context.Context,
+	method, absoluteUrl string,
+	bodyReader io.Reader,
+	header http.Header,
 ) (*http.Response, error) {
 	req, err := http.NewRequest(method, absoluteUrl, bodyReader)
 	if err != nil {
@@ -68,7 +94,49 @@ func (c *Client) doRawRequest(
 
 	req = req.WithContext(ctx)
 
-	return c.client.Do(req)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	// http.NewRequest determines length of in-memory readers only, let's
+	// avoid chunked encoding for files as well
+	if bodyReader != nil && req.ContentLength == 0 {
+		if n := fileLength(bodyReader); n > 0 {
+			req.ContentLength = n
+		} else if n == 0 {
+			req.Body = http.NoBody
+		}
+	}
+
+	hashRequest(ctx, req)
+	throttleResponse := c.throttleRequest(ctx, req)
+	trackResponse := c.trackRequestProgress(ctx, req)
+
+	resp, err := c.client.Do(req)
+	throttleResponse(resp)
+	trackResponse(resp)
+
+	return resp, err
+}
+
+// Remaining length of the file or -1 if r is not a file.
+func fileLength(r io.Reader) int64 {
+	f, ok := r.(*os.File)
+	if !ok {
+		return -1
+	}
+
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return -1
+	}
+
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return -1
+	}
+
+	return info.Size() - offset
 }
 
 func (c *Client) doRequest(
@@ -0,0 +1,344 @@
+// Package crypt encrypts files on client side before they're uploaded to
+// Yandex.Disk and decrypts them on download.
+//
+// Content is encrypted with chunked AES-256-GCM, so files of any size are
+// encrypted and decrypted as streams and any modification of stored content
+// is detected on download. Names of files and directories could be encrypted
+// too, deterministically, so encrypted paths could be looked up.
+//
+// Keys are provided by Keyring, PassphraseKeyring derives them from
+// a passphrase.
+package crypt
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/yurykabanov/go-yandex-disk"
+	"github.com/yurykabanov/go-yandex-disk/internal/atomicfile"
+)
+
+// Options of encrypting client.
+type Options struct {
+	// Directory where encrypted files are stored. Paths passed to the client
+	// are relative to it, the path of the directory itself is not encrypted.
+	// Disk root by default.
+	Root string
+
+	// Encrypt names of files and directories.
+	EncryptNames bool
+
+	// Size of plaintext chunk, DefaultChunkSize by default. Chunk size of
+	// encrypted content is stored in its header.
+	ChunkSize int
+}
+
+// Client encrypting content uploaded by the wrapped client and decrypting
+// downloaded one.
+type Client struct {
+	client  *yadisk.Client
+	keyring Keyring
+	names   *nameCipher
+	root    string
+	opts    Options
+}
+
+// Create encrypting client.
+//
+// client - Client of Yandex.Disk.
+// keyring - Source of encryption keys.
+// opts - Options, nil means default options.
+func New(client *yadisk.Client, keyring Keyring, opts *Options) (*Client, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	c := &Client{
+		client:  client,
+		keyring: keyring,
+		root:    path.Clean("/" + opts.Root),
+		opts:    *opts,
+	}
+
+	if opts.EncryptNames {
+		key, err := keyring.NameKey()
+		if err != nil {
+			return nil, err
+		}
+
+		c.names, err = newNameCipher(key)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// Remote path of the given path: the path relative to Root with encrypted
+// names if names are encrypted.
+func (c *Client) RemotePath(p string) (string, error) {
+	p = path.Clean("/" + p)
+	if p == "/" {
+		return c.root, nil
+	}
+
+	parts := strings.Split(p[1:], "/")
+	if c.names != nil {
+		for i, name := range parts {
+			encrypted, err := c.names.encrypt(name)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = encrypted
+		}
+	}
+
+	return path.Join(c.root, path.Join(parts...)), nil
+}
+
+// Decrypt name of remote file or directory. Name is returned as is if names
+// are not encrypted.
+func (c *Client) DecryptName(name string) (string, error) {
+	if c.names == nil {
+		return name, nil
+	}
+
+	return c.names.decrypt(name)
+}
+
+// List directory. Names and paths of returned resources are decrypted, paths
+// are relative to Root. Sizes are sizes of encrypted content.
+//
+// Resources whose names could not be decrypted are skipped.
+func (c *Client) ReadDir(ctx context.Context, dir string) ([]yadisk.Resource, error) {
+	remoteDir, err := c.RemotePath(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := c.client.ListDirectory(ctx, remoteDir)
+	if err != nil {
+		if errors.Is(err, yadisk.ErrNotDirectory) {
+			return nil, yadisk.NotDirectoryError{Path: dir}
+		}
+		return nil, err
+	}
+
+	var items []yadisk.Resource
+	for _, item := range resources {
+		name, err := c.DecryptName(item.Name)
+		if err != nil {
+			continue
+		}
+
+		item.Name = name
+		item.Path = path.Join("/", dir, name)
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// Request link to upload encrypted file, see yadisk.Client.RequestUploadLink.
+//
+// path - The path relative to Root.
+func (c *Client) RequestUploadLink(ctx context.Context, path string, overwrite bool) (*yadisk.Link, error) {
+	remotePath, err := c.RemotePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.client.RequestUploadLink(ctx, remotePath, overwrite)
+}
+
+// Encrypt content and upload it, see yadisk.Client.Upload.
+//
+// link - Link requested with RequestUploadLink.
+// r - io.Reader of plaintext content.
+func (c *Client) Upload(ctx context.Context, link *yadisk.Link, r io.Reader) (int, error) {
+	er, err := c.encryptingReader(r)
+	if err != nil {
+		return 0, err
+	}
+	defer er.Close()
+
+	return c.client.Upload(ctx, link, er)
+}
+
+// Request link to download encrypted file, see
+// yadisk.Client.RequestDownloadLink.
+//
+// path - The path relative to Root.
+func (c *Client) RequestDownloadLink(ctx context.Context, path string) (*yadisk.Link, error) {
+	remotePath, err := c.RemotePath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.client.RequestDownloadLink(ctx, remotePath)
+}
+
+// Download file and decrypt it, see yadisk.Client.Download.
+//
+// link - Link requested with RequestDownloadLink.
+//
+// Body of successful response is decrypted while it's read. Reading ends with
+// ErrAuthentication instead of io.EOF if stored content has been modified.
+func (c *Client) Download(ctx context.Context, link *yadisk.Link) (*http.Response, error) {
+	resp, err := c.client.Download(ctx, link)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	resp.Body = &decryptingBody{Reader: NewReader(resp.Body, c.keyring), body: resp.Body}
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+
+	return resp, nil
+}
+
+// Encrypt local file and upload it, see yadisk.Client.UploadFile.
+//
+// remotePath - The path relative to Root.
+func (c *Client) UploadFile(ctx context.Context, localPath, remotePath string, opts *yadisk.UploadOptions) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return c.UploadReader(ctx, f, remotePath, opts)
+}
+
+// Encrypt content of the reader and upload it, see
+// yadisk.Client.UploadReader.
+//
+// remotePath - The path relative to Root.
+func (c *Client) UploadReader(ctx context.Context, r io.Reader, remotePath string, opts *yadisk.UploadOptions) error {
+	encryptedPath, err := c.RemotePath(remotePath)
+	if err != nil {
+		return err
+	}
+
+	er, err := c.encryptingReader(r)
+	if err != nil {
+		return err
+	}
+	defer er.Close()
+
+	return c.client.UploadReader(ctx, er, encryptedPath, opts)
+}
+
+// Download file, decrypt it and write its content to w.
+//
+// remotePath - The path relative to Root.
+//
+// Content is written as soon as it's authenticated chunk by chunk, so w could
+// receive a part of content before ErrAuthentication is returned. Use
+// DownloadFile to never get partial content.
+func (c *Client) DownloadTo(ctx context.Context, remotePath string, w io.Writer) (int64, error) {
+	link, err := c.RequestDownloadLink(ctx, remotePath)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.Download(ctx, link)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return 0, yadisk.TransferError{StatusCode: resp.StatusCode}
+	}
+
+	return io.Copy(w, resp.Body)
+}
+
+// Download file and decrypt it to the given local path.
+//
+// remotePath - The path relative to Root.
+// localPath - The path to the local file.
+// overwrite - What to do if local file already exists.
+//
+// Content is written to a temporary file which replaces the local file only
+// once the whole content is authenticated.
+func (c *Client) DownloadFile(ctx context.Context, remotePath, localPath string, overwrite yadisk.OverwritePolicy) error {
+	if _, err := os.Lstat(localPath); err == nil {
+		switch overwrite {
+		case yadisk.OverwriteNever:
+			return yadisk.ErrDestinationExists
+		case yadisk.OverwriteSkip:
+			return nil
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	return atomicfile.Write(localPath, nil, func(w io.Writer) error {
+		_, err := c.DownloadTo(ctx, remotePath, w)
+		return err
+	})
+}
+
+// Encrypted content of the reader. It's encrypted in background once it's
+// read for the first time, so the source isn't consumed if upload fails
+// before content is sent. Close stops encryption, it doesn't wait for the
+// source which has no data yet.
+type encryptedReader struct {
+	pr    *io.PipeReader
+	start sync.Once
+
+	encrypt func()
+}
+
+func (c *Client) encryptingReader(r io.Reader) (*encryptedReader, error) {
+	pr, pw := io.Pipe()
+
+	w, err := NewWriter(pw, c.keyring, c.opts.ChunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypt := func() {
+		_, err := io.Copy(w, r)
+		if err == nil {
+			err = w.Close()
+		}
+		pw.CloseWithError(err)
+	}
+
+	return &encryptedReader{pr: pr, encrypt: encrypt}, nil
+}
+
+func (r *encryptedReader) Read(p []byte) (int, error) {
+	r.start.Do(func() { go r.encrypt() })
+
+	return r.pr.Read(p)
+}
+
+func (r *encryptedReader) Close() error {
+	// Encryption is never started after Close
+	r.start.Do(func() {})
+
+	// Encrypting goroutine stops at its next write
+	return r.pr.Close()
+}
+
+type decryptingBody struct {
+	*Reader
+	body io.Closer
+}
+
+func (b *decryptingBody) Close() error {
+	return b.body.Close()
+}
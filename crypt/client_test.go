@@ -0,0 +1,210 @@
+package crypt
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/go-yandex-disk"
+	"github.com/yurykabanov/go-yandex-disk/internal/testhelpers"
+)
+
+func newTestClient(t *testing.T, opts *Options) (*Client, *testhelpers.FakeDisk) {
+	disk := testhelpers.NewFakeDisk()
+
+	c, err := New(yadisk.New(disk.Client()), newTestKeyring("new"), opts)
+	assert.Nil(t, err)
+
+	return c, disk
+}
+
+func TestClient_RemotePath(t *testing.T) {
+	plain, _ := newTestClient(t, &Options{Root: "encrypted"})
+	encrypted, _ := newTestClient(t, &Options{Root: "/encrypted/", EncryptNames: true})
+
+	p, err := plain.RemotePath("docs/a.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "/encrypted/docs/a.txt", p)
+
+	p, err = encrypted.RemotePath("/")
+	assert.Nil(t, err)
+	assert.Equal(t, "/encrypted", p)
+
+	p, err = encrypted.RemotePath("/docs/a.txt")
+	assert.Nil(t, err)
+
+	parts := strings.Split(p, "/")
+	assert.Len(t, parts, 4)
+	assert.Equal(t, "encrypted", parts[1])
+	assert.NotContains(t, p, "docs")
+	assert.NotContains(t, p, "a.txt")
+
+	// Names are encrypted deterministically
+	same, _ := encrypted.RemotePath("/docs/b.txt")
+	assert.Equal(t, parts[2], strings.Split(same, "/")[2])
+
+	name, err := encrypted.DecryptName(parts[3])
+	assert.Nil(t, err)
+	assert.Equal(t, "a.txt", name)
+
+	_, err = encrypted.DecryptName(strings.ToUpper(parts[3]))
+	assert.Nil(t, err)
+
+	_, err = encrypted.DecryptName("a.txt")
+	assert.Equal(t, ErrInvalidName, err)
+
+	_, err = encrypted.DecryptName(parts[3][:len(parts[3])-1] + "0")
+	assert.Equal(t, ErrInvalidName, err)
+
+	_, err = encrypted.RemotePath(strings.Repeat("x", 143))
+	assert.Nil(t, err)
+	_, err = encrypted.RemotePath(strings.Repeat("x", 144))
+	assert.Equal(t, ErrNameTooLong, err)
+}
+
+func TestClient_UploadDownload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yadisk-crypt-test")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	c, disk := newTestClient(t, &Options{Root: "/encrypted", EncryptNames: true, ChunkSize: 16})
+	ctx := context.Background()
+
+	content := []byte(strings.Repeat("secret content ", 10))
+	localPath := filepath.Join(dir, "local.txt")
+	assert.Nil(t, ioutil.WriteFile(localPath, content, 0644))
+
+	docs, _ := c.RemotePath("/docs")
+	disk.PutDir(docs)
+
+	assert.Nil(t, c.UploadFile(ctx, localPath, "/docs/a.txt", nil))
+
+	// Upload fails on conflict, encrypting goroutine is stopped
+	err = c.UploadFile(ctx, localPath, "/docs/a.txt", nil)
+	assert.Equal(t, http.StatusConflict, err.(yadisk.ApiError).StatusCode)
+	assert.Nil(t, c.UploadFile(ctx, localPath, "/docs/a.txt", &yadisk.UploadOptions{Overwrite: yadisk.OverwriteSkip}))
+
+	link, err := c.RequestUploadLink(ctx, "/docs/b.txt", false)
+	assert.Nil(t, err)
+	statusCode, err := c.Upload(ctx, link, strings.NewReader("B"))
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusCreated, statusCode)
+
+	remotePath, _ := c.RemotePath("/docs/a.txt")
+	stored := disk.File(remotePath).Content
+	assert.False(t, bytes.Contains(stored, []byte("secret")))
+
+	items, err := c.ReadDir(ctx, "/docs")
+	assert.Nil(t, err)
+	sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	assert.Len(t, items, 2)
+	assert.Equal(t, "a.txt", items[0].Name)
+	assert.Equal(t, "/docs/a.txt", items[0].Path)
+	assert.Equal(t, "b.txt", items[1].Name)
+
+	buf := &bytes.Buffer{}
+	n, err := c.DownloadTo(ctx, "/docs/a.txt", buf)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(len(content)), n)
+	assert.Equal(t, content, buf.Bytes())
+
+	downloadPath := filepath.Join(dir, "downloaded.txt")
+	assert.Nil(t, c.DownloadFile(ctx, "/docs/a.txt", downloadPath, yadisk.OverwriteNever))
+	downloaded, _ := ioutil.ReadFile(downloadPath)
+	assert.Equal(t, content, downloaded)
+
+	assert.Equal(t, yadisk.ErrDestinationExists, c.DownloadFile(ctx, "/docs/a.txt", downloadPath, yadisk.OverwriteNever))
+
+	// Tampered content never replaces local file
+	stored[len(stored)-1] ^= 1
+	disk.PutFile(remotePath, stored)
+
+	err = c.DownloadFile(ctx, "/docs/a.txt", downloadPath, yadisk.OverwriteAlways)
+	assert.Equal(t, ErrAuthentication, err)
+	downloaded, _ = ioutil.ReadFile(downloadPath)
+	assert.Equal(t, content, downloaded)
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 2)
+
+	_, err = c.DownloadTo(ctx, "/docs/missing.txt", ioutil.Discard)
+	assert.Equal(t, http.StatusNotFound, err.(yadisk.ApiError).StatusCode)
+}
+
+func TestClient_UploadReader_BlockingSource(t *testing.T) {
+	c, _ := newTestClient(t, nil)
+	ctx := context.Background()
+
+	assert.Nil(t, c.UploadReader(ctx, strings.NewReader("OLD"), "/file.txt", nil))
+
+	tests := []struct {
+		name      string
+		overwrite yadisk.OverwritePolicy
+	}{
+		{name: "conflict", overwrite: yadisk.OverwriteNever},
+		{name: "skip", overwrite: yadisk.OverwriteSkip},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Source which has no data yet, like stdin
+			pr, pw := io.Pipe()
+			defer pw.Close()
+
+			done := make(chan error)
+			go func() {
+				done <- c.UploadReader(ctx, pr, "/file.txt", &yadisk.UploadOptions{Overwrite: test.overwrite})
+			}()
+
+			select {
+			case err := <-done:
+				if test.overwrite == yadisk.OverwriteSkip {
+					assert.Nil(t, err)
+				} else {
+					assert.NotNil(t, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("upload hasn't returned")
+			}
+
+			// Source hasn't been consumed
+			go pw.Write([]byte("NEW"))
+			buf := make([]byte, 3)
+			_, err := io.ReadFull(pr, buf)
+			assert.Nil(t, err)
+			assert.Equal(t, "NEW", string(buf))
+		})
+	}
+}
+
+func TestPassphraseKeyring(t *testing.T) {
+	keyring, err := NewPassphraseKeyring("passphrase", []byte("salt"))
+	assert.Nil(t, err)
+
+	id, key, err := keyring.ContentKey()
+	assert.Nil(t, err)
+	assert.Len(t, key, 32)
+	assert.NotContains(t, id, "passphrase")
+
+	same, _ := NewPassphraseKeyring("passphrase", []byte("salt"))
+	encrypted := encrypt(t, keyring, []byte("content"), 0)
+	decrypted, err := ioutil.ReadAll(NewReader(bytes.NewReader(encrypted), same))
+	assert.Nil(t, err)
+	assert.Equal(t, "content", string(decrypted))
+
+	other, _ := NewPassphraseKeyring("other", []byte("salt"))
+	_, err = ioutil.ReadAll(NewReader(bytes.NewReader(encrypted), other))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	_, err = NewPassphraseKeyring("", []byte("salt"))
+	assert.NotNil(t, err)
+}
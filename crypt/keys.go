@@ -0,0 +1,86 @@
+package crypt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrKeyNotFound is returned when content has been encrypted with a key
+// unknown to the keyring.
+var ErrKeyNotFound = errors.New("crypt: key not found")
+
+// Source of encryption keys. Keys must be at least 16 bytes long.
+type Keyring interface {
+	// Key used to encrypt new content along with its ID. ID is stored in
+	// the header of encrypted content as is, so it must not reveal the key.
+	ContentKey() (id string, key []byte, err error)
+
+	// Key with the given ID used to decrypt content. ErrKeyNotFound should
+	// be returned if the key is unknown.
+	ContentKeyByID(id string) ([]byte, error)
+
+	// Key of deterministic name encryption. It must not change, otherwise
+	// previously encrypted paths could not be found.
+	NameKey() ([]byte, error)
+}
+
+// Parameters of scrypt used by PassphraseKeyring.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// Keyring with keys derived from a passphrase with scrypt.
+type PassphraseKeyring struct {
+	id      string
+	content []byte
+	names   []byte
+}
+
+// Derive keys from the passphrase.
+//
+// passphrase - Secret passphrase.
+// salt - Salt of key derivation. It's not secret, but the same salt must be
+// used to decrypt content, so it should be stored along with the application
+// settings.
+func NewPassphraseKeyring(passphrase string, salt []byte) (*PassphraseKeyring, error) {
+	if passphrase == "" {
+		return nil, errors.New("crypt: empty passphrase")
+	}
+	if len(salt) == 0 {
+		return nil, errors.New("crypt: empty salt")
+	}
+
+	keys, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(append([]byte("yadisk-crypt key id\x00"), keys[:32]...))
+
+	return &PassphraseKeyring{
+		id:      hex.EncodeToString(sum[:8]),
+		content: keys[:32],
+		names:   keys[32:],
+	}, nil
+}
+
+func (k *PassphraseKeyring) ContentKey() (string, []byte, error) {
+	return k.id, k.content, nil
+}
+
+func (k *PassphraseKeyring) ContentKeyByID(id string) ([]byte, error) {
+	if id != k.id {
+		return nil, ErrKeyNotFound
+	}
+
+	return k.content, nil
+}
+
+func (k *PassphraseKeyring) NameKey() ([]byte, error) {
+	return k.names, nil
+}
@@ -0,0 +1,96 @@
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Yandex.Disk limits length of a name.
+const maxNameLength = 255
+
+var (
+	// ErrNameTooLong is returned when encrypted name exceeds the limit of
+	// Yandex.Disk. Names up to 143 bytes could always be encrypted.
+	ErrNameTooLong = errors.New("crypt: encrypted name is too long")
+
+	// ErrInvalidName is returned when name could not be decrypted.
+	ErrInvalidName = errors.New("crypt: invalid encrypted name")
+)
+
+// Lower case base32 without padding, so encrypted names don't depend on case
+// sensitivity of file systems.
+var nameEncoding = base32.NewEncoding("0123456789abcdefghijklmnopqrstuv").WithPadding(base32.NoPadding)
+
+// Deterministic name encryption in the style of SIV: synthetic IV is HMAC of
+// the name, the name is encrypted with AES-CTR using that IV. The same name
+// is always encrypted the same way, so paths could be looked up, and the IV
+// authenticates the name on decryption.
+type nameCipher struct {
+	mac   []byte
+	block cipher.Block
+}
+
+func newNameCipher(key []byte) (*nameCipher, error) {
+	if len(key) < minKeySize {
+		return nil, ErrKeySize
+	}
+
+	keys := make([]byte, 64)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, nil, []byte("yadisk-crypt names v1")), keys); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(keys[32:])
+	if err != nil {
+		return nil, err
+	}
+
+	return &nameCipher{mac: keys[:32], block: block}, nil
+}
+
+func (c *nameCipher) syntheticIV(name []byte) []byte {
+	mac := hmac.New(sha256.New, c.mac)
+	mac.Write(name)
+
+	return mac.Sum(nil)[:aes.BlockSize]
+}
+
+func (c *nameCipher) encrypt(name string) (string, error) {
+	iv := c.syntheticIV([]byte(name))
+
+	data := make([]byte, len(iv)+len(name))
+	copy(data, iv)
+	cipher.NewCTR(c.block, iv).XORKeyStream(data[len(iv):], []byte(name))
+
+	encrypted := nameEncoding.EncodeToString(data)
+	if len(encrypted) > maxNameLength {
+		return "", ErrNameTooLong
+	}
+
+	return encrypted, nil
+}
+
+func (c *nameCipher) decrypt(encrypted string) (string, error) {
+	data, err := nameEncoding.DecodeString(strings.ToLower(encrypted))
+	if err != nil || len(data) <= aes.BlockSize {
+		return "", ErrInvalidName
+	}
+
+	iv := data[:aes.BlockSize]
+	name := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCTR(c.block, iv).XORKeyStream(name, data[aes.BlockSize:])
+
+	if !hmac.Equal(iv, c.syntheticIV(name)) {
+		return "", ErrInvalidName
+	}
+
+	return string(name), nil
+}
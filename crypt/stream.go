@@ -0,0 +1,336 @@
+package crypt
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Encrypted stream consists of a header followed by chunks sealed with
+// AES-256-GCM:
+//
+//	magic "YDCRYPT" | version (1 byte) | chunk size (uint32) |
+//	key ID length (1 byte) | key ID | salt (32 bytes)
+//
+// Each file is encrypted with its own key derived from the content key and
+// the random salt (HKDF-SHA256). Nonce of a chunk is its index with a flag
+// marking the last chunk, the header is authenticated with every chunk, so
+// reordered, truncated or extended streams are detected.
+const (
+	magic    = "YDCRYPT"
+	version1 = 1
+
+	// Default size of plaintext chunk.
+	DefaultChunkSize = 64 << 10
+
+	maxChunkSize = 16 << 20
+	saltSize     = 32
+	tagSize      = 16
+	maxKeyID     = 255
+	minKeySize   = 16
+)
+
+var (
+	// ErrAuthentication is returned by decryption when content has been
+	// tampered with, truncated or encrypted with another key.
+	ErrAuthentication = errors.New("crypt: message authentication failed")
+
+	// ErrFormat is returned when content is not an encrypted stream.
+	ErrFormat = errors.New("crypt: not an encrypted stream")
+
+	// ErrVersion is returned when stream has been encrypted with unsupported
+	// format version.
+	ErrVersion = errors.New("crypt: unsupported format version")
+
+	// ErrKeySize is returned when key provided by Keyring is too short.
+	ErrKeySize = errors.New("crypt: key is too short")
+
+	errClosed = errors.New("crypt: write to closed writer")
+)
+
+// Writer encrypts content written to it. It must be closed to write the last
+// chunk, Close doesn't close the underlying writer.
+type Writer struct {
+	w         io.Writer
+	aead      cipher.AEAD
+	header    []byte
+	chunkSize int
+
+	headerWritten bool
+	buf           []byte
+	out           []byte
+	counter       uint64
+	err           error
+}
+
+// Create writer encrypting content with the current content key of the
+// keyring.
+//
+// chunkSize - Size of plaintext chunk, zero means DefaultChunkSize.
+func NewWriter(w io.Writer, keyring Keyring, chunkSize int) (*Writer, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	if chunkSize > maxChunkSize {
+		return nil, errors.New("crypt: chunk size is too large")
+	}
+
+	id, key, err := keyring.ContentKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > maxKeyID {
+		return nil, errors.New("crypt: key ID is too long")
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(magic)+1+4+1+len(id)+saltSize)
+	header = append(header, magic...)
+	header = append(header, version1)
+	header = append(header, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(header[len(header)-4:], uint32(chunkSize))
+	header = append(header, byte(len(id)))
+	header = append(header, id...)
+	header = append(header, salt...)
+
+	aead, err := fileCipher(key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		w:         w,
+		aead:      aead,
+		header:    header,
+		chunkSize: chunkSize,
+		buf:       make([]byte, 0, chunkSize),
+	}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	n := 0
+	for len(p) > 0 {
+		// Full chunk is sealed only when more data arrives because the last
+		// chunk is sealed differently
+		if len(w.buf) == w.chunkSize {
+			if err := w.flush(false); err != nil {
+				w.err = err
+				return n, err
+			}
+		}
+
+		k := copy(w.buf[len(w.buf):w.chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+k]
+		p = p[k:]
+		n += k
+	}
+
+	return n, nil
+}
+
+// Write the last chunk. Writer could not be used after it's closed.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		if w.err == errClosed {
+			return nil
+		}
+		return w.err
+	}
+
+	if err := w.flush(true); err != nil {
+		w.err = err
+		return err
+	}
+
+	w.err = errClosed
+	return nil
+}
+
+func (w *Writer) flush(last bool) error {
+	if !w.headerWritten {
+		if _, err := w.w.Write(w.header); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+
+	w.out = w.aead.Seal(w.out[:0], chunkNonce(w.counter, last), w.buf, w.header)
+	w.buf = w.buf[:0]
+	w.counter++
+
+	_, err := w.w.Write(w.out)
+	return err
+}
+
+// Reader decrypts content of encrypted stream. Reading ends with
+// ErrAuthentication instead of io.EOF if stream has been tampered with.
+//
+// Content is returned chunk by chunk once every chunk is authenticated, but
+// stream is known to be complete only when io.EOF is returned.
+type Reader struct {
+	r       *bufio.Reader
+	keyring Keyring
+
+	aead    cipher.AEAD
+	header  []byte
+	chunk   []byte
+	plain   []byte
+	counter uint64
+	last    bool
+	err     error
+}
+
+// Create reader decrypting content with key found in the keyring by ID stored
+// in the header. Header is read on the first Read.
+func NewReader(r io.Reader, keyring Keyring) *Reader {
+	return &Reader{r: bufio.NewReader(r), keyring: keyring}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.last {
+			r.err = io.EOF
+			continue
+		}
+
+		if r.aead == nil {
+			r.err = r.readHeader()
+		} else {
+			r.err = r.readChunk()
+		}
+	}
+
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+
+	return n, nil
+}
+
+func (r *Reader) readHeader() error {
+	fixed := make([]byte, len(magic)+1+4+1)
+	if _, err := io.ReadFull(r.r, fixed); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrFormat
+		}
+		return err
+	}
+
+	if string(fixed[:len(magic)]) != magic {
+		return ErrFormat
+	}
+	if fixed[len(magic)] != version1 {
+		return ErrVersion
+	}
+
+	chunkSize := binary.BigEndian.Uint32(fixed[len(magic)+1:])
+	if chunkSize == 0 || chunkSize > maxChunkSize {
+		return ErrFormat
+	}
+
+	rest := make([]byte, int(fixed[len(fixed)-1])+saltSize)
+	if _, err := io.ReadFull(r.r, rest); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrFormat
+		}
+		return err
+	}
+
+	id := string(rest[:len(rest)-saltSize])
+	salt := rest[len(rest)-saltSize:]
+
+	key, err := r.keyring.ContentKeyByID(id)
+	if err != nil {
+		return err
+	}
+
+	aead, err := fileCipher(key, salt)
+	if err != nil {
+		return err
+	}
+
+	r.aead = aead
+	r.header = append(fixed, rest...)
+	r.chunk = make([]byte, int(chunkSize)+tagSize)
+
+	return nil
+}
+
+func (r *Reader) readChunk() error {
+	n, err := io.ReadFull(r.r, r.chunk)
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		// Short chunk must be the last one
+		r.last = true
+	case err != nil:
+		return err
+	default:
+		// Full chunk is the last one if nothing follows it
+		if _, err := r.r.Peek(1); err == io.EOF {
+			r.last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if n < tagSize {
+		return ErrAuthentication
+	}
+
+	plain, err := r.aead.Open(r.chunk[:0], chunkNonce(r.counter, r.last), r.chunk[:n], r.header)
+	if err != nil {
+		return ErrAuthentication
+	}
+
+	r.plain = plain
+	r.counter++
+
+	return nil
+}
+
+// Cipher of a single file: AES-256-GCM with key derived from the content key
+// and the salt of the file.
+func fileCipher(key, salt []byte) (cipher.AEAD, error) {
+	if len(key) < minKeySize {
+		return nil, ErrKeySize
+	}
+
+	fileKey := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, salt, []byte("yadisk-crypt content v1")), fileKey); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, counter)
+	if last {
+		nonce[11] = 1
+	}
+
+	return nonce
+}
@@ -0,0 +1,207 @@
+package crypt
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Keyring with fixed keys.
+type testKeyring struct {
+	id   string
+	keys map[string][]byte
+}
+
+func newTestKeyring(id string) *testKeyring {
+	return &testKeyring{id: id, keys: map[string][]byte{
+		"old": bytes.Repeat([]byte{1}, 32),
+		"new": bytes.Repeat([]byte{2}, 32),
+	}}
+}
+
+func (k *testKeyring) ContentKey() (string, []byte, error) {
+	return k.id, k.keys[k.id], nil
+}
+
+func (k *testKeyring) ContentKeyByID(id string) ([]byte, error) {
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	return key, nil
+}
+
+func (k *testKeyring) NameKey() ([]byte, error) {
+	return bytes.Repeat([]byte{3}, 32), nil
+}
+
+func encrypt(t *testing.T, keyring Keyring, content []byte, chunkSize int) []byte {
+	buf := &bytes.Buffer{}
+
+	w, err := NewWriter(buf, keyring, chunkSize)
+	assert.Nil(t, err)
+
+	// Write in pieces not aligned to chunks
+	for len(content) > 0 {
+		n := 3
+		if n > len(content) {
+			n = len(content)
+		}
+		_, err := w.Write(content[:n])
+		assert.Nil(t, err)
+		content = content[n:]
+	}
+
+	assert.Nil(t, w.Close())
+	assert.Nil(t, w.Close())
+
+	return buf.Bytes()
+}
+
+func TestStream(t *testing.T) {
+	keyring := newTestKeyring("new")
+
+	for _, size := range []int{0, 1, 15, 16, 17, 32, 100} {
+		content := bytes.Repeat([]byte("0123456789"), 10)[:size]
+
+		encrypted := encrypt(t, keyring, content, 16)
+		assert.False(t, bytes.Contains(encrypted, []byte("0123456789")))
+
+		decrypted, err := ioutil.ReadAll(NewReader(bytes.NewReader(encrypted), keyring))
+		assert.Nil(t, err, "size %d", size)
+		assert.Equal(t, content, decrypted, "size %d", size)
+	}
+
+	// The same content is encrypted differently every time
+	assert.NotEqual(t, encrypt(t, keyring, []byte("content"), 0), encrypt(t, keyring, []byte("content"), 0))
+}
+
+func TestStream_KeyRotation(t *testing.T) {
+	encrypted := encrypt(t, newTestKeyring("old"), []byte("content"), 0)
+
+	decrypted, err := ioutil.ReadAll(NewReader(bytes.NewReader(encrypted), newTestKeyring("new")))
+	assert.Nil(t, err)
+	assert.Equal(t, "content", string(decrypted))
+
+	_, err = ioutil.ReadAll(NewReader(bytes.NewReader(encrypted), &testKeyring{}))
+	assert.Equal(t, ErrKeyNotFound, err)
+}
+
+func TestStream_Tampering(t *testing.T) {
+	keyring := newTestKeyring("new")
+	content := []byte(strings.Repeat("x", 40))
+
+	// Header: magic, version, chunk size, key ID of 3 bytes and salt
+	headerSize := len(magic) + 1 + 4 + 1 + 3 + saltSize
+	chunk := 16 + tagSize
+
+	tests := []struct {
+		name   string
+		modify func(data []byte) []byte
+		error  error
+	}{
+		{
+			name:   "not encrypted",
+			modify: func(data []byte) []byte { return []byte("plain content") },
+			error:  ErrFormat,
+		},
+		{
+			name:   "empty",
+			modify: func(data []byte) []byte { return nil },
+			error:  ErrFormat,
+		},
+		{
+			name: "unknown version",
+			modify: func(data []byte) []byte {
+				data[len(magic)] = 2
+				return data
+			},
+			error: ErrVersion,
+		},
+		{
+			name: "modified content",
+			modify: func(data []byte) []byte {
+				data[headerSize+chunk+1] ^= 1
+				return data
+			},
+			error: ErrAuthentication,
+		},
+		{
+			name: "modified salt",
+			modify: func(data []byte) []byte {
+				data[headerSize-1] ^= 1
+				return data
+			},
+			error: ErrAuthentication,
+		},
+		{
+			name: "modified chunk size",
+			modify: func(data []byte) []byte {
+				data[len(magic)+4] = 32
+				return data
+			},
+			error: ErrAuthentication,
+		},
+		{
+			name: "reordered chunks",
+			modify: func(data []byte) []byte {
+				result := append([]byte{}, data[:headerSize]...)
+				result = append(result, data[headerSize+chunk:headerSize+2*chunk]...)
+				result = append(result, data[headerSize:headerSize+chunk]...)
+				return append(result, data[headerSize+2*chunk:]...)
+			},
+			error: ErrAuthentication,
+		},
+		{
+			name:   "truncated at chunk boundary",
+			modify: func(data []byte) []byte { return data[:headerSize+2*chunk] },
+			error:  ErrAuthentication,
+		},
+		{
+			name:   "truncated header",
+			modify: func(data []byte) []byte { return data[:headerSize-1] },
+			error:  ErrFormat,
+		},
+		{
+			name:   "header only",
+			modify: func(data []byte) []byte { return data[:headerSize] },
+			error:  ErrAuthentication,
+		},
+		{
+			name:   "extended",
+			modify: func(data []byte) []byte { return append(data, 0) },
+			error:  ErrAuthentication,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := test.modify(encrypt(t, keyring, content, 16))
+
+			_, err := ioutil.ReadAll(NewReader(bytes.NewReader(data), keyring))
+			assert.Equal(t, test.error, err)
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestWriter_Error(t *testing.T) {
+	w, err := NewWriter(failingWriter{}, newTestKeyring("new"), 4)
+	assert.Nil(t, err)
+
+	_, err = w.Write([]byte("0123456789"))
+	assert.Equal(t, io.ErrClosedPipe, err)
+	assert.Equal(t, io.ErrClosedPipe, w.Close())
+
+	_, err = NewWriter(ioutil.Discard, &testKeyring{id: "short", keys: map[string][]byte{"short": []byte("key")}}, 0)
+	assert.Equal(t, ErrKeySize, err)
+}
@@ -16,6 +16,16 @@ const (
 //
 // See: https://tech.yandex.com/disk/api/reference/capacity-docpage/
 func (c *Client) GetDisk(ctx context.Context) (*Disk, error) {
+	if c.cache != nil {
+		return c.cache.disk(ctx, func(ctx context.Context) (*Disk, error) {
+			return c.getDisk(ctx)
+		})
+	}
+
+	return c.getDisk(ctx)
+}
+
+func (c *Client) getDisk(ctx context.Context) (*Disk, error) {
 	var disk Disk
 
 	_, err := c.doRequestAndDecode(ctx, methodGetDisk, urlGetDisk, nil, nil, &disk)
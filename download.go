@@ -14,10 +14,21 @@ const (
 //
 // path - The path to the file to download.
 //
-// Method returns a Link if it has succeeded.
+// Method returns a Link if it has succeeded. Note that link is accessible
+// only for a limited time, see Link.Expired and DownloadWithFreshLink. If
+// download link cache is enabled, recently requested link could be returned.
+//
+// If path is a directory, the link serves ZIP archive of its content, see
+// DownloadDir.
 //
 // See: https://tech.yandex.com/disk/api/reference/content-docpage/
 func (c *Client) RequestDownloadLink(ctx context.Context, path string) (*Link, error) {
+	if c.linkCache != nil {
+		if link := c.linkCache.get(path, c.now()); link != nil {
+			return link, nil
+		}
+	}
+
 	var link Link
 
 	params := map[string]string{
@@ -29,6 +40,14 @@ func (c *Client) RequestDownloadLink(ctx context.Context, path string) (*Link, e
 		return nil, err
 	}
 
+	link.IssuedAt = c.now()
+	link.path = path
+	link.kind = linkKindDownload
+
+	if c.linkCache != nil {
+		c.linkCache.put(path, &link, link.IssuedAt)
+	}
+
 	return &link, nil
 }
 
@@ -40,7 +59,31 @@ func (c *Client) RequestDownloadLink(ctx context.Context, path string) (*Link, e
 // described. It WILL NOT return an error on successful request with 4xx-5xx
 // HTTP response codes. The application MUST check response code by itself.
 //
+// Link of a directory serves ZIP archive ("application/zip") with content
+// put into a directory named after the downloaded one. Use DownloadDir to
+// extract it.
+//
+// If verification is requested (see WithVerification), reading of response
+// body ends with ChecksumMismatchError instead of io.EOF if downloaded content
+// doesn't match the remote file.
+//
 // See: https://tech.yandex.com/disk/api/reference/content-docpage/
 func (c *Client) Download(ctx context.Context, link *Link) (*http.Response, error) {
-	return c.doRawRequest(ctx, link.Method, link.Href, nil)
+	resp, err := c.doRawRequest(ctx, link.Method, link.Href, nil)
+	if err != nil {
+		return resp, err
+	}
+
+	if _, ok := verifyOptions(ctx); ok && link.path != "" && resp.StatusCode == http.StatusOK {
+		hashes := newTransferHashes()
+		resp.Body = &hashingBody{
+			ReadCloser: resp.Body,
+			hashes:     hashes,
+			onEOF: func() error {
+				return c.verifyDownload(ctx, link.path, hashes)
+			},
+		}
+	}
+
+	return resp, nil
 }
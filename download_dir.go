@@ -0,0 +1,348 @@
+package yadisk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yurykabanov/go-yandex-disk/internal/zipstream"
+)
+
+// ErrUnsafePath is reported for archive entries and listed files which would
+// be written outside of the destination directory (e.g. "../file" or "/file").
+var ErrUnsafePath = errors.New("yadisk: unsafe path")
+
+// How DownloadDir downloads the directory.
+type DirDownloadMode int
+
+const (
+	// Directory tree is listed and files are downloaded one by one by several
+	// workers.
+	DirDownloadFiles DirDownloadMode = iota
+
+	// Directory is downloaded as a single ZIP archive made by Yandex.Disk,
+	// which is extracted while it's being downloaded. It makes a single
+	// download request, but download could not be parallelized.
+	DirDownloadZip
+)
+
+// Options of DownloadDir.
+type DownloadDirOptions struct {
+	// How directory is downloaded. DirDownloadFiles by default.
+	Mode DirDownloadMode
+
+	// What to do if local file already exists. OverwriteNever by default.
+	Overwrite OverwritePolicy
+
+	// Number of simultaneous downloads of DirDownloadFiles. 4 by default.
+	Workers int
+
+	// Only files matching any of these patterns are downloaded, all files are
+	// downloaded if it's empty. Pattern syntax is the one of path.Match,
+	// pattern is matched against both the slash-separated path relative to
+	// the downloaded directory and the base name.
+	Include []string
+
+	// Files and directories matching any of these patterns are not downloaded.
+	Exclude []string
+}
+
+// Download remote directory tree to the given local path.
+//
+// remoteDir - The path to the remote directory.
+// localDir - The path of the local directory, it's created if it doesn't
+// exist.
+// opts - Download options, nil means default options.
+//
+// Files are written atomically (see DownloadOptions.Atomic), modification
+// time of files and directories is set to the remote one.
+//
+// Method returns report of every downloaded file. If some of the files have
+// failed, the report is returned along with ErrPartialTransfer. Other errors
+// mean that download hasn't been started or has been interrupted.
+func (c *Client) DownloadDir(ctx context.Context, remoteDir, localDir string, opts *DownloadDirOptions) (*DirTransferReport, error) {
+	if opts == nil {
+		opts = &DownloadDirOptions{}
+	}
+
+	filter, err := newPathFilter(opts.Include, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(localDir, 0755); err != nil {
+		return nil, err
+	}
+
+	report := &DirTransferReport{}
+
+	// Directories with their modification time in the order of creation
+	var dirs []dirModTime
+
+	if opts.Mode == DirDownloadZip {
+		dirs, err = c.extractDirZip(ctx, remoteDir, localDir, filter, opts, report)
+	} else {
+		dirs, err = c.downloadDirFiles(ctx, remoteDir, localDir, filter, opts, report)
+	}
+	if err != nil {
+		return report, err
+	}
+
+	// Content of directories is written, so their time is not changed anymore
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Chtimes(dirs[i].path, dirs[i].modified, dirs[i].modified)
+	}
+
+	if len(report.Failed()) > 0 {
+		return report, ErrPartialTransfer
+	}
+
+	return report, nil
+}
+
+type dirModTime struct {
+	path     string
+	modified time.Time
+}
+
+func (c *Client) downloadDirFiles(ctx context.Context, remoteDir, localDir string, filter *pathFilter, opts *DownloadDirOptions, report *DirTransferReport) ([]dirModTime, error) {
+	var dirs []dirModTime
+
+	// Metainformation of reported files, it's nil for failed directories
+	var resources []*Resource
+
+	var walk func(remote, rel string) error
+	walk = func(remote, rel string) error {
+		items, err := c.ListDirectory(ctx, remote)
+		if err != nil {
+			return err
+		}
+
+		for i := range items {
+			item := &items[i]
+
+			// Names come from the server, so they're checked like archive
+			// paths, each of them must be a single path element
+			name, nameOk := sanitizeArchivePath(item.Name)
+			itemRel, ok := sanitizeArchivePath(path.Join(rel, item.Name))
+			if !ok || !nameOk || name != item.Name || strings.Contains(name, "/") {
+				report.Files = append(report.Files, FileTransferResult{RemotePath: path.Join(remote, item.Name), Err: ErrUnsafePath})
+				resources = append(resources, nil)
+				continue
+			}
+
+			result := FileTransferResult{
+				LocalPath:  filepath.Join(localDir, filepath.FromSlash(itemRel)),
+				RemotePath: path.Join(remote, item.Name),
+			}
+
+			if item.Type == ResourceTypeDirectory {
+				if filter.excluded(itemRel) {
+					continue
+				}
+
+				err := os.MkdirAll(result.LocalPath, 0755)
+				if err == nil {
+					dirs = append(dirs, dirModTime{path: result.LocalPath, modified: item.Modified})
+					err = walk(result.RemotePath, itemRel)
+				}
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if err != nil {
+					result.Err = err
+					report.Files = append(report.Files, result)
+					resources = append(resources, nil)
+				}
+
+				continue
+			}
+
+			if !filter.matches(itemRel) {
+				continue
+			}
+
+			result.Size = item.Size
+			report.Files = append(report.Files, result)
+			resources = append(resources, item)
+		}
+
+		return nil
+	}
+
+	if err := walk(remoteDir, ""); err != nil {
+		return nil, err
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = defaultDirTransferWorkers
+	}
+
+	downloadOpts := &DownloadOptions{Overwrite: opts.Overwrite, Atomic: true, PreserveModTime: true}
+
+	type job struct {
+		result   *FileTransferResult
+		resource *Resource
+	}
+
+	jobs := make(chan job)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				job.result.Skipped, job.result.Err = c.downloadFile(ctx, job.result.RemotePath, job.result.LocalPath, job.resource, downloadOpts)
+			}
+		}()
+	}
+
+feed:
+	for i := range report.Files {
+		if report.Files[i].Err != nil {
+			continue
+		}
+
+		select {
+		case jobs <- job{result: &report.Files[i], resource: resources[i]}:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return dirs, ctx.Err()
+	}
+
+	return dirs, nil
+}
+
+func (c *Client) extractDirZip(ctx context.Context, remoteDir, localDir string, filter *pathFilter, opts *DownloadDirOptions, report *DirTransferReport) ([]dirModTime, error) {
+	link, err := c.RequestDownloadLink(ctx, remoteDir)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.Download(ctx, link)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil, TransferError{StatusCode: resp.StatusCode}
+	}
+
+	var dirs []dirModTime
+
+	// Yandex.Disk puts content into a directory named after the downloaded one
+	root := path.Base(remoteDir) + "/"
+	stripRoot := false
+
+	zr := zipstream.NewReader(resp.Body)
+
+	for first := true; ; first = false {
+		header, err := zr.Next()
+		if err == io.EOF {
+			return dirs, nil
+		}
+		if err != nil {
+			return dirs, err
+		}
+
+		name := header.Name
+		if first {
+			stripRoot = strings.HasPrefix(name, root)
+		}
+		if stripRoot {
+			name = strings.TrimPrefix(name, root)
+		}
+		if name == "" {
+			dirs = append(dirs, dirModTime{path: localDir, modified: header.Modified})
+			continue
+		}
+
+		rel, ok := sanitizeArchivePath(name)
+		if !ok {
+			report.Files = append(report.Files, FileTransferResult{RemotePath: path.Join(remoteDir, name), Err: ErrUnsafePath})
+			continue
+		}
+
+		result := FileTransferResult{
+			LocalPath:  filepath.Join(localDir, filepath.FromSlash(rel)),
+			RemotePath: path.Join(remoteDir, rel),
+		}
+
+		if header.IsDir() {
+			if filter.excludedTree(rel) {
+				continue
+			}
+
+			if err := os.MkdirAll(result.LocalPath, 0755); err != nil {
+				result.Err = err
+				report.Files = append(report.Files, result)
+				continue
+			}
+
+			dirs = append(dirs, dirModTime{path: result.LocalPath, modified: header.Modified})
+			continue
+		}
+
+		if filter.excludedTree(path.Dir(rel)) || !filter.matches(rel) {
+			continue
+		}
+
+		// Archive doesn't have to contain entries of directories
+		err = os.MkdirAll(filepath.Dir(result.LocalPath), 0755)
+		if err == nil {
+			result.Skipped, err = replaceFile(result.LocalPath, header.Modified, opts.Overwrite, func(w io.Writer) error {
+				var err error
+				result.Size, err = io.Copy(w, zr)
+				return err
+			})
+		}
+		result.Err = err
+
+		report.Files = append(report.Files, result)
+
+		if ctx.Err() != nil {
+			return dirs, ctx.Err()
+		}
+	}
+}
+
+// Clean slash-separated archive path, it's not safe if it's absolute or it
+// leads outside of the destination directory.
+func sanitizeArchivePath(name string) (string, bool) {
+	// Backslash is a separator on Windows
+	if strings.Contains(name, `\`) || strings.HasPrefix(name, "/") {
+		return "", false
+	}
+
+	rel := path.Clean(name)
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+
+	// Drive letters and volume names
+	if filepath.VolumeName(filepath.FromSlash(rel)) != "" || filepath.IsAbs(filepath.FromSlash(rel)) {
+		return "", false
+	}
+
+	return rel, true
+}
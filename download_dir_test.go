@@ -0,0 +1,308 @@
+package yadisk
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/go-yandex-disk/internal/testhelpers"
+)
+
+// Remote tree with content of files equal to their relative paths.
+func putRemoteTree(disk *testhelpers.FakeDisk, dir string, modified time.Time, paths ...string) {
+	for _, p := range paths {
+		if strings.HasSuffix(p, "/") {
+			disk.PutDir(dir + "/" + p)
+			continue
+		}
+		disk.PutFile(dir+"/"+p, []byte(p))
+	}
+
+	for _, p := range append(disk.Files(), dir, dir+"/sub", dir+"/sub/deep", dir+"/skip", dir+"/empty") {
+		disk.Touch(p, modified)
+	}
+}
+
+// Relative paths of local files in alphabetical order.
+func localFiles(t *testing.T, dir string) []string {
+	var files []string
+	filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		assert.Nil(t, err)
+		if !info.IsDir() {
+			rel, _ := filepath.Rel(dir, p)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+
+	return files
+}
+
+func TestClient_DownloadDir(t *testing.T) {
+	modified := time.Date(2019, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+
+		opts     DownloadDirOptions
+		existing []string
+
+		files   []string
+		dirs    []string
+		skipped []string
+		failed  []string
+		error   error
+	}{
+		{
+			name: "whole tree",
+
+			files: []string{"a.txt", "b.log", "skip/e.txt", "sub/c.txt", "sub/deep/d.txt"},
+			dirs:  []string{"empty", "skip", "sub/deep"},
+		},
+
+		{
+			name: "excluded files and directories",
+			opts: DownloadDirOptions{Exclude: []string{"skip", "*.log"}},
+
+			files: []string{"a.txt", "sub/c.txt", "sub/deep/d.txt"},
+			dirs:  []string{"empty", "sub/deep"},
+		},
+
+		{
+			name: "included files",
+			opts: DownloadDirOptions{Include: []string{"sub/*", "*.log"}, Workers: 1},
+
+			files: []string{"b.log", "sub/c.txt"},
+			dirs:  []string{"empty", "sub/deep"},
+		},
+
+		{
+			name:     "existing files are skipped",
+			opts:     DownloadDirOptions{Overwrite: OverwriteSkip},
+			existing: []string{"a.txt", "sub/c.txt"},
+
+			files:   []string{"a.txt", "b.log", "skip/e.txt", "sub/c.txt", "sub/deep/d.txt"},
+			skipped: []string{"a.txt", "sub/c.txt"},
+		},
+
+		{
+			name:     "existing files are overwritten",
+			opts:     DownloadDirOptions{Overwrite: OverwriteAlways},
+			existing: []string{"a.txt"},
+
+			files: []string{"a.txt", "b.log", "skip/e.txt", "sub/c.txt", "sub/deep/d.txt"},
+		},
+
+		{
+			name:     "existing files fail",
+			existing: []string{"a.txt"},
+
+			files:  []string{"a.txt", "b.log", "skip/e.txt", "sub/c.txt", "sub/deep/d.txt"},
+			failed: []string{"a.txt"},
+			error:  ErrPartialTransfer,
+		},
+	}
+	for _, mode := range []DirDownloadMode{DirDownloadFiles, DirDownloadZip} {
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("mode %d: %s", mode, test.name), func(t *testing.T) {
+				dir, cleanup := tempDir(t)
+				defer cleanup()
+
+				localDir := filepath.Join(dir, "dst")
+				writeTree(t, localDir, test.existing...)
+				for _, p := range test.existing {
+					assert.Nil(t, ioutil.WriteFile(filepath.Join(localDir, p), []byte("EXISTING"), 0644))
+				}
+
+				client, disk := newFakeDiskClient()
+				putRemoteTree(disk, "/src", modified, "a.txt", "b.log", "sub/c.txt", "sub/deep/d.txt", "skip/e.txt", "empty/")
+
+				opts := test.opts
+				opts.Mode = mode
+
+				report, err := client.DownloadDir(context.Background(), "/src", localDir, &opts)
+				assert.Equal(t, test.error, err)
+				assert.Equal(t, test.files, localFiles(t, localDir))
+
+				for _, p := range test.dirs {
+					info, err := os.Stat(filepath.Join(localDir, p))
+					assert.Nil(t, err)
+					assert.True(t, info.ModTime().Equal(modified), p)
+				}
+
+				var skipped, failed []string
+				for _, result := range report.Files {
+					rel, _ := filepath.Rel(localDir, result.LocalPath)
+					if result.Skipped {
+						skipped = append(skipped, filepath.ToSlash(rel))
+					}
+					if result.Err != nil {
+						failed = append(failed, filepath.ToSlash(rel))
+					}
+				}
+				// Order of walk differs between modes
+				sort.Strings(skipped)
+				sort.Strings(failed)
+				assert.Equal(t, test.skipped, skipped)
+				assert.Equal(t, test.failed, failed)
+
+				// Downloaded files have remote content and modification time
+				for _, result := range report.Files {
+					if result.Err != nil || result.Skipped {
+						continue
+					}
+
+					content, err := ioutil.ReadFile(result.LocalPath)
+					assert.Nil(t, err)
+					assert.Equal(t, string(disk.File(result.RemotePath).Content), string(content))
+
+					info, err := os.Stat(result.LocalPath)
+					assert.Nil(t, err)
+					assert.True(t, info.ModTime().Equal(modified), result.LocalPath)
+				}
+			})
+		}
+	}
+}
+
+func TestClient_DownloadDir_manyFiles(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	client, disk := newFakeDiskClient()
+
+	var paths []string
+	for i := 0; i < dirListLimit+10; i++ {
+		p := fmt.Sprintf("file-%03d.txt", i)
+		paths = append(paths, p)
+		disk.PutFile("/src/"+p, []byte(p))
+	}
+
+	report, err := client.DownloadDir(context.Background(), "/src", dir, nil)
+	assert.Nil(t, err)
+	assert.Len(t, report.Files, len(paths))
+	assert.Equal(t, paths, localFiles(t, dir))
+}
+
+func TestClient_DownloadDir_zipSlip(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	localDir := filepath.Join(dir, "dst")
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, name := range []string{"src/ok.txt", "src/../evil.txt", "src/sub/../../evil.txt", "/evil.txt", `src/..\evil.txt`} {
+		w, _ := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		w.Write([]byte("CONTENT"))
+	}
+	zw.Close()
+
+	client, disk := newFakeDiskClient()
+	disk.PutDir("/src")
+	disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if !strings.HasPrefix(r.URL.Path, "/download/") {
+			return false
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+		return true
+	}
+
+	report, err := client.DownloadDir(context.Background(), "/src", localDir, &DownloadDirOptions{Mode: DirDownloadZip})
+	assert.Equal(t, ErrPartialTransfer, err)
+	assert.Len(t, report.Failed(), 4)
+	for _, result := range report.Failed() {
+		assert.Equal(t, ErrUnsafePath, result.Err)
+	}
+
+	assert.Equal(t, []string{"dst/ok.txt"}, localFiles(t, dir))
+}
+
+func TestClient_DownloadDir_unsafeNames(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	localDir := filepath.Join(dir, "dst")
+
+	var items []string
+	for _, name := range []string{"ok.txt", "..", "../evil.txt", `..\evil.txt`} {
+		items = append(items, fmt.Sprintf(`{"name": %q, "path": %q, "type": "file", "size": 7}`, name, "disk:/src/"+name))
+	}
+	listing := `{"path": "disk:/src", "type": "dir", "_embedded": {"items": [` + strings.Join(items, ",") + `], "total": 4}}`
+
+	client, disk := newFakeDiskClient()
+	disk.PutFile("/src/ok.txt", []byte("CONTENT"))
+	disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.URL.Path != "/v1/disk/resources" || r.URL.Query().Get("path") != "/src" {
+			return false
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(listing))
+		return true
+	}
+
+	report, err := client.DownloadDir(context.Background(), "/src", localDir, nil)
+	assert.Equal(t, ErrPartialTransfer, err)
+	assert.Len(t, report.Failed(), 3)
+	for _, result := range report.Failed() {
+		assert.Equal(t, ErrUnsafePath, result.Err)
+	}
+
+	assert.Equal(t, []string{"dst/ok.txt"}, localFiles(t, dir))
+}
+
+func TestClient_DownloadDir_Canceled(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client, disk := newFakeDiskClient()
+	for i := 0; i < 20; i++ {
+		disk.PutFile(fmt.Sprintf("/src/file%02d.txt", i), []byte("CONTENT"))
+	}
+	disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasPrefix(r.URL.Path, "/download/") {
+			cancel()
+		}
+		return false
+	}
+
+	_, err := client.DownloadDir(ctx, "/src", dir, &DownloadDirOptions{Workers: 1})
+	assert.Equal(t, context.Canceled, err)
+
+	// Remaining files are not attempted
+	downloads := countRequests(disk, 0, "GET /download/")
+	assert.True(t, downloads <= 2, "%d downloads have been started", downloads)
+}
+
+func TestClient_DownloadDir_Errors(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	client, disk := newFakeDiskClient()
+	disk.PutFile("/file.txt", []byte("CONTENT"))
+
+	for _, mode := range []DirDownloadMode{DirDownloadFiles, DirDownloadZip} {
+		_, err := client.DownloadDir(context.Background(), "/missing", dir, &DownloadDirOptions{Mode: mode})
+		assert.Equal(t, http.StatusNotFound, err.(ApiError).StatusCode)
+	}
+
+	_, err := client.DownloadDir(context.Background(), "/file.txt", dir, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,275 @@
+package yadisk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	defaultParallelWorkers   = 4
+	defaultParallelChunkSize = 8 << 20
+)
+
+// Options of DownloadParallel.
+type ParallelDownloadOptions struct {
+	// Number of concurrent range requests. 4 by default.
+	Workers int
+
+	// Size of the range requested at once. 8 MiB by default.
+	ChunkSize int64
+
+	// How many times failed chunk is retried. Zero means no retries.
+	Retries int
+
+	// Delay between retries. 1 second by default.
+	RetryDelay time.Duration
+
+	// Metainformation of the downloaded file (see GetResource). If it's
+	// provided, its size is used instead of HEAD request and downloaded content
+	// is verified against its Md5 and Sha256.
+	Resource *Resource
+}
+
+// Download file from the given link using concurrent range requests and
+// write its content to w.
+//
+// link - Previously requested link, it's renewed if it expires during download.
+// w - Destination, chunks are written to it concurrently in arbitrary order.
+// opts - Download options, nil means default options.
+//
+// File size is taken from opts.Resource or is requested with HEAD request.
+// Content is verified against checksums of opts.Resource only if w is
+// io.ReaderAt as well (e.g. *os.File), method returns ChecksumMismatchError
+// if verification fails. If verification is requested with WithVerification,
+// metainformation is requested when opts.Resource is not provided.
+//
+// Method returns number of written bytes. It returns TransferError if
+// storage host responds with unexpected status.
+func (c *Client) DownloadParallel(ctx context.Context, link *Link, w io.WriterAt, opts *ParallelDownloadOptions) (int64, error) {
+	if opts == nil {
+		opts = &ParallelDownloadOptions{}
+	}
+
+	d := &parallelDownload{
+		client:     c,
+		link:       link,
+		w:          w,
+		workers:    opts.Workers,
+		chunkSize:  opts.ChunkSize,
+		retries:    opts.Retries,
+		retryDelay: opts.RetryDelay,
+	}
+	if d.workers <= 0 {
+		d.workers = defaultParallelWorkers
+	}
+	if d.chunkSize <= 0 {
+		d.chunkSize = defaultParallelChunkSize
+	}
+	if d.retryDelay <= 0 {
+		d.retryDelay = time.Second
+	}
+
+	ctx, d.progress = c.trackProgress(ctx, -1, 0)
+	defer d.progress.finish()
+
+	var size int64
+	if opts.Resource != nil {
+		size = opts.Resource.Size
+	} else {
+		var err error
+		if size, err = d.head(ctx); err != nil {
+			return 0, err
+		}
+	}
+
+	d.progress.setTotal(size)
+
+	if err := d.run(ctx, size); err != nil {
+		return 0, err
+	}
+
+	resource := opts.Resource
+	if _, ok := verifyOptions(ctx); ok && resource == nil && link.path != "" {
+		var err error
+		if resource, err = c.GetResource(ctx, link.path, 0, 0); err != nil {
+			return size, err
+		}
+	}
+
+	if r, ok := w.(io.ReaderAt); ok && resource != nil {
+		if err := verifyChecksums(io.NewSectionReader(r, 0, size), resource); err != nil {
+			return size, err
+		}
+	}
+
+	return size, nil
+}
+
+type parallelDownload struct {
+	client *Client
+
+	// Link is shared by workers and could be renewed by any of them
+	linkMu sync.Mutex
+	link   *Link
+
+	w io.WriterAt
+
+	workers    int
+	chunkSize  int64
+	retries    int
+	retryDelay time.Duration
+
+	progress *progressTracker
+}
+
+// Request file size with HEAD request.
+func (d *parallelDownload) head(ctx context.Context) (int64, error) {
+	link, err := d.freshLink(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := d.client.doRawRequest(ctx, http.MethodHead, link.Href, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, TransferError{StatusCode: resp.StatusCode}
+	}
+
+	if resp.ContentLength < 0 {
+		return 0, errors.New("yadisk: storage host didn't report file size")
+	}
+
+	return resp.ContentLength, nil
+}
+
+func (d *parallelDownload) run(ctx context.Context, size int64) error {
+	offsets := make(chan int64)
+
+	g, ctx := errgroup.WithContext(ctx)
+
+	g.Go(func() error {
+		defer close(offsets)
+
+		for offset := int64(0); offset < size; offset += d.chunkSize {
+			select {
+			case offsets <- offset:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		return nil
+	})
+
+	for i := 0; i < d.workers; i++ {
+		g.Go(func() error {
+			for offset := range offsets {
+				length := d.chunkSize
+				if offset+length > size {
+					length = size - offset
+				}
+
+				if err := d.downloadChunkWithRetries(ctx, offset, length); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
+
+func (d *parallelDownload) downloadChunkWithRetries(ctx context.Context, offset, length int64) error {
+	for attempt := 0; ; attempt++ {
+		err := d.downloadChunk(ctx, offset, length)
+		if err == nil || attempt >= d.retries || ctx.Err() != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(d.retryDelay):
+		}
+	}
+}
+
+func (d *parallelDownload) downloadChunk(ctx context.Context, offset, length int64) error {
+	link, err := d.freshLink(ctx)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+
+	resp, err := d.client.doRawRequestWithHeaders(ctx, link.Method, link.Href, nil, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		io.Copy(ioutil.Discard, resp.Body)
+		return TransferError{StatusCode: resp.StatusCode}
+	}
+
+	expectedRange := fmt.Sprintf("bytes %d-%d/", offset, offset+length-1)
+	if !strings.HasPrefix(resp.Header.Get("Content-Range"), expectedRange) {
+		n, _ := io.Copy(ioutil.Discard, resp.Body)
+		d.progress.add(-n)
+		return fmt.Errorf("yadisk: unexpected content range %q", resp.Header.Get("Content-Range"))
+	}
+
+	n, err := io.Copy(&offsetWriter{w: d.w, offset: offset}, io.LimitReader(resp.Body, length))
+	if err == nil && n != length {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		// Chunk will be downloaded again
+		d.progress.add(-n)
+		return err
+	}
+
+	return nil
+}
+
+// Copy of the link which is renewed if it has expired.
+func (d *parallelDownload) freshLink(ctx context.Context) (Link, error) {
+	d.linkMu.Lock()
+	defer d.linkMu.Unlock()
+
+	if err := d.client.renewExpiredLink(ctx, d.link); err != nil {
+		return Link{}, err
+	}
+
+	return *d.link, nil
+}
+
+// Sequential writer to io.WriterAt starting at the given offset.
+type offsetWriter struct {
+	w      io.WriterAt
+	offset int64
+}
+
+func (ow *offsetWriter) Write(p []byte) (int, error) {
+	n, err := ow.w.WriteAt(p, ow.offset)
+	ow.offset += int64(n)
+	return n, err
+}
@@ -0,0 +1,129 @@
+package yadisk
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_DownloadParallel(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+
+	tests := []struct {
+		name string
+
+		// Number of the first range requests which fail
+		failures int
+		retries  int
+
+		useResource bool
+		md5         string
+
+		written int64
+		error   error
+	}{
+		{
+			name:        "size from resource",
+			useResource: true,
+
+			written: 100,
+		},
+
+		{
+			name: "size from HEAD",
+
+			written: 100,
+		},
+
+		{
+			name:     "retried chunk",
+			failures: 2,
+			retries:  3,
+
+			written: 100,
+		},
+
+		{
+			name:     "failed chunk",
+			failures: 1,
+
+			error: TransferError{StatusCode: 503},
+		},
+
+		{
+			name:        "checksum mismatch",
+			useResource: true,
+			md5:         "00000000000000000000000000000000",
+
+			written: 100,
+			error:   ChecksumMismatchError{Algorithm: "md5", Expected: "00000000000000000000000000000000", Actual: "7a08b07e84641703e5f2c836aa59a170"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+
+			client, disk := newFakeDiskClient()
+			client.now = func() time.Time { return testNow }
+			disk.PutFile("/file.bin", []byte(content))
+
+			// Chunks are requested concurrently
+			var (
+				mu       sync.Mutex
+				failures int
+			)
+			disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+				mu.Lock()
+				defer mu.Unlock()
+
+				if r.Header.Get("Range") != "" && failures < test.failures {
+					failures++
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return true
+				}
+				return false
+			}
+
+			opts := &ParallelDownloadOptions{
+				Workers:    3,
+				ChunkSize:  7,
+				Retries:    test.retries,
+				RetryDelay: time.Millisecond,
+			}
+			if test.useResource {
+				resource, err := client.GetResource(context.Background(), "/file.bin", 0, 0)
+				assert.Nil(t, err)
+				if test.md5 != "" {
+					resource.Md5 = test.md5
+				}
+				opts.Resource = resource
+			}
+
+			link, err := client.RequestDownloadLink(context.Background(), "/file.bin")
+			assert.Nil(t, err)
+
+			f, err := os.Create(filepath.Join(dir, "file.bin"))
+			assert.Nil(t, err)
+			defer f.Close()
+
+			n, err := client.DownloadParallel(context.Background(), link, f, opts)
+			assert.Equal(t, test.error, err)
+			assert.Equal(t, test.written, n)
+
+			if test.error == nil {
+				downloaded, err := ioutil.ReadFile(f.Name())
+				assert.Nil(t, err)
+				assert.Equal(t, content, string(downloaded))
+			}
+		})
+	}
+}
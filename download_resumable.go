@@ -0,0 +1,344 @@
+package yadisk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yurykabanov/go-yandex-disk/internal/atomicfile"
+)
+
+const (
+	resumableDownloadVersion     = 1
+	resumableDownloadPartSuffix  = ".part"
+	resumableDownloadStateSuffix = ".yadisk-download"
+)
+
+// Options of DownloadFileResumable.
+type ResumableDownloadOptions struct {
+	// What to do if local file already exists. OverwriteNever by default.
+	Overwrite OverwritePolicy
+
+	// Path of the partially downloaded content. "<localPath>.part" by default.
+	// Its state is kept in "<PartPath>.yadisk-download".
+	PartPath string
+
+	// How many times download is resumed within the call after a failure
+	// before giving up. The download could be resumed later by another call
+	// anyway.
+	Retries int
+
+	// Delay between retries. 1 second by default.
+	RetryDelay time.Duration
+}
+
+// Persisted state of resumable download.
+type resumableDownloadState struct {
+	Version int `json:"version"`
+
+	RemotePath   string `json:"remote_path"`
+	Size         int64  `json:"size"`
+	ETag         string `json:"etag"`
+	LastModified string `json:"last_modified"`
+}
+
+// Download remote file to the given local path so that the download could be
+// resumed after interruption.
+//
+// Content is written to the partial file first. If the partial file of the
+// same remote file exists, download continues from its end with a range
+// request. Storage host's ETag (or Last-Modified date) is used to ensure that
+// the remote file hasn't been changed, otherwise download restarts from the
+// beginning. Partial file is renamed to localPath once download is completed
+// (and verified if it's requested with WithVerification).
+//
+// Method returns TransferError if storage host responds with unexpected status.
+func (c *Client) DownloadFileResumable(ctx context.Context, remotePath, localPath string, opts *ResumableDownloadOptions) error {
+	if opts == nil {
+		opts = &ResumableDownloadOptions{}
+	}
+
+	if _, err := os.Lstat(localPath); err == nil {
+		switch opts.Overwrite {
+		case OverwriteSkip:
+			return nil
+		case OverwriteNever:
+			return ErrDestinationExists
+		}
+	}
+
+	d := &resumableDownload{
+		client:     c,
+		remotePath: remotePath,
+	}
+	d.partPath, d.statePath = resumableDownloadPaths(localPath, opts.PartPath)
+
+	retryDelay := opts.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = time.Second
+	}
+
+	total := int64(-1)
+	state, offset := d.restore()
+	if state != nil {
+		total = state.Size
+	}
+
+	ctx, d.progress = c.trackProgress(ctx, total, offset)
+	defer d.progress.finish()
+
+	for attempt := 0; ; attempt++ {
+		err := d.run(ctx)
+		if err == nil {
+			break
+		}
+
+		if attempt >= opts.Retries || ctx.Err() != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryDelay):
+		}
+	}
+
+	if _, ok := verifyOptions(ctx); ok {
+		if err := d.verify(ctx); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(d.partPath, localPath); err != nil {
+		return err
+	}
+	os.Remove(d.statePath)
+
+	return nil
+}
+
+// Remove partially downloaded content of DownloadFileResumable and its state,
+// so the next download starts over.
+//
+// localPath - The path the file is downloaded to.
+// partPath - ResumableDownloadOptions.PartPath, empty string means default.
+//
+// Method returns nil if there's no partial download.
+func RemovePartialDownload(localPath, partPath string) error {
+	partPath, statePath := resumableDownloadPaths(localPath, partPath)
+
+	for _, p := range []string{partPath, statePath} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Paths of the partial file and its state.
+func resumableDownloadPaths(localPath, partPath string) (string, string) {
+	if partPath == "" {
+		partPath = localPath + resumableDownloadPartSuffix
+	}
+
+	return partPath, partPath + resumableDownloadStateSuffix
+}
+
+type resumableDownload struct {
+	client *Client
+
+	remotePath string
+	partPath   string
+	statePath  string
+
+	progress *progressTracker
+}
+
+func (d *resumableDownload) run(ctx context.Context) error {
+	link, err := d.client.RequestDownloadLink(ctx, d.remotePath)
+	if err != nil {
+		return err
+	}
+
+	state, offset := d.restore()
+	d.progress.set(offset)
+
+	header := http.Header{}
+	if state != nil {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		header.Set("If-Range", state.validator())
+	}
+
+	resp, err := d.client.doRawRequestWithHeaders(ctx, link.Method, link.Href, nil, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var flags int
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && state != nil && state.matches(resp, offset):
+		flags = os.O_WRONLY | os.O_APPEND
+	case resp.StatusCode == http.StatusOK:
+		// Remote file has been changed or download is started from scratch
+		offset = 0
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+
+		d.progress.set(0)
+		d.progress.setTotal(resp.ContentLength)
+
+		state = &resumableDownloadState{
+			Version:      resumableDownloadVersion,
+			RemotePath:   d.remotePath,
+			Size:         resp.ContentLength,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		}
+		if err := d.save(state); err != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			return err
+		}
+	case state != nil && (resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable):
+		// Partial file is either inconsistent with the remote one or it's
+		// larger, let's start over
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		d.reset()
+		return d.run(ctx)
+	default:
+		io.Copy(ioutil.Discard, resp.Body)
+		return TransferError{StatusCode: resp.StatusCode}
+	}
+
+	f, err := os.OpenFile(d.partPath, flags, 0644)
+	if err != nil {
+		io.Copy(ioutil.Discard, resp.Body)
+		return err
+	}
+
+	n, err := io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if state.Size >= 0 && offset+n != state.Size {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+// Restore state of the partial file. Nil state is returned if there's
+// nothing to resume.
+func (d *resumableDownload) restore() (*resumableDownloadState, int64) {
+	info, err := os.Stat(d.partPath)
+	if err != nil || info.Size() == 0 {
+		return nil, 0
+	}
+
+	data, err := ioutil.ReadFile(d.statePath)
+	if err != nil {
+		return nil, 0
+	}
+
+	var state resumableDownloadState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, 0
+	}
+
+	if state.Version != resumableDownloadVersion ||
+		state.RemotePath != d.remotePath ||
+		state.validator() == "" ||
+		(state.Size >= 0 && info.Size() >= state.Size) {
+		return nil, 0
+	}
+
+	return &state, info.Size()
+}
+
+func (d *resumableDownload) save(state *resumableDownloadState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(d.statePath, data, 0600)
+}
+
+// Verify downloaded content. Content is removed if it doesn't match, so the
+// next attempt starts over.
+func (d *resumableDownload) verify(ctx context.Context) error {
+	resource, err := d.client.GetResource(ctx, d.remotePath, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(d.partPath)
+	if err != nil {
+		return err
+	}
+
+	err = verifyChecksums(f, resource)
+	f.Close()
+
+	if _, ok := err.(ChecksumMismatchError); ok {
+		d.reset()
+	}
+
+	return err
+}
+
+func (d *resumableDownload) reset() {
+	os.Remove(d.partPath)
+	os.Remove(d.statePath)
+}
+
+// Value of If-Range header. Weak ETags can't be used with If-Range, so
+// Last-Modified date is used instead.
+func (s *resumableDownloadState) validator() string {
+	if s.ETag != "" && !strings.HasPrefix(s.ETag, "W/") {
+		return s.ETag
+	}
+
+	return s.LastModified
+}
+
+// Whether partial response continues the partial file of the same remote file.
+func (s *resumableDownloadState) matches(resp *http.Response, offset int64) bool {
+	if etag := resp.Header.Get("ETag"); etag != "" && s.ETag != "" && etag != s.ETag {
+		return false
+	}
+
+	contentRange := resp.Header.Get("Content-Range")
+
+	prefix := fmt.Sprintf("bytes %d-", offset)
+	if !strings.HasPrefix(contentRange, prefix) {
+		return false
+	}
+
+	slash := strings.LastIndex(contentRange, "/")
+	if slash < 0 {
+		return false
+	}
+
+	total := contentRange[slash+1:]
+	if total == "*" || s.Size < 0 {
+		return true
+	}
+
+	size, err := strconv.ParseInt(total, 10, 64)
+	return err == nil && size == s.Size
+}
@@ -0,0 +1,225 @@
+package yadisk
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeETag(content string) string {
+	sum := md5.Sum([]byte(content))
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func TestClient_DownloadFileResumable(t *testing.T) {
+	const content = "0123456789ABCDEFGHIJ"
+
+	tests := []struct {
+		name string
+
+		remote string
+		part   string
+		state  *resumableDownloadState
+
+		content string
+		ranges  []string
+	}{
+		{
+			name:   "no partial file",
+			remote: content,
+
+			content: content,
+			ranges:  []string{""},
+		},
+
+		{
+			name:   "partial file",
+			remote: content,
+			part:   "0123456",
+			state:  &resumableDownloadState{Version: 1, RemotePath: "/file.bin", Size: 20, ETag: fakeETag(content)},
+
+			content: content,
+			ranges:  []string{"bytes=7-"},
+		},
+
+		{
+			name:   "remote file changed",
+			remote: strings.ToLower(content),
+			part:   "0123456",
+			state:  &resumableDownloadState{Version: 1, RemotePath: "/file.bin", Size: 20, ETag: fakeETag(content)},
+
+			content: strings.ToLower(content),
+			ranges:  []string{"bytes=7-"},
+		},
+
+		{
+			name:   "partial file of another remote file",
+			remote: content,
+			part:   "0123456",
+			state:  &resumableDownloadState{Version: 1, RemotePath: "/another.bin", Size: 20, ETag: fakeETag(content)},
+
+			content: content,
+			ranges:  []string{""},
+		},
+
+		{
+			name:   "partial file without state",
+			remote: content,
+			part:   "0123456",
+
+			content: content,
+			ranges:  []string{""},
+		},
+
+		{
+			name:   "partial file larger than remote",
+			remote: content,
+			part:   content + content,
+			state:  &resumableDownloadState{Version: 1, RemotePath: "/file.bin", Size: 20, ETag: fakeETag(content)},
+
+			content: content,
+			ranges:  []string{""},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+
+			localPath := filepath.Join(dir, "file.bin")
+			partPath := localPath + resumableDownloadPartSuffix
+			statePath := partPath + resumableDownloadStateSuffix
+
+			if test.part != "" {
+				assert.Nil(t, ioutil.WriteFile(partPath, []byte(test.part), 0644))
+			}
+			if test.state != nil {
+				data, _ := json.Marshal(test.state)
+				assert.Nil(t, ioutil.WriteFile(statePath, data, 0600))
+			}
+
+			client, disk := newFakeDiskClient()
+			disk.PutFile("/file.bin", []byte(test.remote))
+
+			var ranges []string
+			disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+				if strings.HasPrefix(r.URL.Path, "/download/") {
+					ranges = append(ranges, r.Header.Get("Range"))
+				}
+				return false
+			}
+
+			err := client.DownloadFileResumable(context.Background(), "/file.bin", localPath, nil)
+			assert.Nil(t, err)
+			assert.Equal(t, test.ranges, ranges)
+
+			downloaded, err := ioutil.ReadFile(localPath)
+			assert.Nil(t, err)
+			assert.Equal(t, test.content, string(downloaded))
+
+			_, err = os.Stat(partPath)
+			assert.True(t, os.IsNotExist(err))
+			_, err = os.Stat(statePath)
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestClient_DownloadFileResumable_Interrupted(t *testing.T) {
+	const content = "0123456789ABCDEFGHIJ"
+
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	localPath := filepath.Join(dir, "file.bin")
+
+	client, disk := newFakeDiskClient()
+	disk.PutFile("/file.bin", []byte(content))
+
+	// Connection is lost after 8 bytes
+	disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if !strings.HasPrefix(r.URL.Path, "/download/") {
+			return false
+		}
+
+		w.Header().Set("ETag", fakeETag(content))
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, content[:8])
+		return true
+	}
+
+	err := client.DownloadFileResumable(context.Background(), "/file.bin", localPath, nil)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	part, err := ioutil.ReadFile(localPath + resumableDownloadPartSuffix)
+	assert.Nil(t, err)
+	assert.Equal(t, content[:8], string(part))
+
+	var ranges []string
+	disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasPrefix(r.URL.Path, "/download/") {
+			ranges = append(ranges, r.Header.Get("Range"))
+		}
+		return false
+	}
+
+	err = client.DownloadFileResumable(context.Background(), "/file.bin", localPath, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"bytes=8-"}, ranges)
+
+	downloaded, err := ioutil.ReadFile(localPath)
+	assert.Nil(t, err)
+	assert.Equal(t, content, string(downloaded))
+
+	t.Run("existing local file", func(t *testing.T) {
+		err := client.DownloadFileResumable(context.Background(), "/file.bin", localPath, nil)
+		assert.Equal(t, ErrDestinationExists, err)
+	})
+}
+
+func TestRemovePartialDownload(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	localPath := filepath.Join(dir, "file.bin")
+
+	client, disk := newFakeDiskClient()
+	disk.PutFile("/file.bin", []byte("0123456789"))
+
+	// Connection is lost after 4 bytes
+	disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if !strings.HasPrefix(r.URL.Path, "/download/") {
+			return false
+		}
+
+		w.Header().Set("Content-Length", "10")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, "0123")
+		return true
+	}
+
+	err := client.DownloadFileResumable(context.Background(), "/file.bin", localPath, nil)
+	assert.NotNil(t, err)
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 2)
+
+	assert.Nil(t, RemovePartialDownload(localPath, ""))
+	files, _ = ioutil.ReadDir(dir)
+	assert.Len(t, files, 0)
+
+	// Nothing to remove
+	assert.Nil(t, RemovePartialDownload(localPath, ""))
+}
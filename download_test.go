@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -36,6 +37,9 @@ func TestClient_RequestDownloadLink(t *testing.T) {
 				Href:      "some_href",
 				Method:    "PUT",
 				Templated: false,
+				IssuedAt:  testNow,
+				path:      "/some_path/some_file.ext",
+				kind:      linkKindDownload,
 			},
 
 			error: nil,
@@ -69,6 +73,7 @@ func TestClient_RequestDownloadLink(t *testing.T) {
 					Body:       ioutil.NopCloser(bytes.NewBufferString(test.responseBody)),
 				}
 			}))
+			client.now = func() time.Time { return testNow }
 
 			link, err := client.RequestDownloadLink(context.Background(), test.path)
 
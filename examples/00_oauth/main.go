@@ -2,101 +2,71 @@ package main
 
 import (
 	"context"
-	"crypto/rand"
-	"encoding/base64"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"log"
-	"net/http"
-	"time"
+	"os"
+	"strings"
 
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/yandex"
+
+	"github.com/yurykabanov/go-yandex-disk/auth"
 )
 
 var (
-	listenAddr   = flag.String("listen", "localhost:8000", "Listen address")
+	flow         = flag.String("flow", "local-server", "Which flow to use: 'local-server', 'verification-code' or 'device-code'")
 	clientID     = flag.String("client-id", "", "OAuth Client ID")
 	clientSecret = flag.String("client-secret", "", "OAuth Client Secret")
-	callbackUrl  = flag.String("callback-url", "http://localhost:8000/auth/yandex/callback", "OAuth Callback URL")
+	callbackUrl  = flag.String("callback-url", "http://localhost:8000/auth/yandex/callback", "OAuth Callback URL (local-server flow only)")
+	scopes       = flag.String("scopes", "", "Comma separated list of scopes, e.g. 'cloud_api:disk.read,cloud_api:disk.info'")
 )
 
-type YandexAuth struct {
-	oauthConfig *oauth2.Config
-}
-
-func NewYandexAuth(clientId, clientSecret, callbackUrl string) *YandexAuth {
-	return &YandexAuth{
-		oauthConfig: &oauth2.Config{
-			ClientID:     clientId,
-			ClientSecret: clientSecret,
-			RedirectURL:  callbackUrl,
-			Endpoint:     yandex.Endpoint,
-		},
-	}
-}
-
 func main() {
 	flag.Parse()
 
-	auth := NewYandexAuth(*clientID, *clientSecret, *callbackUrl)
-
-	mux := http.NewServeMux()
-
-	mux.HandleFunc("/auth/yandex/login", auth.Login)
-	mux.HandleFunc("/auth/yandex/callback", auth.Callback)
-
-	server := &http.Server{
-		Addr:    *listenAddr,
-		Handler: mux,
+	var scopeList []string
+	if *scopes != "" {
+		scopeList = strings.Split(*scopes, ",")
 	}
 
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatal(err)
+	config := auth.NewConfig(*clientID, *clientSecret, scopeList...)
+
+	var (
+		token *oauth2.Token
+		err   error
+	)
+
+	switch *flow {
+	case "local-server":
+		config.RedirectURL = *callbackUrl
+
+		token, err = auth.LoginWithLocalServer(context.Background(), config, auth.LocalServerOptions{
+			OpenURL: func(authURL string) error {
+				_, err := fmt.Printf("Open the following URL in your browser and grant access:\n\n%s\n\n", authURL)
+				return err
+			},
+		})
+	case "verification-code":
+		token, err = auth.LoginWithVerificationCode(context.Background(), config, auth.TerminalPrompt(os.Stdin, os.Stdout))
+	case "device-code":
+		token, err = auth.LoginWithDeviceCode(context.Background(), config, auth.DeviceOptions{
+			DeviceName: "yandex-oauth example",
+			ShowCode:   auth.PrintDeviceCode(os.Stdout),
+		})
+	default:
+		fmt.Println("You should specify -flow=<...> with either 'local-server', 'verification-code' or 'device-code'")
+		os.Exit(1)
 	}
-}
-
-func (h *YandexAuth) generateStateOauthCookie(w http.ResponseWriter) string {
-	var expiration = time.Now().Add(365 * 24 * time.Hour)
 
-	b := make([]byte, 16)
-	rand.Read(b)
-	state := base64.URLEncoding.EncodeToString(b)
-	cookie := http.Cookie{Name: "oauthstate", Value: state, Expires: expiration, HttpOnly: true}
-	http.SetCookie(w, &cookie)
-
-	return state
-}
-
-func (h *YandexAuth) Login(w http.ResponseWriter, r *http.Request) {
-	state := h.generateStateOauthCookie(w)
-	url := h.oauthConfig.AuthCodeURL(state)
-	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
-}
-
-func (h *YandexAuth) Callback(w http.ResponseWriter, r *http.Request) {
-	if state, err := r.Cookie("oauthstate"); err != nil || r.FormValue("state") != state.Value {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("bad state"))
-		return
-	}
-
-	code := r.FormValue("code")
-
-	token, err := h.oauthConfig.Exchange(context.Background(), code)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte("bad code"))
-		return
+		log.Fatal(err)
 	}
 
-	w.WriteHeader(http.StatusOK)
-
 	data, err := json.MarshalIndent(token, "", "  ")
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("internal server error"))
+		log.Fatal(err)
 	}
 
-	w.Write(data)
+	fmt.Println(string(data))
 }
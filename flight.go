@@ -0,0 +1,110 @@
+package yadisk
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Group of calls shared by concurrent callers with the same key, so that
+// only one call is performed at a time.
+//
+// Shared call is performed with context which isn't canceled together with
+// a caller's one: each caller stops waiting once its own context is done,
+// while the call is canceled once all of its callers have stopped waiting.
+// Zero value is ready to use.
+type flightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+type flight struct {
+	done chan struct{}
+
+	// Result of the call, it's set once done is closed
+	value interface{}
+	err   error
+
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// Perform the call or join the one in progress with the same key.
+func (g *flightGroup) do(ctx context.Context, key string, call func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[string]*flight)
+	}
+
+	f, ok := g.flights[key]
+	if !ok {
+		callCtx, cancel := context.WithCancel(detachedContext{ctx})
+		f = &flight{done: make(chan struct{}), cancel: cancel}
+		g.flights[key] = f
+
+		go g.run(callCtx, key, f, call)
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		g.leave(key, f)
+		return nil, ctx.Err()
+	}
+}
+
+func (g *flightGroup) run(ctx context.Context, key string, f *flight, call func(ctx context.Context) (interface{}, error)) {
+	value, err := call(ctx)
+	f.cancel()
+
+	g.mu.Lock()
+	g.forget(key, f)
+	f.value, f.err = value, err
+	close(f.done)
+	g.mu.Unlock()
+}
+
+// Stop waiting for the call, it's canceled if nobody else waits for it.
+func (g *flightGroup) leave(key string, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	f.waiters--
+	if f.waiters == 0 {
+		f.cancel()
+
+		// Subsequent callers start a new call rather than join the canceled one
+		g.forget(key, f)
+	}
+}
+
+func (g *flightGroup) forget(key string, f *flight) {
+	if g.flights[key] == f {
+		delete(g.flights, key)
+	}
+}
+
+// Context which carries values of the parent one, but neither its deadline
+// nor cancellation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (ctx detachedContext) Value(key interface{}) interface{} {
+	return ctx.parent.Value(key)
+}
@@ -0,0 +1,89 @@
+package yadisk
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlightGroup(t *testing.T) {
+	var g flightGroup
+	var calls int32
+
+	// The first call stalls until it's canceled
+	call := func(ctx context.Context) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return "value", nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := g.do(ctx, "key", call)
+			assert.Equal(t, context.DeadlineExceeded, err)
+		}()
+	}
+	wg.Wait()
+
+	// Abandoned call is not joined
+	value, err := g.do(context.Background(), "key", call)
+	assert.Nil(t, err)
+	assert.Equal(t, "value", value)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestFlightGroup_sharedCall(t *testing.T) {
+	var g flightGroup
+	var calls int32
+
+	release := make(chan struct{})
+	call := func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		select {
+		case <-release:
+			return "value", nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		_, err := g.do(ctx, "key", call)
+		assert.Equal(t, context.Canceled, err)
+	}()
+
+	results := make(chan interface{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		value, _ := g.do(context.Background(), "key", call)
+		results <- value
+	}()
+
+	// Call isn't canceled while someone waits for it
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	wg.Wait()
+	close(release)
+
+	assert.Equal(t, "value", <-results)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
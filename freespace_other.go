@@ -0,0 +1,10 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !windows
+// +build !darwin,!dragonfly,!freebsd,!linux,!windows
+
+package yadisk
+
+// Free space could not be determined on this platform, thus it's not checked.
+
+func availableSpace(dir string) (int64, error) {
+	return -1, nil
+}
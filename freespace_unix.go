@@ -0,0 +1,18 @@
+//go:build darwin || dragonfly || freebsd || linux
+// +build darwin dragonfly freebsd linux
+
+package yadisk
+
+import (
+	"syscall"
+)
+
+// Number of bytes available to unprivileged user in the file system of dir.
+func availableSpace(dir string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
@@ -0,0 +1,431 @@
+package yadisk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+)
+
+var errIsDirectory = errors.New("is a directory")
+
+// FS is file system of a remote directory. It implements fs.FS, fs.StatFS,
+// fs.ReadDirFS and fs.ReadFileFS, so it could be used with fs.WalkDir,
+// http.FS, template.ParseFS, etc. It could be modified too, see WritableFS.
+//
+// Files are streamed from download links, they implement io.Seeker: content
+// is requested again from the new offset after seek. Directories implement
+// fs.ReadDirFile.
+//
+// fs.FileInfo of files and directories returns *Resource from Sys.
+type FS struct {
+	client *Client
+	ctx    context.Context
+	root   string
+	cache  *Cache
+}
+
+// File system of the remote directory.
+//
+// ctx - Context of all requests made by the file system.
+// root - The path to the directory.
+//
+// Metadata is cached by the client's cache if it's enabled (see SetCache),
+// use FS.WithCache to cache metadata of the file system separately.
+func (c *Client) FS(ctx context.Context, root string) *FS {
+	return &FS{client: c, ctx: ctx, root: normalizePath(root)}
+}
+
+// Copy of the file system which caches metadata in the given cache. Writes
+// made through the file system invalidate it, but writes made by the client
+// don't, so cache TTL should be chosen accordingly.
+func (fsys *FS) WithCache(cache *Cache) *FS {
+	cached := *fsys
+	cached.cache = cache
+
+	return &cached
+}
+
+// Remote path of the name, name must be valid (see fs.ValidPath).
+func (fsys *FS) remotePath(name string) string {
+	return path.Join(fsys.root, name)
+}
+
+func (fsys *FS) resource(remotePath string, limit, offset int) (*Resource, error) {
+	if fsys.cache != nil {
+		return fsys.cache.resource(fsys.ctx, remotePath, limit, offset, func(ctx context.Context) (*Resource, error) {
+			return fsys.client.getResource(ctx, remotePath, limit, offset)
+		})
+	}
+
+	return fsys.client.GetResource(fsys.ctx, remotePath, limit, offset)
+}
+
+func (fsys *FS) stat(op, name string) (*Resource, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	resource, err := fsys.resource(fsys.remotePath(name), 0, 0)
+	if err != nil {
+		return nil, fsPathError(op, name, err)
+	}
+
+	return resource, nil
+}
+
+// Open the named file or directory.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	resource, err := fsys.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &fileInfo{name: path.Base(name), resource: resource}
+
+	if resource.Type == ResourceTypeDirectory {
+		return &fsDir{fsys: fsys, name: name, info: info}, nil
+	}
+
+	return &fsFile{fsys: fsys, name: name, info: info}, nil
+}
+
+// Describe the named file or directory.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	resource, err := fsys.stat("stat", name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileInfo{name: path.Base(name), resource: resource}, nil
+}
+
+// List the named directory sorted by name.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return fsys.readDir(name)
+}
+
+func (fsys *FS) readDir(name string) ([]fs.DirEntry, error) {
+	remotePath := fsys.remotePath(name)
+
+	var entries []fs.DirEntry
+
+	for {
+		resource, err := fsys.resource(remotePath, dirListLimit, len(entries))
+		if err != nil {
+			return nil, fsPathError("readdir", name, err)
+		}
+		if resource.Type != ResourceTypeDirectory {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: ErrNotDirectory}
+		}
+
+		for i := range resource.Embedded.Items {
+			item := &resource.Embedded.Items[i]
+			entries = append(entries, &dirEntry{&fileInfo{name: item.Name, resource: item}})
+		}
+
+		if len(resource.Embedded.Items) == 0 || int64(len(entries)) >= resource.Embedded.Total {
+			break
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// Read the named file.
+func (fsys *FS) ReadFile(name string) ([]byte, error) {
+	resource, err := fsys.stat("read", name)
+	if err != nil {
+		return nil, err
+	}
+	if resource.Type == ResourceTypeDirectory {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errIsDirectory}
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, resource.Size))
+	if _, err := fsys.client.DownloadTo(fsys.ctx, fsys.remotePath(name), buf); err != nil {
+		return nil, fsPathError("read", name, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Errors of FS methods are *fs.PathError, missing resources and parents are
+// reported as fs.ErrNotExist, existing destinations - as fs.ErrExist.
+func fsPathError(op, name string, err error) error {
+	switch {
+	case isNotFound(err), mkdirErrorID(err) == "DiskPathDoesntExistsError":
+		err = fs.ErrNotExist
+	case isConflict(err):
+		err = fs.ErrExist
+	}
+
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+type fileInfo struct {
+	name     string
+	resource *Resource
+}
+
+func (fi *fileInfo) Name() string {
+	return fi.name
+}
+
+func (fi *fileInfo) Size() int64 {
+	return fi.resource.Size
+}
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	if fi.IsDir() {
+		return fs.ModeDir | 0555
+	}
+
+	return 0444
+}
+
+func (fi *fileInfo) ModTime() time.Time {
+	return fi.resource.Modified
+}
+
+func (fi *fileInfo) IsDir() bool {
+	return fi.resource.Type == ResourceTypeDirectory
+}
+
+// *Resource with metadata of the file or directory.
+func (fi *fileInfo) Sys() interface{} {
+	return fi.resource
+}
+
+type dirEntry struct {
+	info *fileInfo
+}
+
+func (e *dirEntry) Name() string {
+	return e.info.Name()
+}
+
+func (e *dirEntry) IsDir() bool {
+	return e.info.IsDir()
+}
+
+func (e *dirEntry) Type() fs.FileMode {
+	return e.info.Mode().Type()
+}
+
+func (e *dirEntry) Info() (fs.FileInfo, error) {
+	return e.info, nil
+}
+
+// Remote file, content is requested on the first read.
+type fsFile struct {
+	fsys *FS
+	name string
+	info *fileInfo
+
+	link   *Link
+	body   io.ReadCloser
+	offset int64
+	closed bool
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *fsFile) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	}
+	if f.offset >= f.info.Size() {
+		return 0, io.EOF
+	}
+
+	if f.body == nil {
+		if err := f.open(); err != nil {
+			return 0, &fs.PathError{Op: "read", Path: f.name, Err: err}
+		}
+	}
+
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+
+	if err != nil && err != io.EOF {
+		err = &fs.PathError{Op: "read", Path: f.name, Err: err}
+	}
+
+	return n, err
+}
+
+// Request content from the current offset. Download link is requested once
+// and reused until it expires, link rejected by the server is renewed once.
+func (f *fsFile) open() error {
+	client := f.fsys.client
+
+	if f.link == nil {
+		link, err := client.RequestDownloadLink(f.fsys.ctx, f.fsys.remotePath(f.name))
+		if err != nil {
+			return err
+		}
+		f.link = link
+	} else if err := client.renewExpiredLink(f.fsys.ctx, f.link); err != nil {
+		return err
+	}
+
+	resp, err := f.request()
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusGone {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		if err := client.RenewLink(f.fsys.ctx, f.link); err != nil {
+			return err
+		}
+
+		if resp, err = f.request(); err != nil {
+			return err
+		}
+	}
+
+	expected := http.StatusOK
+	if f.offset > 0 {
+		expected = http.StatusPartialContent
+	}
+	if resp.StatusCode != expected {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		return TransferError{StatusCode: resp.StatusCode}
+	}
+
+	f.body = resp.Body
+	return nil
+}
+
+func (f *fsFile) request() (*http.Response, error) {
+	var header http.Header
+	if f.offset > 0 {
+		header = http.Header{}
+		header.Set("Range", fmt.Sprintf("bytes=%d-", f.offset))
+	}
+
+	return f.fsys.client.doRawRequestWithHeaders(f.fsys.ctx, f.link.Method, f.link.Href, nil, header)
+}
+
+func (f *fsFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrClosed}
+	}
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size()
+	case io.SeekStart:
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+
+	if offset != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	f.offset = offset
+
+	return offset, nil
+}
+
+func (f *fsFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+
+	if f.body != nil {
+		return f.body.Close()
+	}
+
+	return nil
+}
+
+// Remote directory, it's listed on the first ReadDir.
+type fsDir struct {
+	fsys *FS
+	name string
+	info *fileInfo
+
+	entries []fs.DirEntry
+	listed  bool
+	closed  bool
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *fsDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errIsDirectory}
+}
+
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.closed {
+		return nil, &fs.PathError{Op: "readdir", Path: d.name, Err: fs.ErrClosed}
+	}
+
+	if !d.listed {
+		entries, err := d.fsys.readDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries, d.listed = entries, true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+
+	return entries, nil
+}
+
+func (d *fsDir) Close() error {
+	if d.closed {
+		return &fs.PathError{Op: "close", Path: d.name, Err: fs.ErrClosed}
+	}
+	d.closed = true
+
+	return nil
+}
@@ -0,0 +1,146 @@
+package yadisk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_FS(t *testing.T) {
+	client, disk := newFakeDiskClient()
+	disk.PutFile("/site/index.html", []byte("<h1>Index</h1>"))
+	disk.PutFile("/site/css/style.css", []byte("body {}"))
+	disk.PutFile("/site/empty.txt", nil)
+	disk.PutDir("/site/images")
+	disk.PutFile("/other.txt", []byte("OTHER"))
+
+	fsys := client.FS(context.Background(), "/site")
+
+	assert.Nil(t, fstest.TestFS(fsys, "index.html", "css/style.css", "empty.txt", "images"))
+
+	content, err := fs.ReadFile(fsys, "css/style.css")
+	assert.Nil(t, err)
+	assert.Equal(t, "body {}", string(content))
+
+	info, err := fs.Stat(fsys, "index.html")
+	assert.Nil(t, err)
+	assert.Equal(t, "index.html", info.Name())
+	assert.Equal(t, int64(14), info.Size())
+	assert.Equal(t, fs.FileMode(0444), info.Mode())
+	assert.Equal(t, "disk:/site/index.html", info.Sys().(*Resource).Path)
+
+	var walked []string
+	err = fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		walked = append(walked, p)
+		return err
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{".", "css", "css/style.css", "empty.txt", "images", "index.html"}, walked)
+
+	_, err = fsys.Open("missing.txt")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	_, err = fsys.Open("../other.txt")
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+
+	_, err = fsys.ReadFile("css")
+	assert.NotNil(t, err)
+
+	_, err = fsys.ReadDir("index.html")
+	assert.True(t, errors.Is(err, ErrNotDirectory))
+}
+
+func TestFS_FileLinkRenewal(t *testing.T) {
+	tests := []struct {
+		name string
+
+		// Time after the first read and status code of the next download
+		elapsed    time.Duration
+		statusCode int
+	}{
+		{name: "expired link", elapsed: time.Hour},
+		{name: "rejected link", statusCode: http.StatusForbidden},
+		{name: "gone link", statusCode: http.StatusGone},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, disk := newFakeDiskClient()
+			disk.PutFile("/data/file.txt", []byte("0123456789"))
+
+			now := testNow
+			client.now = func() time.Time { return now }
+
+			f, err := client.FS(context.Background(), "/data").Open("file.txt")
+			assert.Nil(t, err)
+			defer f.Close()
+
+			buf := make([]byte, 4)
+			_, err = io.ReadFull(f, buf)
+			assert.Nil(t, err)
+
+			// Reopen download at the next read
+			_, err = f.(io.Seeker).Seek(6, io.SeekStart)
+			assert.Nil(t, err)
+
+			now = now.Add(test.elapsed)
+			rejected := test.statusCode
+			disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+				if rejected == 0 || !strings.HasPrefix(r.URL.Path, "/download/") {
+					return false
+				}
+
+				w.WriteHeader(rejected)
+				rejected = 0
+				return true
+			}
+
+			since := len(disk.Requests)
+			rest, err := ioutil.ReadAll(f)
+			assert.Nil(t, err)
+			assert.Equal(t, "6789", string(rest))
+			assert.Equal(t, 1, countRequests(disk, since, "GET /v1/disk/resources/download"))
+		})
+	}
+}
+
+func TestClient_FS_FileServer(t *testing.T) {
+	client, disk := newFakeDiskClient()
+	disk.PutFile("/site/page.html", []byte("<h1>Index</h1>"))
+
+	server := http.FileServer(http.FS(client.FS(context.Background(), "/site")))
+
+	req := httptest.NewRequest(http.MethodGet, "/page.html", nil)
+	req.Header.Set("Range", "bytes=4-8")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusPartialContent, rec.Code)
+	body, _ := ioutil.ReadAll(rec.Body)
+	assert.Equal(t, "Index", string(body))
+}
+
+func TestFS_WithCache(t *testing.T) {
+	client, disk := newFakeDiskClient()
+	disk.PutFile("/site/index.html", []byte("<h1>Index</h1>"))
+
+	fsys := client.FS(context.Background(), "/site").WithCache(NewCache(CacheOptions{}))
+
+	since := len(disk.Requests)
+	for i := 0; i < 3; i++ {
+		_, err := fsys.Stat("index.html")
+		assert.Nil(t, err)
+		_, err = fsys.ReadDir(".")
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 2, countRequests(disk, since, "GET /v1/disk/resources"))
+}
@@ -0,0 +1,240 @@
+package yadisk
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+)
+
+var errDirectoryNotEmpty = errors.New("directory not empty")
+
+// WritableFS is file system which could be modified the same way as local
+// one with package os. FS implements it.
+type WritableFS interface {
+	fs.StatFS
+
+	// Create or truncate the named file. Content written to the returned
+	// writer is uploaded while it's written, Close completes the upload and
+	// returns its error.
+	Create(name string) (io.WriteCloser, error)
+
+	// Create directory, its parent must exist.
+	Mkdir(name string, perm fs.FileMode) error
+
+	// Create directory along with any missing parents.
+	MkdirAll(name string, perm fs.FileMode) error
+
+	// Rename (move) file or directory, existing destination is replaced.
+	Rename(oldname, newname string) error
+
+	// Remove file or empty directory.
+	Remove(name string) error
+
+	// Remove file or directory with its content. Missing path is not an
+	// error.
+	RemoveAll(name string) error
+}
+
+var _ WritableFS = (*FS)(nil)
+
+// Create or truncate the named file.
+//
+// Content written to the returned writer is uploaded while it's written,
+// upload is completed by Close which returns its error.
+func (fsys *FS) Create(name string) (io.WriteCloser, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrInvalid}
+	}
+
+	remotePath := fsys.remotePath(name)
+
+	link, err := fsys.client.RequestUploadLink(fsys.ctx, remotePath, true)
+	if err != nil {
+		return nil, fsPathError("create", name, err)
+	}
+
+	pr, pw := io.Pipe()
+	w := &fsWriter{fsys: fsys, name: name, pw: pw, done: make(chan struct{})}
+
+	go func() {
+		defer close(w.done)
+
+		statusCode, err := fsys.client.Upload(fsys.ctx, link, pr)
+		if err == nil && statusCode != http.StatusCreated && statusCode != http.StatusAccepted {
+			err = TransferError{StatusCode: statusCode}
+		}
+		w.err = err
+
+		// Unblock writes if upload has stopped reading
+		if err == nil {
+			err = io.ErrClosedPipe
+		}
+		pr.CloseWithError(err)
+
+		fsys.invalidate(remotePath)
+	}()
+
+	return w, nil
+}
+
+// Writer of the created file.
+type fsWriter struct {
+	fsys *FS
+	name string
+
+	pw   *io.PipeWriter
+	done chan struct{}
+
+	// Result of upload, it's set once done is closed
+	err error
+
+	closed bool
+}
+
+func (w *fsWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, &fs.PathError{Op: "write", Path: w.name, Err: fs.ErrClosed}
+	}
+
+	n, err := w.pw.Write(p)
+	if err != nil {
+		// Upload has failed, report its error rather than the pipe's one
+		<-w.done
+		if w.err != nil {
+			err = w.err
+		}
+		return n, &fs.PathError{Op: "write", Path: w.name, Err: err}
+	}
+
+	return n, nil
+}
+
+// Complete upload and wait for it.
+func (w *fsWriter) Close() error {
+	if w.closed {
+		return &fs.PathError{Op: "close", Path: w.name, Err: fs.ErrClosed}
+	}
+	w.closed = true
+
+	w.pw.Close()
+	<-w.done
+
+	if w.err != nil {
+		return &fs.PathError{Op: "close", Path: w.name, Err: w.err}
+	}
+
+	return nil
+}
+
+// Create directory, perm is ignored. Parent directory must exist.
+func (fsys *FS) Mkdir(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	remotePath := fsys.remotePath(name)
+
+	_, err := fsys.client.CreateDirectory(fsys.ctx, remotePath)
+	fsys.invalidate(remotePath)
+	if err != nil {
+		return fsPathError("mkdir", name, err)
+	}
+
+	return nil
+}
+
+// Create directory along with any missing parents, perm is ignored. Existing
+// directory is not an error.
+func (fsys *FS) MkdirAll(name string, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	remotePath := fsys.remotePath(name)
+
+	err := fsys.client.MkdirAll(fsys.ctx, remotePath)
+	fsys.invalidate(remotePath)
+	if err != nil {
+		return fsPathError("mkdir", name, err)
+	}
+
+	return nil
+}
+
+// Rename (move) file or directory. Existing destination is replaced. Method
+// waits until asynchronous move of directory is finished.
+func (fsys *FS) Rename(oldname, newname string) error {
+	if !fs.ValidPath(oldname) || oldname == "." {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrInvalid}
+	}
+	if !fs.ValidPath(newname) || newname == "." {
+		return &fs.PathError{Op: "rename", Path: newname, Err: fs.ErrInvalid}
+	}
+
+	src, dst := fsys.remotePath(oldname), fsys.remotePath(newname)
+
+	link, statusCode, err := fsys.client.Move(fsys.ctx, src, dst, true)
+	if err == nil && statusCode == http.StatusAccepted {
+		err = fsys.client.WaitOperation(fsys.ctx, link, 0)
+	}
+	fsys.invalidate(src, dst)
+	if err != nil {
+		return fsPathError("rename", oldname, err)
+	}
+
+	return nil
+}
+
+// Remove file or empty directory permanently.
+func (fsys *FS) Remove(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+
+	remotePath := fsys.remotePath(name)
+
+	// Delete removes directories with their content, check that it's empty
+	// bypassing the cache which may be stale
+	resource, err := fsys.client.getResource(fsys.ctx, remotePath, 1, 0)
+	if err != nil {
+		return fsPathError("remove", name, err)
+	}
+	if resource.Type == ResourceTypeDirectory && resource.Embedded.Total > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: errDirectoryNotEmpty}
+	}
+
+	if err := fsys.delete(remotePath); err != nil {
+		return fsPathError("remove", name, err)
+	}
+
+	return nil
+}
+
+// Remove file or directory with its content permanently. Missing path is not
+// an error. Method waits until asynchronous delete is finished.
+func (fsys *FS) RemoveAll(name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if err := fsys.delete(fsys.remotePath(name)); err != nil && !isNotFound(err) {
+		return fsPathError("removeall", name, err)
+	}
+
+	return nil
+}
+
+func (fsys *FS) delete(remotePath string) error {
+	defer fsys.invalidate(remotePath)
+
+	return fsys.client.deletePermanently(fsys.ctx, remotePath)
+}
+
+// Invalidate metadata cached by the file system, client's cache is
+// invalidated by the client itself.
+func (fsys *FS) invalidate(remotePaths ...string) {
+	if fsys.cache != nil {
+		fsys.cache.Invalidate(remotePaths...)
+	}
+}
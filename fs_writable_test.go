@@ -0,0 +1,186 @@
+package yadisk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFS_Create(t *testing.T) {
+	client, disk := newFakeDiskClient()
+	disk.PutDir("/data")
+	disk.PutFile("/data/old.txt", []byte("OLD CONTENT"))
+
+	fsys := client.FS(context.Background(), "/data")
+
+	w, err := fsys.Create("new.txt")
+	assert.Nil(t, err)
+	_, err = io.Copy(w, strings.NewReader(strings.Repeat("0123456789", 1000)))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	assert.Equal(t, strings.Repeat("0123456789", 1000), string(disk.File("/data/new.txt").Content))
+
+	_, err = w.Write([]byte("MORE"))
+	assert.True(t, errors.Is(err, fs.ErrClosed))
+
+	// Existing file is truncated
+	w, err = fsys.Create("old.txt")
+	assert.Nil(t, err)
+	_, err = w.Write([]byte("NEW"))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+
+	content, err := fs.ReadFile(fsys, "old.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, "NEW", string(content))
+
+	_, err = fsys.Create("missing/file.txt")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	_, err = fsys.Create("../file.txt")
+	assert.True(t, errors.Is(err, fs.ErrInvalid))
+
+	// Upload error is returned by Close
+	disk.MaxFileSize = 2
+	w, err = fsys.Create("big.txt")
+	assert.Nil(t, err)
+	w.Write([]byte("BIG"))
+	err = w.Close()
+	assert.Equal(t, &fs.PathError{Op: "close", Path: "big.txt", Err: TransferError{StatusCode: 413}}, err)
+}
+
+func TestFS_Mkdir(t *testing.T) {
+	client, disk := newFakeDiskClient()
+	disk.PutDir("/data")
+	disk.PutFile("/data/file.txt", []byte("FILE"))
+
+	fsys := client.FS(context.Background(), "/data")
+
+	tests := []struct {
+		name  string
+		all   bool
+		error error
+	}{
+		{name: "dir"},
+		{name: "dir", error: fs.ErrExist},
+		{name: "dir", all: true},
+		{name: "file.txt", error: fs.ErrExist},
+		{name: "a/b", error: fs.ErrNotExist},
+		{name: "a/b", all: true},
+		{name: "/abs", error: fs.ErrInvalid},
+	}
+	for _, test := range tests {
+		var err error
+		if test.all {
+			err = fsys.MkdirAll(test.name, 0755)
+		} else {
+			err = fsys.Mkdir(test.name, 0755)
+		}
+
+		if test.error == nil {
+			assert.Nil(t, err, test.name)
+		} else {
+			assert.True(t, errors.Is(err, test.error), "%s: %v", test.name, err)
+		}
+	}
+
+	info, err := fsys.Stat("a/b")
+	assert.Nil(t, err)
+	assert.True(t, info.IsDir())
+}
+
+func TestFS_Rename(t *testing.T) {
+	client, disk := newFakeDiskClient()
+	disk.AsyncOperations = 1
+	disk.PutFile("/data/dir/file.txt", []byte("FILE"))
+	disk.PutFile("/data/old.txt", []byte("OLD"))
+	disk.PutFile("/data/new.txt", []byte("NEW"))
+
+	fsys := client.FS(context.Background(), "/data")
+
+	assert.Nil(t, fsys.Rename("old.txt", "new.txt"))
+	assert.Nil(t, fsys.Rename("dir", "moved"))
+	assert.Equal(t, []string{"/data/moved/file.txt", "/data/new.txt"}, disk.Files())
+	assert.Equal(t, "OLD", string(disk.File("/data/new.txt").Content))
+
+	err := fsys.Rename("missing.txt", "new.txt")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	err = fsys.Rename("new.txt", "missing/new.txt")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+}
+
+func TestFS_Remove(t *testing.T) {
+	client, disk := newFakeDiskClient()
+	disk.AsyncOperations = 1
+	disk.PutFile("/data/dir/file.txt", []byte("FILE"))
+	disk.PutDir("/data/empty")
+	disk.PutFile("/data/file.txt", []byte("FILE"))
+
+	fsys := client.FS(context.Background(), "/data")
+
+	assert.Nil(t, fsys.Remove("file.txt"))
+	assert.Nil(t, fsys.Remove("empty"))
+
+	err := fsys.Remove("dir")
+	assert.Equal(t, &fs.PathError{Op: "remove", Path: "dir", Err: errDirectoryNotEmpty}, err)
+
+	err = fsys.Remove("missing")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	assert.True(t, errors.Is(fsys.Remove("."), fs.ErrInvalid))
+	assert.True(t, errors.Is(fsys.RemoveAll("."), fs.ErrInvalid))
+
+	assert.Nil(t, fsys.RemoveAll("dir"))
+	assert.Nil(t, fsys.RemoveAll("missing"))
+	assert.Empty(t, disk.Files())
+}
+
+func TestFS_Remove_StaleCache(t *testing.T) {
+	client, disk := newFakeDiskClient()
+	client.SetCache(NewCache(CacheOptions{TTL: time.Minute}))
+	disk.PutDir("/data/dir")
+
+	// Directory is cached as empty, then a file is added by someone else
+	_, err := client.GetResource(context.Background(), "/data/dir", 1, 0)
+	assert.Nil(t, err)
+	disk.PutFile("/data/dir/file.txt", []byte("FILE"))
+
+	err = client.FS(context.Background(), "/data").Remove("dir")
+	assert.Equal(t, &fs.PathError{Op: "remove", Path: "dir", Err: errDirectoryNotEmpty}, err)
+	assert.Equal(t, []string{"/data/dir/file.txt"}, disk.Files())
+}
+
+func TestFS_WithCache_Writes(t *testing.T) {
+	client, disk := newFakeDiskClient()
+	disk.PutFile("/data/file.txt", []byte("OLD"))
+
+	fsys := client.FS(context.Background(), "/data").WithCache(NewCache(CacheOptions{}))
+
+	info, err := fsys.Stat("file.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), info.Size())
+
+	w, err := fsys.Create("file.txt")
+	assert.Nil(t, err)
+	w.Write([]byte("CONTENT"))
+	assert.Nil(t, w.Close())
+
+	info, err = fsys.Stat("file.txt")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7), info.Size())
+
+	assert.Nil(t, fsys.Rename("file.txt", "renamed.txt"))
+	_, err = fsys.Stat("file.txt")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+
+	assert.Nil(t, fsys.Remove("renamed.txt"))
+	_, err = fsys.Stat("renamed.txt")
+	assert.True(t, errors.Is(err, fs.ErrNotExist))
+}
@@ -2,5 +2,8 @@ module github.com/yurykabanov/go-yandex-disk
 
 require (
 	github.com/stretchr/testify v1.3.0
+	golang.org/x/crypto v0.0.0-20190308221718-c2843e01d9a2
 	golang.org/x/oauth2 v0.0.0-20190402181905-9f3314589c9a
+	golang.org/x/sync v0.0.0-20181221193216-37e7f081c4d4
+	golang.org/x/time v0.0.0-20190308202827-9d24e82272b4
 )
@@ -0,0 +1,94 @@
+// Package atomicfile replaces local files atomically: content is written to
+// a temporary file in the same directory, synced and renamed to the target,
+// so the target is either fully written or not changed at all.
+package atomicfile
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Options of the replacement.
+type Options struct {
+	// Permissions of the file. 0600 by default.
+	Perm os.FileMode
+
+	// Modification time of the file, it's not set if it's zero.
+	ModTime time.Time
+
+	// Called right before rename, e.g. to check that the target hasn't been
+	// created meanwhile. Its error cancels the replacement and is returned.
+	BeforeRename func() error
+}
+
+// Replace the file with content written by write. Options could be nil.
+func Write(path string, opts *Options, write func(w io.Writer) error) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	perm := opts.Perm
+	if perm == 0 {
+		perm = 0600
+	}
+
+	dir := filepath.Dir(path)
+
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	// Cleanup in case of failure, it's no-op after successful rename
+	defer os.Remove(tmp.Name())
+
+	err = write(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	if !opts.ModTime.IsZero() {
+		if err := os.Chtimes(tmp.Name(), opts.ModTime, opts.ModTime); err != nil {
+			return err
+		}
+	}
+
+	if opts.BeforeRename != nil {
+		if err := opts.BeforeRename(); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Make rename durable, it's not supported on all platforms
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
+
+// Replace the file with the data.
+func WriteFile(path string, data []byte, perm os.FileMode) error {
+	return Write(path, &Options{Perm: perm}, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
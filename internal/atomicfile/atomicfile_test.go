@@ -0,0 +1,99 @@
+package atomicfile
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWrite(t *testing.T) {
+	failure := errors.New("failure")
+	modTime := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		opts  *Options
+		write func(w io.Writer) error
+
+		content string
+		error   error
+	}{
+		{
+			name: "replaced",
+			opts: &Options{Perm: 0644, ModTime: modTime},
+			write: func(w io.Writer) error {
+				_, err := w.Write([]byte("NEW"))
+				return err
+			},
+
+			content: "NEW",
+		},
+		{
+			name: "failed write",
+			write: func(w io.Writer) error {
+				w.Write([]byte("PARTIAL"))
+				return failure
+			},
+
+			content: "OLD",
+			error:   failure,
+		},
+		{
+			name: "canceled before rename",
+			opts: &Options{BeforeRename: func() error { return failure }},
+			write: func(w io.Writer) error {
+				_, err := w.Write([]byte("NEW"))
+				return err
+			},
+
+			content: "OLD",
+			error:   failure,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "atomicfile")
+			assert.Nil(t, err)
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "file.txt")
+			assert.Nil(t, ioutil.WriteFile(path, []byte("OLD"), 0600))
+
+			assert.Equal(t, test.error, Write(path, test.opts, test.write))
+
+			content, _ := ioutil.ReadFile(path)
+			assert.Equal(t, test.content, string(content))
+
+			// Temporary file is removed
+			files, _ := ioutil.ReadDir(dir)
+			assert.Len(t, files, 1)
+
+			if test.error == nil && test.opts != nil {
+				info, _ := os.Stat(path)
+				assert.Equal(t, test.opts.Perm, info.Mode().Perm())
+				assert.True(t, test.opts.ModTime.Equal(info.ModTime()))
+			}
+		})
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "atomicfile")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+	assert.Nil(t, WriteFile(path, []byte("{}"), 0600))
+
+	content, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "{}", string(content))
+
+	info, _ := os.Stat(path)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
@@ -0,0 +1,752 @@
+package testhelpers
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fakeApiPrefix      = "/v1/disk/"
+	fakeUploadPrefix   = "/upload/"
+	fakeDownloadPrefix = "/download/"
+)
+
+// In-memory emulation of Yandex.Disk API and its storage hosts.
+//
+// It supports metadata requests, directory creation, copy, move, delete
+// (optionally as asynchronous operations), upload and download links (uploads
+// support Content-Range header, downloads support Range and If-Range headers,
+// directories are downloaded as ZIP archives).
+// All requests are served without network, use Client to make requests.
+type FakeDisk struct {
+	mu    sync.Mutex
+	files map[string]*FakeFile
+	dirs  map[string]time.Time
+
+	uploads map[string]*fakeUpload
+
+	// Intercept is called for every request before it's handled. If it
+	// returns true, request is considered handled.
+	Intercept func(w http.ResponseWriter, r *http.Request) bool
+
+	// Requests contains "METHOD /path" of every handled request.
+	Requests []string
+
+	// If it's positive, copy, move and delete of directories respond with
+	// "202 Accepted" and operation link. Operation is done at once, but its
+	// status is "in-progress" for this number of status requests.
+	AsyncOperations int
+
+	// Maximum file size reported in disk information, larger uploads are
+	// rejected with "413 Request Entity Too Large". 1 GB by default.
+	MaxFileSize int64
+
+	uploadID int
+
+	// Remaining "in-progress" responses of operations
+	operations  map[string]int
+	operationID int
+}
+
+// Upload session started by upload link request.
+type fakeUpload struct {
+	path     string
+	received []byte
+	done     bool
+}
+
+// File stored in FakeDisk.
+type FakeFile struct {
+	Content          []byte
+	Modified         time.Time
+	CustomProperties map[string]string
+}
+
+func NewFakeDisk() *FakeDisk {
+	return &FakeDisk{
+		files:      make(map[string]*FakeFile),
+		dirs:       map[string]time.Time{"/": time.Now()},
+		uploads:    make(map[string]*fakeUpload),
+		operations: make(map[string]int),
+	}
+}
+
+// Client which sends all requests (including requests to upload and download
+// links) to the fake disk.
+func (d *FakeDisk) Client() *http.Client {
+	return &http.Client{
+		Transport: RoundTripFunc(func(req *http.Request) *http.Response {
+			rec := httptest.NewRecorder()
+			d.ServeHTTP(rec, req)
+			return rec.Result()
+		}),
+	}
+}
+
+// Put file to the fake disk, parent directories are created as needed.
+func (d *FakeDisk) PutFile(p string, content []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p = cleanFakePath(p)
+	d.mkdirAll(path.Dir(p))
+	d.files[p] = &FakeFile{Content: content, Modified: time.Now()}
+}
+
+// Create directory and its parents.
+func (d *FakeDisk) PutDir(p string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.mkdirAll(cleanFakePath(p))
+}
+
+// Set modification time of the file or directory.
+func (d *FakeDisk) Touch(p string, modified time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	p = cleanFakePath(p)
+	if f, ok := d.files[p]; ok {
+		f.Modified = modified
+	}
+	if _, ok := d.dirs[p]; ok {
+		d.dirs[p] = modified
+	}
+}
+
+// Get stored file or nil.
+func (d *FakeDisk) File(p string) *FakeFile {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.files[cleanFakePath(p)]
+}
+
+// Whether directory exists.
+func (d *FakeDisk) Dir(p string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, ok := d.dirs[cleanFakePath(p)]
+	return ok
+}
+
+// Paths of all files in alphabetical order.
+func (d *FakeDisk) Files() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var paths []string
+	for p := range d.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	return paths
+}
+
+func (d *FakeDisk) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	d.Requests = append(d.Requests, r.Method+" "+r.URL.Path)
+	intercept := d.Intercept
+	d.mu.Unlock()
+
+	if intercept != nil && intercept(w, r) {
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, fakeUploadPrefix):
+		d.serveUpload(w, r)
+	case strings.HasPrefix(r.URL.Path, fakeDownloadPrefix):
+		d.serveDownload(w, r)
+	case strings.HasPrefix(r.URL.Path, fakeApiPrefix):
+		d.serveApi(w, r)
+	default:
+		writeFakeError(w, http.StatusNotFound, "NotFound")
+	}
+}
+
+func (d *FakeDisk) serveApi(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	q := r.URL.Query()
+	p := cleanFakePath(q.Get("path"))
+	endpoint := strings.TrimPrefix(r.URL.Path, fakeApiPrefix)
+
+	switch {
+	case endpoint == "" && r.Method == http.MethodGet:
+		d.serveDiskInfo(w)
+	case endpoint == "resources" && r.Method == http.MethodGet:
+		d.serveResource(w, p, q)
+	case endpoint == "resources" && r.Method == http.MethodPut:
+		d.serveMkdir(w, p)
+	case endpoint == "resources" && r.Method == http.MethodDelete:
+		d.serveDelete(w, p)
+	case strings.HasPrefix(endpoint, "operations/") && r.Method == http.MethodGet:
+		d.serveOperation(w, strings.TrimPrefix(endpoint, "operations/"))
+	case endpoint == "resources" && r.Method == http.MethodPatch:
+		d.servePatch(w, r, p)
+	case endpoint == "resources/upload" && r.Method == http.MethodGet:
+		d.serveUploadLink(w, p, q.Get("permanently") == "true")
+	case endpoint == "resources/download" && r.Method == http.MethodGet:
+		d.serveDownloadLink(w, p)
+	case (endpoint == "resources/copy" || endpoint == "resources/move") && r.Method == http.MethodPost:
+		d.serveCopyMove(w, cleanFakePath(q.Get("from")), p, q.Get("permanently") == "true", endpoint == "resources/move")
+	default:
+		writeFakeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (d *FakeDisk) serveDiskInfo(w http.ResponseWriter) {
+	var used int64
+	for _, f := range d.files {
+		used += int64(len(f.Content))
+	}
+
+	writeFakeJson(w, http.StatusOK, map[string]interface{}{
+		"total_space":   int64(10) << 30,
+		"used_space":    used,
+		"max_file_size": d.maxFileSize(),
+		"user":          map[string]string{"uid": "1001", "login": "fake"},
+	})
+}
+
+func (d *FakeDisk) serveResource(w http.ResponseWriter, p string, q url.Values) {
+	if f, ok := d.files[p]; ok {
+		writeFakeJson(w, http.StatusOK, fakeFileResource(p, f))
+		return
+	}
+
+	modified, ok := d.dirs[p]
+	if !ok {
+		writeFakeError(w, http.StatusNotFound, "DiskNotFoundError")
+		return
+	}
+
+	var dirs, files []map[string]interface{}
+	for child, modified := range d.dirs {
+		if child != p && path.Dir(child) == p {
+			dirs = append(dirs, fakeDirResource(child, modified))
+		}
+	}
+	for child, f := range d.files {
+		if path.Dir(child) == p {
+			files = append(files, fakeFileResource(child, f))
+		}
+	}
+	sortFakeResources(dirs)
+	sortFakeResources(files)
+	items := append(dirs, files...)
+
+	limit, offset := 20, 0
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil {
+		limit = v
+	}
+	if v, err := strconv.Atoi(q.Get("offset")); err == nil {
+		offset = v
+	}
+
+	total := len(items)
+	if offset > len(items) {
+		offset = len(items)
+	}
+	items = items[offset:]
+	if limit < len(items) {
+		items = items[:limit]
+	}
+	if items == nil {
+		items = []map[string]interface{}{}
+	}
+
+	resource := fakeDirResource(p, modified)
+	resource["_embedded"] = map[string]interface{}{
+		"path":   "disk:" + p,
+		"limit":  limit,
+		"offset": offset,
+		"total":  total,
+		"items":  items,
+	}
+
+	writeFakeJson(w, http.StatusOK, resource)
+}
+
+func (d *FakeDisk) serveMkdir(w http.ResponseWriter, p string) {
+	if _, ok := d.dirs[p]; ok {
+		writeFakeError(w, http.StatusConflict, "DiskPathPointsToExistentDirectoryError")
+		return
+	}
+	if _, ok := d.files[p]; ok {
+		writeFakeError(w, http.StatusConflict, "DiskResourceAlreadyExistsError")
+		return
+	}
+	if _, ok := d.dirs[path.Dir(p)]; !ok {
+		writeFakeError(w, http.StatusConflict, "DiskPathDoesntExistsError")
+		return
+	}
+
+	d.dirs[p] = time.Now()
+
+	writeFakeLink(w, http.StatusCreated, fakeResourceLink(p))
+}
+
+func (d *FakeDisk) serveDelete(w http.ResponseWriter, p string) {
+	if _, ok := d.files[p]; ok {
+		delete(d.files, p)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if _, ok := d.dirs[p]; !ok || p == "/" {
+		writeFakeError(w, http.StatusNotFound, "DiskNotFoundError")
+		return
+	}
+
+	d.removeTree(p)
+
+	if d.AsyncOperations > 0 {
+		writeFakeLink(w, http.StatusAccepted, d.startOperation())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (d *FakeDisk) servePatch(w http.ResponseWriter, r *http.Request, p string) {
+	f, ok := d.files[p]
+	if !ok {
+		writeFakeError(w, http.StatusNotFound, "DiskNotFoundError")
+		return
+	}
+
+	var body struct {
+		CustomProperties map[string]*string `json:"custom_properties"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeFakeError(w, http.StatusBadRequest, "FieldValidationError")
+		return
+	}
+
+	if f.CustomProperties == nil {
+		f.CustomProperties = make(map[string]string)
+	}
+	for k, v := range body.CustomProperties {
+		if v == nil {
+			delete(f.CustomProperties, k)
+		} else {
+			f.CustomProperties[k] = *v
+		}
+	}
+
+	writeFakeJson(w, http.StatusOK, fakeFileResource(p, f))
+}
+
+func (d *FakeDisk) serveUploadLink(w http.ResponseWriter, p string, overwrite bool) {
+	if _, ok := d.dirs[p]; ok {
+		writeFakeError(w, http.StatusConflict, "DiskResourceAlreadyExistsError")
+		return
+	}
+	if _, ok := d.files[p]; ok && !overwrite {
+		writeFakeError(w, http.StatusConflict, "DiskResourceAlreadyExistsError")
+		return
+	}
+	if _, ok := d.dirs[path.Dir(p)]; !ok {
+		writeFakeError(w, http.StatusConflict, "DiskPathDoesntExistsError")
+		return
+	}
+
+	d.uploadID++
+	id := strconv.Itoa(d.uploadID)
+	d.uploads[id] = &fakeUpload{path: p}
+
+	writeFakeLink(w, http.StatusOK, map[string]interface{}{
+		"href":   "https://uploader.fake" + fakeUploadPrefix + id,
+		"method": http.MethodPut,
+	})
+}
+
+func (d *FakeDisk) serveDownloadLink(w http.ResponseWriter, p string) {
+	_, isFile := d.files[p]
+	_, isDir := d.dirs[p]
+	if !isFile && !isDir {
+		writeFakeError(w, http.StatusNotFound, "DiskNotFoundError")
+		return
+	}
+
+	writeFakeLink(w, http.StatusOK, map[string]interface{}{
+		"href":   "https://downloader.fake" + fakeDownloadPrefix + url.PathEscape(strings.TrimPrefix(p, "/")),
+		"method": http.MethodGet,
+	})
+}
+
+func (d *FakeDisk) serveCopyMove(w http.ResponseWriter, from, to string, overwrite, move bool) {
+	_, isFile := d.files[from]
+	_, isDir := d.dirs[from]
+	if !isFile && !isDir {
+		writeFakeError(w, http.StatusNotFound, "DiskNotFoundError")
+		return
+	}
+
+	_, dstFile := d.files[to]
+	_, dstDir := d.dirs[to]
+	if (dstFile || dstDir) && !overwrite {
+		writeFakeError(w, http.StatusConflict, "DiskResourceAlreadyExistsError")
+		return
+	}
+	if _, ok := d.dirs[path.Dir(to)]; !ok {
+		writeFakeError(w, http.StatusConflict, "DiskPathDoesntExistsError")
+		return
+	}
+
+	d.removeTree(to)
+	delete(d.files, to)
+
+	if isFile {
+		f := *d.files[from]
+		d.files[to] = &f
+	} else {
+		for dir, modified := range d.dirs {
+			if dir == from || strings.HasPrefix(dir, from+"/") {
+				d.dirs[to+strings.TrimPrefix(dir, from)] = modified
+			}
+		}
+		for p, f := range d.files {
+			if strings.HasPrefix(p, from+"/") {
+				copied := *f
+				d.files[to+strings.TrimPrefix(p, from)] = &copied
+			}
+		}
+	}
+
+	if move {
+		d.removeTree(from)
+		delete(d.files, from)
+	}
+
+	if isDir && d.AsyncOperations > 0 {
+		writeFakeLink(w, http.StatusAccepted, d.startOperation())
+		return
+	}
+
+	writeFakeLink(w, http.StatusCreated, fakeResourceLink(to))
+}
+
+// Link of a new asynchronous operation.
+func (d *FakeDisk) startOperation() map[string]interface{} {
+	d.operationID++
+	id := strconv.Itoa(d.operationID)
+	d.operations[id] = d.AsyncOperations
+
+	return map[string]interface{}{
+		"href":      "https://cloud-api.yandex.net" + fakeApiPrefix + "operations/" + id,
+		"method":    http.MethodGet,
+		"templated": false,
+	}
+}
+
+func (d *FakeDisk) serveOperation(w http.ResponseWriter, id string) {
+	remaining, ok := d.operations[id]
+	if !ok {
+		writeFakeError(w, http.StatusNotFound, "DiskNotFoundError")
+		return
+	}
+
+	status := "success"
+	if remaining > 0 {
+		status = "in-progress"
+		d.operations[id] = remaining - 1
+	}
+
+	writeFakeJson(w, http.StatusOK, map[string]string{"status": status})
+}
+
+func (d *FakeDisk) serveUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeFakeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+		return
+	}
+
+	content := &bytes.Buffer{}
+	if r.Body != nil {
+		if _, err := content.ReadFrom(r.Body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	upload, ok := d.uploads[strings.TrimPrefix(r.URL.Path, fakeUploadPrefix)]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	contentRange := r.Header.Get("Content-Range")
+	if contentRange == "" {
+		upload.received = content.Bytes()
+		d.completeUpload(w, upload)
+		return
+	}
+
+	// Partial upload: "bytes */total" queries received bytes,
+	// "bytes first-last/total" appends a chunk
+	var first, last, total int64
+	if _, err := fmt.Sscanf(contentRange, "bytes */%d", &total); err == nil {
+		first, last = -1, -1
+	} else if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &first, &last, &total); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if upload.done {
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	if first >= 0 {
+		if last-first+1 != int64(content.Len()) || last >= total {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Chunks which don't continue received content are ignored, client
+		// should query received bytes and continue from there
+		if first == int64(len(upload.received)) {
+			upload.received = append(upload.received, content.Bytes()...)
+		}
+	}
+
+	if int64(len(upload.received)) == total {
+		d.completeUpload(w, upload)
+		return
+	}
+
+	if len(upload.received) > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", len(upload.received)-1))
+	}
+	w.WriteHeader(http.StatusPermanentRedirect)
+}
+
+func (d *FakeDisk) maxFileSize() int64 {
+	if d.MaxFileSize > 0 {
+		return d.MaxFileSize
+	}
+
+	return int64(1) << 30
+}
+
+func (d *FakeDisk) completeUpload(w http.ResponseWriter, upload *fakeUpload) {
+	if int64(len(upload.received)) > d.maxFileSize() {
+		writeFakeError(w, http.StatusRequestEntityTooLarge, "FileTooBig")
+		return
+	}
+
+	upload.done = true
+	d.files[upload.path] = &FakeFile{Content: upload.received, Modified: time.Now()}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// Forget all upload sessions, so upload links respond with "404 Not Found"
+// as if they have expired.
+func (d *FakeDisk) ExpireUploads() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.uploads = make(map[string]*fakeUpload)
+}
+
+func (d *FakeDisk) serveDownload(w http.ResponseWriter, r *http.Request) {
+	p := cleanFakePath(strings.TrimPrefix(r.URL.Path, fakeDownloadPrefix))
+
+	d.mu.Lock()
+	f, ok := d.files[p]
+	_, isDir := d.dirs[p]
+	var archive []byte
+	if isDir {
+		archive = d.zipDir(p)
+	}
+	d.mu.Unlock()
+
+	if isDir {
+		w.Header().Set("Content-Type", "application/zip")
+		w.WriteHeader(http.StatusOK)
+		w.Write(archive)
+		return
+	}
+
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	sum := md5.Sum(f.Content)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	w.Header().Set("Content-Type", "application/octet-stream")
+
+	http.ServeContent(w, r, path.Base(p), f.Modified, bytes.NewReader(f.Content))
+}
+
+// ZIP archive of the directory like the one served by Yandex.Disk: entries
+// are put into a directory named after the archived one.
+func (d *FakeDisk) zipDir(p string) []byte {
+	prefix := p + "/"
+	if p == "/" {
+		prefix = "/"
+	}
+
+	var entries []string
+	for dir := range d.dirs {
+		if strings.HasPrefix(dir, prefix) {
+			entries = append(entries, dir+"/")
+		}
+	}
+	for file := range d.files {
+		if strings.HasPrefix(file, prefix) {
+			entries = append(entries, file)
+		}
+	}
+	sort.Strings(entries)
+
+	root := path.Base(p)
+	if p == "/" {
+		root = "disk"
+	}
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+
+	zw.CreateHeader(&zip.FileHeader{Name: root + "/", Modified: d.dirs[p]})
+
+	for _, entry := range entries {
+		name := root + "/" + strings.TrimPrefix(entry, prefix)
+
+		if strings.HasSuffix(entry, "/") {
+			zw.CreateHeader(&zip.FileHeader{Name: name, Modified: d.dirs[strings.TrimSuffix(entry, "/")]})
+			continue
+		}
+
+		f := d.files[entry]
+		fw, _ := zw.CreateHeader(&zip.FileHeader{Name: name, Modified: f.Modified, Method: zip.Deflate})
+		fw.Write(f.Content)
+	}
+
+	zw.Close()
+
+	return buf.Bytes()
+}
+
+func (d *FakeDisk) mkdirAll(p string) {
+	for ; p != "/"; p = path.Dir(p) {
+		if _, ok := d.dirs[p]; !ok {
+			d.dirs[p] = time.Now()
+		}
+	}
+}
+
+func (d *FakeDisk) removeTree(p string) {
+	for dir := range d.dirs {
+		if dir == p || strings.HasPrefix(dir, p+"/") {
+			delete(d.dirs, dir)
+		}
+	}
+	for file := range d.files {
+		if strings.HasPrefix(file, p+"/") {
+			delete(d.files, file)
+		}
+	}
+}
+
+func fakeFileResource(p string, f *FakeFile) map[string]interface{} {
+	md5sum := md5.Sum(f.Content)
+	sha256sum := sha256.Sum256(f.Content)
+
+	resource := map[string]interface{}{
+		"name":      path.Base(p),
+		"path":      "disk:" + p,
+		"type":      "file",
+		"size":      len(f.Content),
+		"md5":       hex.EncodeToString(md5sum[:]),
+		"sha256":    hex.EncodeToString(sha256sum[:]),
+		"created":   f.Modified.Format(time.RFC3339),
+		"modified":  f.Modified.Format(time.RFC3339),
+		"mime_type": "application/octet-stream",
+	}
+	if f.CustomProperties != nil {
+		resource["custom_properties"] = f.CustomProperties
+	}
+
+	return resource
+}
+
+func fakeDirResource(p string, modified time.Time) map[string]interface{} {
+	name := path.Base(p)
+	if p == "/" {
+		name = "disk"
+	}
+
+	return map[string]interface{}{
+		"name":     name,
+		"path":     "disk:" + p,
+		"type":     "dir",
+		"created":  modified.Format(time.RFC3339),
+		"modified": modified.Format(time.RFC3339),
+	}
+}
+
+func fakeResourceLink(p string) map[string]interface{} {
+	return map[string]interface{}{
+		"href":      "https://cloud-api.yandex.net/v1/disk/resources?path=" + url.QueryEscape("disk:"+p),
+		"method":    http.MethodGet,
+		"templated": false,
+	}
+}
+
+func sortFakeResources(resources []map[string]interface{}) {
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i]["name"].(string) < resources[j]["name"].(string)
+	})
+}
+
+func cleanFakePath(p string) string {
+	if i := strings.Index(p, ":/"); i >= 0 {
+		p = p[i+1:]
+	}
+	return path.Clean("/" + p)
+}
+
+func writeFakeLink(w http.ResponseWriter, statusCode int, link map[string]interface{}) {
+	writeFakeJson(w, statusCode, link)
+}
+
+func writeFakeError(w http.ResponseWriter, statusCode int, errorID string) {
+	writeFakeJson(w, statusCode, map[string]string{
+		"error":       errorID,
+		"description": fmt.Sprintf("fake %s", errorID),
+		"message":     errorID,
+	})
+}
+
+func writeFakeJson(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,336 @@
+// Package zipstream reads ZIP archives sequentially, without seeking, so an
+// archive could be extracted while it's being downloaded.
+//
+// Entries are read from their local headers, the central directory is not
+// used. Only stored and deflated entries are supported.
+package zipstream
+
+import (
+	"bufio"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+const (
+	localHeaderSignature     = 0x04034b50
+	dataDescriptorSignature  = 0x08074b50
+	centralHeaderSignature   = 0x02014b50
+	endOfCentralDirSignature = 0x06054b50
+	zip64EndSignature        = 0x06064b50
+
+	localHeaderLength = 26
+
+	flagEncrypted      = 0x1
+	flagDataDescriptor = 0x8
+
+	methodStore   = 0
+	methodDeflate = 8
+
+	extraZip64     = 0x0001
+	extraTimestamp = 0x5455
+
+	uint32Max = 0xffffffff
+)
+
+var (
+	ErrFormat    = errors.New("zipstream: not a valid zip file")
+	ErrAlgorithm = errors.New("zipstream: unsupported compression algorithm")
+	ErrEncrypted = errors.New("zipstream: encrypted entries are not supported")
+	ErrChecksum  = errors.New("zipstream: checksum error")
+)
+
+// Header of an archive entry.
+type Header struct {
+	// Slash-separated path of the entry as it's stored in the archive, it
+	// must be sanitized before it's used as a local path.
+	Name string
+
+	Modified time.Time
+
+	// Uncompressed size, -1 if it's not known until the entry is read.
+	Size int64
+}
+
+// Whether entry is a directory.
+func (h *Header) IsDir() bool {
+	return strings.HasSuffix(h.Name, "/")
+}
+
+// Reader of archive entries in the order they're stored.
+type Reader struct {
+	r *bufio.Reader
+
+	entry *entryReader
+	err   error
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Advance to the next entry, unread content of the current entry is skipped.
+// Method returns io.EOF when there are no more entries.
+func (zr *Reader) Next() (*Header, error) {
+	if zr.err != nil {
+		return nil, zr.err
+	}
+
+	if zr.entry != nil {
+		if _, err := io.Copy(ioutil.Discard, zr.entry); err != nil {
+			zr.err = err
+			return nil, err
+		}
+		zr.entry = nil
+	}
+
+	header, entry, err := zr.readLocalHeader()
+	if err != nil {
+		zr.err = err
+		return nil, err
+	}
+
+	zr.entry = entry
+
+	return header, nil
+}
+
+// Read content of the current entry. Checksum and size are verified once the
+// content is read, ErrChecksum is returned instead of io.EOF on mismatch.
+func (zr *Reader) Read(p []byte) (int, error) {
+	if zr.entry == nil {
+		return 0, io.EOF
+	}
+
+	return zr.entry.Read(p)
+}
+
+func (zr *Reader) readLocalHeader() (*Header, *entryReader, error) {
+	var buf [localHeaderLength]byte
+
+	if _, err := io.ReadFull(zr.r, buf[:4]); err != nil {
+		return nil, nil, unexpectedEOF(err)
+	}
+
+	switch binary.LittleEndian.Uint32(buf[:4]) {
+	case localHeaderSignature:
+	case centralHeaderSignature, endOfCentralDirSignature, zip64EndSignature:
+		return nil, nil, io.EOF
+	default:
+		return nil, nil, ErrFormat
+	}
+
+	if _, err := io.ReadFull(zr.r, buf[:]); err != nil {
+		return nil, nil, unexpectedEOF(err)
+	}
+
+	flags := binary.LittleEndian.Uint16(buf[2:])
+	method := binary.LittleEndian.Uint16(buf[4:])
+	modTime := binary.LittleEndian.Uint16(buf[6:])
+	modDate := binary.LittleEndian.Uint16(buf[8:])
+	crc := binary.LittleEndian.Uint32(buf[10:])
+	compressedSize := int64(binary.LittleEndian.Uint32(buf[14:]))
+	size := int64(binary.LittleEndian.Uint32(buf[18:]))
+	nameLength := int(binary.LittleEndian.Uint16(buf[22:]))
+	extraLength := int(binary.LittleEndian.Uint16(buf[24:]))
+
+	variable := make([]byte, nameLength+extraLength)
+	if _, err := io.ReadFull(zr.r, variable); err != nil {
+		return nil, nil, unexpectedEOF(err)
+	}
+
+	header := &Header{
+		Name:     string(variable[:nameLength]),
+		Modified: msDosTimeToTime(modDate, modTime),
+		Size:     size,
+	}
+
+	zip64 := false
+
+	extra := variable[nameLength:]
+	for len(extra) >= 4 {
+		tag := binary.LittleEndian.Uint16(extra)
+		length := int(binary.LittleEndian.Uint16(extra[2:]))
+		extra = extra[4:]
+		if length > len(extra) {
+			return nil, nil, ErrFormat
+		}
+		field := extra[:length]
+		extra = extra[length:]
+
+		switch tag {
+		case extraZip64:
+			zip64 = true
+
+			if size == uint32Max && len(field) >= 8 {
+				size = int64(binary.LittleEndian.Uint64(field))
+				field = field[8:]
+			}
+			if compressedSize == uint32Max && len(field) >= 8 {
+				compressedSize = int64(binary.LittleEndian.Uint64(field))
+			}
+		case extraTimestamp:
+			if len(field) >= 5 && field[0]&1 != 0 {
+				header.Modified = time.Unix(int64(int32(binary.LittleEndian.Uint32(field[1:]))), 0).UTC()
+			}
+		}
+	}
+
+	if flags&flagEncrypted != 0 {
+		return nil, nil, ErrEncrypted
+	}
+
+	entry := &entryReader{
+		r:        zr.r,
+		crc:      crc32.NewIEEE(),
+		expected: crc,
+		size:     size,
+		zip64:    zip64,
+	}
+
+	descriptor := flags&flagDataDescriptor != 0
+	if descriptor {
+		// Checksum and sizes follow the content
+		entry.descriptor = true
+		header.Size = -1
+	} else {
+		header.Size = size
+	}
+
+	switch method {
+	case methodStore:
+		if descriptor {
+			// End of stored content could not be found without its size,
+			// though some writers put it into local header anyway
+			if compressedSize == 0 || compressedSize == uint32Max {
+				return nil, nil, ErrAlgorithm
+			}
+			header.Size = compressedSize
+		}
+		entry.content = io.LimitReader(zr.r, compressedSize)
+	case methodDeflate:
+		// bufio.Reader is io.ByteReader, so decompressor doesn't read
+		// beyond the end of compressed content
+		entry.content = flate.NewReader(zr.r)
+	default:
+		return nil, nil, ErrAlgorithm
+	}
+
+	return header, entry, nil
+}
+
+// Reader of entry content, which verifies it at the end.
+type entryReader struct {
+	r       *bufio.Reader
+	content io.Reader
+
+	crc      hash.Hash32
+	expected uint32
+	size     int64
+	read     int64
+
+	descriptor bool
+	zip64      bool
+
+	err error
+}
+
+func (e *entryReader) Read(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	n, err := e.content.Read(p)
+	e.crc.Write(p[:n])
+	e.read += int64(n)
+
+	if err == io.EOF {
+		err = e.finish()
+	} else if err != nil {
+		err = unexpectedEOF(err)
+	}
+
+	if err != nil {
+		e.err = err
+	}
+
+	return n, err
+}
+
+// Read data descriptor if it's present and verify the content.
+func (e *entryReader) finish() error {
+	if e.descriptor {
+		if err := e.readDataDescriptor(); err != nil {
+			return err
+		}
+	} else if e.read < e.size {
+		return io.ErrUnexpectedEOF
+	}
+
+	if e.read != e.size || e.crc.Sum32() != e.expected {
+		return ErrChecksum
+	}
+
+	return io.EOF
+}
+
+func (e *entryReader) readDataDescriptor() error {
+	var buf [24]byte
+
+	// Signature is optional
+	if _, err := io.ReadFull(e.r, buf[:4]); err != nil {
+		return unexpectedEOF(err)
+	}
+	crc := buf[:4]
+	if binary.LittleEndian.Uint32(buf[:4]) == dataDescriptorSignature {
+		if _, err := io.ReadFull(e.r, buf[4:8]); err != nil {
+			return unexpectedEOF(err)
+		}
+		crc = buf[4:8]
+	}
+	e.expected = binary.LittleEndian.Uint32(crc)
+
+	// Sizes are 8 bytes long in zip64 archives, writers which don't add zip64
+	// extra field to local header use them only for large entries
+	if e.zip64 || e.read >= uint32Max {
+		if _, err := io.ReadFull(e.r, buf[8:24]); err != nil {
+			return unexpectedEOF(err)
+		}
+		e.size = int64(binary.LittleEndian.Uint64(buf[16:24]))
+	} else {
+		if _, err := io.ReadFull(e.r, buf[8:16]); err != nil {
+			return unexpectedEOF(err)
+		}
+		e.size = int64(binary.LittleEndian.Uint32(buf[12:16]))
+	}
+
+	return nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
+
+// Convert MS-DOS date and time, which have no time zone, to UTC time.
+func msDosTimeToTime(dosDate, dosTime uint16) time.Time {
+	return time.Date(
+		int(dosDate>>9+1980),
+		time.Month(dosDate>>5&0xf),
+		int(dosDate&0x1f),
+		int(dosTime>>11),
+		int(dosTime>>5&0x3f),
+		int(dosTime&0x1f*2),
+		0,
+		time.UTC,
+	)
+}
@@ -0,0 +1,192 @@
+package zipstream
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testEntry struct {
+	name     string
+	method   uint16
+	content  string
+	modified time.Time
+}
+
+func writeTestZip(t *testing.T, entries []testEntry) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+
+	for _, entry := range entries {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: entry.name, Method: entry.method, Modified: entry.modified})
+		assert.Nil(t, err)
+		w.Write([]byte(entry.content))
+	}
+
+	assert.Nil(t, zw.Close())
+
+	return buf.Bytes()
+}
+
+// Stored entry which has sizes in local header, followed by end of central
+// directory. Data descriptor follows the content if descriptor is true.
+func writeStoredZip(name, content string, descriptor bool) []byte {
+	buf := &bytes.Buffer{}
+
+	flags := uint16(0)
+	if descriptor {
+		flags = flagDataDescriptor
+	}
+
+	checksum := crc32.ChecksumIEEE([]byte(content))
+
+	fields := []interface{}{
+		uint32(localHeaderSignature),
+		uint16(10), // version
+		flags,
+		uint16(methodStore),
+		uint16(0), // time
+		uint16(0), // date
+		checksum,
+		uint32(len(content)),
+		uint32(len(content)),
+		uint16(len(name)),
+		uint16(0), // extra length
+	}
+	for _, field := range fields {
+		binary.Write(buf, binary.LittleEndian, field)
+	}
+	buf.WriteString(name)
+	buf.WriteString(content)
+
+	if descriptor {
+		for _, field := range []interface{}{uint32(dataDescriptorSignature), checksum, uint32(len(content)), uint32(len(content))} {
+			binary.Write(buf, binary.LittleEndian, field)
+		}
+	}
+
+	binary.Write(buf, binary.LittleEndian, uint32(endOfCentralDirSignature))
+	buf.Write(make([]byte, 18))
+
+	return buf.Bytes()
+}
+
+func TestReader(t *testing.T) {
+	modified := time.Date(2019, 4, 1, 12, 0, 0, 0, time.UTC)
+
+	entries := []testEntry{
+		{name: "dir/", modified: modified},
+		{name: "dir/file.txt", method: zip.Deflate, content: "CONTENT", modified: modified},
+		{name: "dir/empty.txt", method: zip.Deflate, modified: modified},
+		{name: "dir/big.bin", method: zip.Deflate, content: string(bytes.Repeat([]byte("0123456789"), 10000)), modified: modified},
+	}
+
+	zr := NewReader(bytes.NewReader(writeTestZip(t, entries)))
+
+	for _, entry := range entries {
+		header, err := zr.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, entry.name, header.Name)
+		assert.True(t, entry.modified.Equal(header.Modified), header.Modified)
+		assert.Equal(t, entry.name == "dir/", header.IsDir())
+
+		content, err := ioutil.ReadAll(zr)
+		assert.Nil(t, err)
+		assert.Equal(t, entry.content, string(content))
+	}
+
+	_, err := zr.Next()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReader_skipsUnreadContent(t *testing.T) {
+	zr := NewReader(bytes.NewReader(writeTestZip(t, []testEntry{
+		{name: "a.txt", method: zip.Deflate, content: "AAAA"},
+		{name: "b.txt", method: zip.Deflate, content: "BBBB"},
+	})))
+
+	_, err := zr.Next()
+	assert.Nil(t, err)
+
+	header, err := zr.Next()
+	assert.Nil(t, err)
+	assert.Equal(t, "b.txt", header.Name)
+
+	content, err := ioutil.ReadAll(zr)
+	assert.Nil(t, err)
+	assert.Equal(t, "BBBB", string(content))
+}
+
+func TestReader_stored(t *testing.T) {
+	for _, descriptor := range []bool{false, true} {
+		zr := NewReader(bytes.NewReader(writeStoredZip("file.txt", "CONTENT", descriptor)))
+
+		header, err := zr.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(7), header.Size)
+
+		content, err := ioutil.ReadAll(zr)
+		assert.Nil(t, err)
+		assert.Equal(t, "CONTENT", string(content))
+
+		_, err = zr.Next()
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func TestReader_errors(t *testing.T) {
+	archive := writeStoredZip("file.txt", "CONTENT", false)
+
+	tests := []struct {
+		name    string
+		archive []byte
+
+		error error
+	}{
+		{
+			name:    "corrupted content",
+			archive: bytes.Replace(archive, []byte("CONTENT"), []byte("CORRUPT"), 1),
+
+			error: ErrChecksum,
+		},
+
+		{
+			name:    "truncated archive",
+			archive: archive[:40],
+
+			error: io.ErrUnexpectedEOF,
+		},
+
+		{
+			name:    "not an archive",
+			archive: []byte("CONTENT"),
+
+			error: ErrFormat,
+		},
+
+		{
+			name:    "stored entry with data descriptor and without sizes",
+			archive: writeTestZip(t, []testEntry{{name: "file.txt", method: zip.Store, content: "CONTENT"}}),
+
+			error: ErrAlgorithm,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			zr := NewReader(bytes.NewReader(test.archive))
+
+			_, err := zr.Next()
+			if err == nil {
+				_, err = ioutil.ReadAll(zr)
+			}
+			assert.Equal(t, test.error, err)
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package yadisk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type linkKind int
+
+const (
+	linkKindOther linkKind = iota
+	linkKindUpload
+	linkKindDownload
+)
+
+const (
+	// Upload link is accessible for 30 minutes according to documentation.
+	uploadLinkLifetime = 30 * time.Minute
+
+	// Download link lifetime is not documented, it's assumed to be the same.
+	downloadLinkLifetime = 30 * time.Minute
+
+	// Link is considered expired a bit earlier, so it doesn't expire while
+	// the transfer is being started.
+	linkExpirySkew = time.Minute
+)
+
+// ErrLinkNotRenewable is returned when link has not been obtained with
+// RequestUploadLink or RequestDownloadLink, so there's no way to renew it.
+var ErrLinkNotRenewable = errors.New("yadisk: link could not be renewed")
+
+// Time when upload or download link expires. It's zero for links which
+// don't expire.
+func (l *Link) ExpiresAt() time.Time {
+	switch {
+	case l.IssuedAt.IsZero():
+		return time.Time{}
+	case l.kind == linkKindUpload:
+		return l.IssuedAt.Add(uploadLinkLifetime)
+	case l.kind == linkKindDownload:
+		return l.IssuedAt.Add(downloadLinkLifetime)
+	default:
+		return time.Time{}
+	}
+}
+
+// Whether the upload or download link has expired (or is about to expire in
+// a minute).
+func (l *Link) Expired() bool {
+	return l.expiredAt(time.Now())
+}
+
+func (l *Link) expiredAt(now time.Time) bool {
+	expiresAt := l.ExpiresAt()
+	return !expiresAt.IsZero() && !now.Before(expiresAt.Add(-linkExpirySkew))
+}
+
+// Request new link with the same parameters as the given one and replace
+// the given link with it.
+//
+// Method returns ErrLinkNotRenewable if link has not been obtained with
+// RequestUploadLink or RequestDownloadLink.
+func (c *Client) RenewLink(ctx context.Context, link *Link) error {
+	var (
+		renewed *Link
+		err     error
+	)
+
+	switch link.kind {
+	case linkKindUpload:
+		renewed, err = c.RequestUploadLink(ctx, link.path, link.overwrite)
+	case linkKindDownload:
+		if c.linkCache != nil {
+			c.linkCache.invalidate(link.path)
+		}
+		renewed, err = c.RequestDownloadLink(ctx, link.path)
+	default:
+		return ErrLinkNotRenewable
+	}
+
+	if err != nil {
+		return err
+	}
+
+	*link = *renewed
+
+	return nil
+}
+
+// Upload file's content to the requested link, the link is renewed first if
+// it has expired. See Upload for details.
+func (c *Client) UploadWithFreshLink(ctx context.Context, link *Link, r io.Reader) (int, error) {
+	if err := c.renewExpiredLink(ctx, link); err != nil {
+		return 0, err
+	}
+
+	return c.Upload(ctx, link, r)
+}
+
+// Download file from the given link, the link is renewed first if it has
+// expired. See Download for details.
+func (c *Client) DownloadWithFreshLink(ctx context.Context, link *Link) (*http.Response, error) {
+	if err := c.renewExpiredLink(ctx, link); err != nil {
+		return nil, err
+	}
+
+	return c.Download(ctx, link)
+}
+
+func (c *Client) renewExpiredLink(ctx context.Context, link *Link) error {
+	if !link.expiredAt(c.now()) {
+		return nil
+	}
+
+	return c.RenewLink(ctx, link)
+}
+
+// Enable cache of download links: RequestDownloadLink returns previously
+// requested link of the same path if it has been obtained less than ttl ago
+// and hasn't expired. Zero ttl disables the cache.
+//
+// Cached links are invalidated by client's mutations of their paths, stale
+// links are swept as new ones are cached.
+func (c *Client) SetDownloadLinkCache(ttl time.Duration) {
+	if ttl <= 0 {
+		c.linkCache = nil
+		return
+	}
+
+	c.linkCache = &linkCache{
+		ttl:     ttl,
+		links:   make(map[string]*Link),
+		sweepAt: minLinkCacheSweep,
+	}
+}
+
+// Number of cached links which triggers the first sweep of stale ones.
+const minLinkCacheSweep = 256
+
+type linkCache struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	links map[string]*Link
+
+	// Number of cached links which triggers the next sweep. It's twice the
+	// number of links left by the previous sweep, so sweeps take amortized
+	// constant time.
+	sweepAt int
+}
+
+func (lc *linkCache) get(p string, now time.Time) *Link {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	p = normalizePath(p)
+
+	link, ok := lc.links[p]
+	if !ok {
+		return nil
+	}
+
+	if lc.stale(link, now) {
+		delete(lc.links, p)
+		return nil
+	}
+
+	// Callers may renew the link in place
+	l := *link
+	return &l
+}
+
+// Cache the link. Stale links are swept from time to time, so links of paths
+// which are never requested again don't pile up.
+func (lc *linkCache) put(p string, link *Link, now time.Time) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	l := *link
+	lc.links[normalizePath(p)] = &l
+
+	if len(lc.links) < lc.sweepAt {
+		return
+	}
+
+	for key, link := range lc.links {
+		if lc.stale(link, now) {
+			delete(lc.links, key)
+		}
+	}
+
+	lc.sweepAt = 2 * len(lc.links)
+	if lc.sweepAt < minLinkCacheSweep {
+		lc.sweepAt = minLinkCacheSweep
+	}
+}
+
+func (lc *linkCache) stale(link *Link, now time.Time) bool {
+	return link.expiredAt(now) || now.Sub(link.IssuedAt) >= lc.ttl
+}
+
+func (lc *linkCache) invalidate(paths ...string) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	for _, p := range paths {
+		p = normalizePath(p)
+
+		for key := range lc.links {
+			if key == p || isDescendant(key, p) {
+				delete(lc.links, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package yadisk
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/go-yandex-disk/internal/testhelpers"
+)
+
+var testNow = time.Date(2019, 4, 1, 12, 0, 0, 0, time.UTC)
+
+func TestLink_Expired(t *testing.T) {
+	tests := []struct {
+		name string
+
+		link Link
+		now  time.Time
+
+		expiresAt time.Time
+		expired   bool
+	}{
+		{
+			name: "fresh upload link",
+
+			link: Link{IssuedAt: testNow, kind: linkKindUpload},
+			now:  testNow.Add(10 * time.Minute),
+
+			expiresAt: testNow.Add(30 * time.Minute),
+			expired:   false,
+		},
+
+		{
+			name: "upload link about to expire",
+
+			link: Link{IssuedAt: testNow, kind: linkKindUpload},
+			now:  testNow.Add(29*time.Minute + 30*time.Second),
+
+			expiresAt: testNow.Add(30 * time.Minute),
+			expired:   true,
+		},
+
+		{
+			name: "expired download link",
+
+			link: Link{IssuedAt: testNow, kind: linkKindDownload},
+			now:  testNow.Add(time.Hour),
+
+			expiresAt: testNow.Add(30 * time.Minute),
+			expired:   true,
+		},
+
+		{
+			name: "operation link never expires",
+
+			link: Link{Href: "some_href"},
+			now:  testNow.Add(time.Hour),
+
+			expiresAt: time.Time{},
+			expired:   false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expiresAt, test.link.ExpiresAt())
+			assert.Equal(t, test.expired, test.link.expiredAt(test.now))
+		})
+	}
+}
+
+// Fake API which issues links "link-1", "link-2"... and records every request.
+func newLinkTestClient(requests *[]string) *Client {
+	issued := 0
+
+	return New(testhelpers.NewTestClient(func(req *http.Request) *http.Response {
+		*requests = append(*requests, req.Method+" "+req.URL.Path)
+
+		switch req.URL.Path {
+		case "/v1/disk/resources/upload", "/v1/disk/resources/download":
+			issued++
+			method := "PUT"
+			if strings.HasSuffix(req.URL.Path, "download") {
+				method = "GET"
+			}
+
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"href":"https://storage.yandex.net/link-` + strconv.Itoa(issued) + `","method":"` + method + `"}`)),
+			}
+		default:
+			return &http.Response{
+				StatusCode: 201,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`content`)),
+			}
+		}
+	}))
+}
+
+func TestClient_UploadWithFreshLink(t *testing.T) {
+	var requests []string
+
+	client := newLinkTestClient(&requests)
+	now := testNow
+	client.now = func() time.Time { return now }
+
+	link, err := client.RequestUploadLink(context.Background(), "/file", true)
+	assert.Nil(t, err)
+
+	statusCode, err := client.UploadWithFreshLink(context.Background(), link, strings.NewReader("content"))
+	assert.Nil(t, err)
+	assert.Equal(t, 201, statusCode)
+
+	now = now.Add(time.Hour)
+
+	statusCode, err = client.UploadWithFreshLink(context.Background(), link, strings.NewReader("content"))
+	assert.Nil(t, err)
+	assert.Equal(t, 201, statusCode)
+
+	// Link is renewed in place
+	assert.Equal(t, "https://storage.yandex.net/link-2", link.Href)
+	assert.Equal(t, now, link.IssuedAt)
+	assert.True(t, link.overwrite)
+
+	assert.Equal(t, []string{
+		"GET /v1/disk/resources/upload",
+		"PUT /link-1",
+		"GET /v1/disk/resources/upload",
+		"PUT /link-2",
+	}, requests)
+}
+
+func TestClient_DownloadWithFreshLink(t *testing.T) {
+	var requests []string
+
+	client := newLinkTestClient(&requests)
+	now := testNow
+	client.now = func() time.Time { return now }
+
+	link, err := client.RequestDownloadLink(context.Background(), "/file")
+	assert.Nil(t, err)
+
+	now = now.Add(time.Hour)
+
+	resp, err := client.DownloadWithFreshLink(context.Background(), link)
+	assert.Nil(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, []string{
+		"GET /v1/disk/resources/download",
+		"GET /v1/disk/resources/download",
+		"GET /link-2",
+	}, requests)
+
+	t.Run("not renewable link", func(t *testing.T) {
+		link := &Link{Href: "some_href", Method: "GET"}
+
+		assert.Equal(t, ErrLinkNotRenewable, client.RenewLink(context.Background(), link))
+	})
+}
+
+func TestClient_SetDownloadLinkCache(t *testing.T) {
+	var requests []string
+
+	client := newLinkTestClient(&requests)
+	now := testNow
+	client.now = func() time.Time { return now }
+	client.SetDownloadLinkCache(5 * time.Minute)
+
+	first, err := client.RequestDownloadLink(context.Background(), "/dir/file")
+	assert.Nil(t, err)
+
+	second, err := client.RequestDownloadLink(context.Background(), "disk:/dir/file")
+	assert.Nil(t, err)
+	assert.Equal(t, first, second)
+	assert.Len(t, requests, 1)
+
+	// Cached link is dropped after ttl
+	now = now.Add(6 * time.Minute)
+	third, err := client.RequestDownloadLink(context.Background(), "/dir/file")
+	assert.Nil(t, err)
+	assert.NotEqual(t, first.Href, third.Href)
+	assert.Len(t, requests, 2)
+
+	// Cached link is dropped when resource changes
+	client.Delete(context.Background(), "/dir", false)
+	_, err = client.RequestDownloadLink(context.Background(), "/dir/file")
+	assert.Nil(t, err)
+	assert.Len(t, requests, 4)
+}
+
+func TestClient_SetDownloadLinkCache_sweep(t *testing.T) {
+	var requests []string
+
+	client := newLinkTestClient(&requests)
+	now := testNow
+	client.now = func() time.Time { return now }
+	client.SetDownloadLinkCache(5 * time.Minute)
+
+	for i := 0; i < minLinkCacheSweep-1; i++ {
+		_, err := client.RequestDownloadLink(context.Background(), fmt.Sprintf("/file-%d", i))
+		assert.Nil(t, err)
+	}
+	assert.Len(t, client.linkCache.links, minLinkCacheSweep-1)
+
+	// Links of paths which aren't requested again are swept once they're stale
+	now = now.Add(6 * time.Minute)
+	_, err := client.RequestDownloadLink(context.Background(), "/file")
+	assert.Nil(t, err)
+	assert.Len(t, client.linkCache.links, 1)
+}
@@ -0,0 +1,89 @@
+package yadisk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrNotDirectory is matched (with errors.Is) by NotDirectoryError.
+var ErrNotDirectory = errors.New("yadisk: not a directory")
+
+// Error returned when remote path is expected to be a directory, but it's
+// a file.
+type NotDirectoryError struct {
+	Path string
+}
+
+func (err NotDirectoryError) Error() string {
+	return "yadisk: not a directory: " + err.Path
+}
+
+func (err NotDirectoryError) Is(target error) bool {
+	return target == ErrNotDirectory
+}
+
+// Create directory along with any missing parents.
+//
+// path - The path to the folder being created.
+//
+// Existing directory is not an error. If the path or any of its parents is a
+// file, method returns NotDirectoryError.
+//
+// Directory is created with a single request if its parent exists, otherwise
+// parents are created one by one. Concurrent calls share requests to create
+// the same directory, so it's safe to create overlapping paths from several
+// goroutines. Each call stops waiting once its own context is done, shared
+// requests are canceled once all of the calls have stopped waiting.
+func (c *Client) MkdirAll(ctx context.Context, path string) error {
+	path = normalizePath(path)
+
+	_, err := c.mkdirs.do(ctx, path, func(ctx context.Context) (interface{}, error) {
+		return nil, c.mkdirAll(ctx, path)
+	})
+
+	return err
+}
+
+func (c *Client) mkdirAll(ctx context.Context, path string) error {
+	_, err := c.CreateDirectory(ctx, path)
+
+	switch mkdirErrorID(err) {
+	case "":
+		return err
+	case "DiskPathPointsToExistentDirectoryError":
+		return nil
+	case "DiskResourceAlreadyExistsError":
+		return NotDirectoryError{Path: path}
+	case "DiskPathDoesntExistsError":
+		parent := parentPath(path)
+		if parent == path {
+			return err
+		}
+
+		if err := c.MkdirAll(ctx, parent); err != nil {
+			return err
+		}
+
+		// Directory could have been created meanwhile
+		_, err = c.CreateDirectory(ctx, path)
+		switch mkdirErrorID(err) {
+		case "DiskPathPointsToExistentDirectoryError":
+			return nil
+		case "DiskResourceAlreadyExistsError":
+			return NotDirectoryError{Path: path}
+		}
+	}
+
+	return err
+}
+
+// ID of 409 Conflict error of CreateDirectory, empty string for other errors.
+func mkdirErrorID(err error) string {
+	apiErr, ok := err.(ApiError)
+	if !ok || apiErr.StatusCode != http.StatusConflict {
+		return ""
+	}
+
+	return apiErr.ErrorID
+}
@@ -0,0 +1,136 @@
+package yadisk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_MkdirAll(t *testing.T) {
+	tests := []struct {
+		name string
+
+		path string
+
+		dirs     []string
+		requests int
+		error    error
+	}{
+		{
+			name: "existing parent",
+			path: "/dir/new",
+
+			dirs:     []string{"/dir/new"},
+			requests: 1,
+		},
+
+		{
+			name: "missing parents",
+			path: "/dir/a/b/c",
+
+			dirs:     []string{"/dir/a", "/dir/a/b", "/dir/a/b/c"},
+			requests: 5,
+		},
+
+		{
+			name: "existing directory",
+			path: "/dir",
+
+			dirs:     []string{"/dir"},
+			requests: 1,
+		},
+
+		{
+			name: "path with scheme",
+			path: "disk:/dir/a/",
+
+			dirs:     []string{"/dir/a"},
+			requests: 1,
+		},
+
+		{
+			name: "file in place of directory",
+			path: "/dir/file.txt",
+
+			requests: 1,
+			error:    NotDirectoryError{Path: "/dir/file.txt"},
+		},
+
+		{
+			name: "file in place of parent",
+			path: "/dir/file.txt/a/b",
+
+			requests: 3,
+			error:    NotDirectoryError{Path: "/dir/file.txt"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, disk := newFakeDiskClient()
+			disk.PutFile("/dir/file.txt", []byte("CONTENT"))
+
+			since := len(disk.Requests)
+
+			err := client.MkdirAll(context.Background(), test.path)
+			assert.Equal(t, test.error, err)
+			assert.Equal(t, test.error != nil, errors.Is(err, ErrNotDirectory))
+			assert.Equal(t, test.requests, countRequests(disk, since, "PUT /v1/disk/resources"))
+
+			for _, p := range test.dirs {
+				assert.True(t, disk.Dir(p), p)
+			}
+		})
+	}
+}
+
+func TestClient_MkdirAll_Concurrent(t *testing.T) {
+	client, disk := newFakeDiskClient()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			err := client.MkdirAll(context.Background(), fmt.Sprintf("/a/b/c%d/d", i%3))
+			assert.Nil(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, disk.Dir(fmt.Sprintf("/a/b/c%d/d", i)))
+	}
+}
+
+func TestClient_MkdirAll_CanceledCaller(t *testing.T) {
+	client, disk := newFakeDiskClient()
+	disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		time.Sleep(50 * time.Millisecond)
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		// Joins the call of the canceled caller
+		time.Sleep(10 * time.Millisecond)
+		assert.Nil(t, client.MkdirAll(context.Background(), "/a/b"))
+	}()
+
+	assert.Equal(t, context.Canceled, client.MkdirAll(ctx, "/a/b"))
+
+	wg.Wait()
+	assert.True(t, disk.Dir("/a/b"))
+}
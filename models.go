@@ -15,6 +15,22 @@ type Link struct {
 
 	// Indicates a URL template according to RFC 6570.
 	Templated bool `json:"templated"`
+
+	// Time when upload or download link has been obtained (not included in
+	// original json). It's zero for other links, they don't expire.
+	IssuedAt time.Time `json:"-"`
+
+	// Path of the resource the link has been requested for (if any).
+	path string
+
+	// Paths affected by asynchronous operation, their cached metadata is
+	// invalidated again once the operation is finished
+	affected []string
+
+	// Kind of the link and parameters it has been requested with, they are
+	// used to renew expired link
+	kind      linkKind
+	overwrite bool
 }
 
 // Resource type.
@@ -83,6 +99,9 @@ type Resource struct {
 	// MD5 hash of the file.
 	Md5 string `json:"md5"`
 
+	// SHA256 hash of the file.
+	Sha256 string `json:"sha256"`
+
 	// Resource type.
 	Type ResourceType `json:"type"`
 
@@ -122,7 +141,7 @@ type ResourceList struct {
 	Path string `json:"path"`
 
 	// The total number of resources in the folder.
-	Total string `json:"total"`
+	Total int64 `json:"total"`
 }
 
 // Flat list of all files on Yandex.Disk in alphabetical order.
@@ -0,0 +1,74 @@
+package yadisk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const methodGetOperation = http.MethodGet
+
+// ErrOperationFailed is returned when asynchronous operation has finished
+// with "failure" status.
+var ErrOperationFailed = errors.New("yadisk: operation failed")
+
+// Status of asynchronous operation.
+//
+// link - Link returned with "202 Accepted" by Copy, Move or Delete.
+//
+// Method returns Operation or error.
+//
+// See: https://tech.yandex.com/disk/api/reference/operations-docpage/
+func (c *Client) GetOperation(ctx context.Context, link *Link) (*Operation, error) {
+	var operation Operation
+
+	_, err := c.doRequestAndDecode(ctx, methodGetOperation, link.Href, nil, nil, &operation)
+	if err != nil {
+		return nil, err
+	}
+
+	// Metadata could have been cached while operation was in progress
+	if operation.Status == OperationStatusSuccess || operation.Status == OperationStatusFailure {
+		c.invalidateCache(link.affected...)
+	}
+
+	return &operation, nil
+}
+
+// Wait until asynchronous operation finishes.
+//
+// link - Link returned with "202 Accepted" by Copy, Move or Delete.
+// interval - Interval between status requests. 1 second by default.
+//
+// Method returns nil if operation has succeeded and ErrOperationFailed if it
+// has failed. If context is done before operation finishes, method returns
+// context's error.
+func (c *Client) WaitOperation(ctx context.Context, link *Link, interval time.Duration) error {
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	for {
+		operation, err := c.GetOperation(ctx, link)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		switch operation.Status {
+		case OperationStatusSuccess:
+			return nil
+		case OperationStatusFailure:
+			return ErrOperationFailed
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
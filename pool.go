@@ -0,0 +1,360 @@
+package yadisk
+
+import (
+	"container/list"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/time/rate"
+)
+
+// Options of the client pool.
+type PoolOptions struct {
+	// OAuth config used to refresh tokens. It is required.
+	Config *oauth2.Config
+
+	// Returns token store of the account with the given UID. It is required.
+	TokenStore func(uid string) TokenStore
+
+	// Base transport shared by all clients. http.DefaultTransport is used by
+	// default.
+	Transport http.RoundTripper
+
+	// Requests rate limit shared by all accounts. Zero means no limit.
+	RateLimit rate.Limit
+	Burst     int
+
+	// Requests rate limit of each account. Zero means no limit.
+	AccountRateLimit rate.Limit
+	AccountBurst     int
+
+	// Clients which haven't been used for this duration are evicted from the
+	// pool. Zero means clients are never evicted.
+	IdleTimeout time.Duration
+}
+
+// Pool of clients for multiple Yandex users identified by their UID
+// (Disk.User.UID).
+//
+// Clients are built lazily from the account's token store and share one
+// transport and one rate limiter, while each account has its own rate limiter
+// as well. Account's limiter is kept when its client is evicted or removed,
+// so the rebuilt client doesn't get fresh rate quota.
+type Pool struct {
+	opts    PoolOptions
+	limiter *rate.Limiter
+
+	// Used by token sources to refresh tokens
+	ctx context.Context
+
+	mu      sync.Mutex
+	clients map[string]*poolEntry
+
+	// Entries ordered by last use, the least recently used one is at the back
+	lru *list.List
+
+	// Account limiters outlive clients, so rebuilt client doesn't get fresh
+	// rate quota. Limiter is dropped once it's been released for long enough
+	// to be refilled.
+	limiters map[string]*accountLimiter
+
+	// Released limiters in the order of release
+	released *list.List
+
+	now func() time.Time
+}
+
+type poolEntry struct {
+	uid      string
+	client   *Client
+	lastUsed time.Time
+
+	elem *list.Element
+}
+
+type accountLimiter struct {
+	uid     string
+	limiter *rate.Limiter
+
+	// Time when the account's client has been removed from the pool, elem is
+	// nil while the client is in the pool
+	releasedAt time.Time
+	elem       *list.Element
+}
+
+// Create client pool.
+func NewPool(opts PoolOptions) *Pool {
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{
+		Transport: opts.Transport,
+	})
+
+	return &Pool{
+		opts:     opts,
+		limiter:  newRateLimiter(opts.RateLimit, opts.Burst),
+		ctx:      ctx,
+		clients:  make(map[string]*poolEntry),
+		lru:      list.New(),
+		limiters: make(map[string]*accountLimiter),
+		released: list.New(),
+		now:      time.Now,
+	}
+}
+
+// Get client of the account with the given UID.
+//
+// Method returns ErrTokenNotFound if the account's token store is empty.
+func (p *Pool) Get(uid string) (*Client, error) {
+	p.mu.Lock()
+	client, ok := p.lookup(uid)
+	p.mu.Unlock()
+
+	if ok {
+		return client, nil
+	}
+
+	store := p.opts.TokenStore(uid)
+
+	// Make sure the account is known before building the client. Store is
+	// accessed without the lock, so a slow one doesn't block other accounts
+	if _, err := store.Load(); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Client could have been built by concurrent Get meanwhile
+	if client, ok := p.lookup(uid); ok {
+		return client, nil
+	}
+
+	client = p.newClient(NewStoreTokenSource(p.ctx, p.opts.Config, store), p.acquireLimiter(uid))
+
+	entry := &poolEntry{
+		uid:      uid,
+		client:   client,
+		lastUsed: p.now(),
+	}
+	entry.elem = p.lru.PushFront(entry)
+	p.clients[uid] = entry
+
+	return client, nil
+}
+
+// Client of the account if it's in the pool, p.mu must be held.
+func (p *Pool) lookup(uid string) (*Client, bool) {
+	now := p.now()
+	p.evictIdle(now)
+
+	entry, ok := p.clients[uid]
+	if !ok {
+		return nil, false
+	}
+
+	entry.lastUsed = now
+	p.lru.MoveToFront(entry.elem)
+
+	return entry.client, true
+}
+
+// Add account with the given token to the pool.
+//
+// Account's UID is determined with GetDisk request, then the token (refreshed
+// by the request if it has expired) is saved to the account's token store.
+//
+// Method returns UID and client of the account.
+func (p *Pool) Add(ctx context.Context, token *oauth2.Token) (string, *Client, error) {
+	source := p.opts.Config.TokenSource(p.ctx, token)
+
+	// Account is unknown yet, so the request is limited by a limiter of its own
+	limiter := newRateLimiter(p.opts.AccountRateLimit, p.opts.AccountBurst)
+
+	disk, err := p.newClient(source, limiter).GetDisk(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	uid := disk.User.UID
+
+	// Token source returns the refreshed token if it has been refreshed
+	token, err = source.Token()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := p.opts.TokenStore(uid).Save(token); err != nil {
+		return "", nil, err
+	}
+
+	// Previous client of the account (if any) uses an outdated token
+	p.Remove(uid)
+
+	client, err := p.Get(uid)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return uid, client, nil
+}
+
+// Remove client of the account from the pool. The account's token store is
+// left intact, so the client will be built again on the next Get.
+func (p *Pool) Remove(uid string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if entry, ok := p.clients[uid]; ok {
+		p.removeEntry(entry, p.now())
+	}
+}
+
+// Evict clients which haven't been used for longer than IdleTimeout. It's
+// also done on every Get.
+//
+// Method returns number of evicted clients.
+func (p *Pool) EvictIdle() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.evictIdle(p.now())
+}
+
+// Number of clients in the pool.
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.clients)
+}
+
+// Evict idle clients starting from the least recently used one, p.mu must
+// be held.
+func (p *Pool) evictIdle(now time.Time) int {
+	p.pruneLimiters(now)
+
+	if p.opts.IdleTimeout <= 0 {
+		return 0
+	}
+
+	evicted := 0
+
+	for elem := p.lru.Back(); elem != nil; elem = p.lru.Back() {
+		entry := elem.Value.(*poolEntry)
+		if now.Sub(entry.lastUsed) <= p.opts.IdleTimeout {
+			break
+		}
+
+		p.removeEntry(entry, now)
+		evicted++
+	}
+
+	return evicted
+}
+
+func (p *Pool) removeEntry(entry *poolEntry, now time.Time) {
+	delete(p.clients, entry.uid)
+	p.lru.Remove(entry.elem)
+
+	if limiter, ok := p.limiters[entry.uid]; ok && limiter.elem == nil {
+		limiter.releasedAt = now
+		limiter.elem = p.released.PushBack(limiter)
+	}
+}
+
+// Limiter of the account, it's created unless it's kept since the account's
+// previous client. Nil means no limit.
+func (p *Pool) acquireLimiter(uid string) *rate.Limiter {
+	if p.opts.AccountRateLimit <= 0 {
+		return nil
+	}
+
+	limiter, ok := p.limiters[uid]
+	if !ok {
+		limiter = &accountLimiter{uid: uid, limiter: newRateLimiter(p.opts.AccountRateLimit, p.opts.AccountBurst)}
+		p.limiters[uid] = limiter
+	}
+
+	if limiter.elem != nil {
+		p.released.Remove(limiter.elem)
+		limiter.elem = nil
+	}
+
+	return limiter.limiter
+}
+
+// Drop released limiters which have been refilled, so they're the same as
+// new ones.
+func (p *Pool) pruneLimiters(now time.Time) {
+	if p.opts.AccountRateLimit <= 0 {
+		return
+	}
+
+	burst := p.opts.AccountBurst
+	if burst <= 0 {
+		burst = 1
+	}
+	refill := time.Duration(float64(burst) / float64(p.opts.AccountRateLimit) * float64(time.Second))
+
+	for elem := p.released.Front(); elem != nil; elem = p.released.Front() {
+		limiter := elem.Value.(*accountLimiter)
+		if now.Sub(limiter.releasedAt) < refill {
+			break
+		}
+
+		p.released.Remove(elem)
+		delete(p.limiters, limiter.uid)
+	}
+}
+
+func (p *Pool) newClient(source oauth2.TokenSource, limiter *rate.Limiter) *Client {
+	return New(&http.Client{
+		Transport: &oauth2.Transport{
+			Source: source,
+			Base: &rateLimitedTransport{
+				base: p.opts.Transport,
+				// Account's limiter goes first, so the account doesn't occupy
+				// shared limit while it is waiting for its own
+				limiters: []*rate.Limiter{limiter, p.limiter},
+			},
+		},
+	})
+}
+
+// Transport which waits for every limiter before performing a request.
+type rateLimitedTransport struct {
+	base     http.RoundTripper
+	limiters []*rate.Limiter
+}
+
+func (t *rateLimitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for _, limiter := range t.limiters {
+		if limiter == nil {
+			continue
+		}
+
+		if err := limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.base.RoundTrip(req)
+}
+
+func newRateLimiter(limit rate.Limit, burst int) *rate.Limiter {
+	if limit <= 0 {
+		return nil
+	}
+
+	if burst <= 0 {
+		burst = 1
+	}
+
+	return rate.NewLimiter(limit, burst)
+}
@@ -0,0 +1,251 @@
+package yadisk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+	"golang.org/x/time/rate"
+
+	"github.com/yurykabanov/go-yandex-disk/internal/testhelpers"
+)
+
+func newTestPool(stores map[string]*MemoryTokenStore, requests *[]string) *Pool {
+	uids := map[string]string{
+		"Bearer token-1": "1001",
+		"Bearer token-2": "1002",
+	}
+
+	return NewPool(PoolOptions{
+		Config: &oauth2.Config{},
+		TokenStore: func(uid string) TokenStore {
+			if _, ok := stores[uid]; !ok {
+				stores[uid] = NewMemoryTokenStore(nil)
+			}
+			return stores[uid]
+		},
+		Transport: testhelpers.RoundTripFunc(func(req *http.Request) *http.Response {
+			auth := req.Header.Get("Authorization")
+			*requests = append(*requests, auth)
+
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"user":{"uid":"` + uids[auth] + `"}}`)),
+			}
+		}),
+		IdleTimeout: time.Minute,
+	})
+}
+
+func TestPool(t *testing.T) {
+	stores := map[string]*MemoryTokenStore{}
+	var requests []string
+
+	pool := newTestPool(stores, &requests)
+
+	t.Run("unknown account", func(t *testing.T) {
+		client, err := pool.Get("1001")
+		assert.Nil(t, client)
+		assert.Equal(t, ErrTokenNotFound, err)
+	})
+
+	t.Run("add accounts", func(t *testing.T) {
+		uid, client, err := pool.Add(context.Background(), &oauth2.Token{AccessToken: "token-1"})
+		assert.Nil(t, err)
+		assert.NotNil(t, client)
+		assert.Equal(t, "1001", uid)
+
+		uid, _, err = pool.Add(context.Background(), &oauth2.Token{AccessToken: "token-2"})
+		assert.Nil(t, err)
+		assert.Equal(t, "1002", uid)
+
+		token, err := stores["1002"].Load()
+		assert.Nil(t, err)
+		assert.Equal(t, "token-2", token.AccessToken)
+
+		assert.Equal(t, 2, pool.Len())
+	})
+
+	t.Run("clients use their own tokens", func(t *testing.T) {
+		requests = nil
+
+		client, err := pool.Get("1002")
+		assert.Nil(t, err)
+
+		disk, err := client.GetDisk(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, "1002", disk.User.UID)
+
+		again, err := pool.Get("1002")
+		assert.Nil(t, err)
+		assert.True(t, client == again)
+
+		assert.Equal(t, []string{"Bearer token-2"}, requests)
+	})
+
+	t.Run("idle clients are evicted", func(t *testing.T) {
+		now := time.Now()
+		pool.now = func() time.Time { return now }
+
+		_, err := pool.Get("1001")
+		assert.Nil(t, err)
+
+		now = now.Add(30 * time.Second)
+		_, err = pool.Get("1002")
+		assert.Nil(t, err)
+
+		now = now.Add(45 * time.Second)
+		assert.Equal(t, 1, pool.EvictIdle())
+		assert.Equal(t, 1, pool.Len())
+
+		// Evicted client is rebuilt from token store
+		client, err := pool.Get("1001")
+		assert.Nil(t, err)
+		assert.NotNil(t, client)
+		assert.Equal(t, 2, pool.Len())
+	})
+}
+
+func TestPool_rateLimits(t *testing.T) {
+	pool := NewPool(PoolOptions{
+		Config:           &oauth2.Config{},
+		TokenStore:       func(uid string) TokenStore { return NewMemoryTokenStore(&oauth2.Token{AccessToken: uid}) },
+		RateLimit:        100,
+		Burst:            10,
+		AccountRateLimit: 5,
+		AccountBurst:     2,
+	})
+
+	client1, err := pool.Get("1001")
+	assert.Nil(t, err)
+	client2, err := pool.Get("1002")
+	assert.Nil(t, err)
+
+	transport1 := client1.client.Transport.(*oauth2.Transport).Base.(*rateLimitedTransport)
+	transport2 := client2.client.Transport.(*oauth2.Transport).Base.(*rateLimitedTransport)
+
+	// Shared limiter is the same, per-account limiters are separate
+	assert.True(t, transport1.limiters[1] == transport2.limiters[1])
+	assert.True(t, transport1.limiters[0] != transport2.limiters[0])
+	assert.Equal(t, 2, transport1.limiters[0].Burst())
+	assert.Equal(t, 10, transport1.limiters[1].Burst())
+}
+
+func TestPool_accountLimiterOutlivesClient(t *testing.T) {
+	pool := NewPool(PoolOptions{
+		Config:           &oauth2.Config{},
+		TokenStore:       func(uid string) TokenStore { return NewMemoryTokenStore(&oauth2.Token{AccessToken: uid}) },
+		AccountRateLimit: 5,
+		AccountBurst:     2,
+	})
+
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+
+	accountLimiter := func() *rate.Limiter {
+		client, err := pool.Get("1001")
+		assert.Nil(t, err)
+		return client.client.Transport.(*oauth2.Transport).Base.(*rateLimitedTransport).limiters[0]
+	}
+
+	limiter := accountLimiter()
+
+	// Rebuilt client keeps the limiter with its remaining quota
+	pool.Remove("1001")
+	assert.True(t, limiter == accountLimiter())
+
+	// Limiter is dropped once it's been refilled (burst of 2 at 5 per second)
+	pool.Remove("1001")
+	now = now.Add(400 * time.Millisecond)
+	assert.Equal(t, 0, pool.EvictIdle())
+	assert.Empty(t, pool.limiters)
+	assert.True(t, limiter != accountLimiter())
+}
+
+func TestPool_Add_refreshedToken(t *testing.T) {
+	stores := map[string]*MemoryTokenStore{}
+
+	pool := NewPool(PoolOptions{
+		Config: &oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: "https://oauth.example.com/token"}},
+		TokenStore: func(uid string) TokenStore {
+			if _, ok := stores[uid]; !ok {
+				stores[uid] = NewMemoryTokenStore(nil)
+			}
+			return stores[uid]
+		},
+		Transport: testhelpers.RoundTripFunc(func(req *http.Request) *http.Response {
+			if req.URL.Path == "/token" {
+				return &http.Response{
+					StatusCode: 200,
+					Header:     http.Header{"Content-Type": {"application/json"}},
+					Body:       ioutil.NopCloser(bytes.NewBufferString(`{"access_token":"refreshed","token_type":"bearer","refresh_token":"refresh-2","expires_in":3600}`)),
+				}
+			}
+
+			return &http.Response{
+				StatusCode: 200,
+				Body:       ioutil.NopCloser(bytes.NewBufferString(`{"user":{"uid":"1001"}}`)),
+			}
+		}),
+	})
+
+	expired := &oauth2.Token{AccessToken: "expired", RefreshToken: "refresh-1", Expiry: time.Now().Add(-time.Hour)}
+
+	uid, _, err := pool.Add(context.Background(), expired)
+	assert.Nil(t, err)
+	assert.Equal(t, "1001", uid)
+
+	token, err := stores["1001"].Load()
+	assert.Nil(t, err)
+	assert.Equal(t, "refreshed", token.AccessToken)
+	assert.Equal(t, "refresh-2", token.RefreshToken)
+}
+
+// Token store which blocks Load until released.
+type blockingTokenStore struct {
+	*MemoryTokenStore
+	release chan struct{}
+}
+
+func (s *blockingTokenStore) Load() (*oauth2.Token, error) {
+	<-s.release
+	return s.MemoryTokenStore.Load()
+}
+
+func TestPool_Get_slowStore(t *testing.T) {
+	slow := &blockingTokenStore{
+		MemoryTokenStore: NewMemoryTokenStore(&oauth2.Token{AccessToken: "slow"}),
+		release:          make(chan struct{}),
+	}
+	fast := NewMemoryTokenStore(&oauth2.Token{AccessToken: "fast"})
+
+	pool := NewPool(PoolOptions{
+		Config: &oauth2.Config{},
+		TokenStore: func(uid string) TokenStore {
+			if uid == "slow" {
+				return slow
+			}
+			return fast
+		},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := pool.Get("slow")
+		assert.Nil(t, err)
+	}()
+
+	// Other accounts aren't blocked by the slow store
+	_, err := pool.Get("fast")
+	assert.Nil(t, err)
+
+	close(slow.release)
+	<-done
+	assert.Equal(t, 2, pool.Len())
+}
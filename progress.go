@@ -0,0 +1,315 @@
+package yadisk
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultProgressInterval = 500 * time.Millisecond
+
+// Progress of upload or download.
+type Progress struct {
+	// Number of transferred bytes. It includes bytes transferred before the
+	// transfer has been resumed.
+	Transferred int64
+
+	// Size of the transferred file, negative if it's unknown.
+	Total int64
+
+	// Transfer rate (bytes per second) since the previous report.
+	Rate float64
+
+	// Average transfer rate (bytes per second) since the transfer has started.
+	AverageRate float64
+
+	// Time since the transfer has started.
+	Elapsed time.Duration
+
+	// Estimated time remaining, negative if it's unknown.
+	ETA time.Duration
+
+	// Whether it's the last report of the transfer. Once the transfer has
+	// started, it's reported regardless of whether the transfer has succeeded.
+	Done bool
+}
+
+// Function receiving progress reports. It's never called concurrently for
+// the same transfer, but it's called synchronously from the transfer, so it
+// should return quickly (see ProgressChan).
+type ProgressFunc func(p Progress)
+
+type progressConfigKey struct{}
+
+type progressTrackerKey struct{}
+
+type progressConfig struct {
+	f        ProgressFunc
+	interval time.Duration
+}
+
+// Report progress of transfers made with the returned context to f.
+//
+// It's supported by Upload, Download and all of upload and download helpers.
+// Progress is reported when the transfer starts (once the response headers
+// have arrived for downloads), then not more often than once per interval
+// (500ms if zero) and when the transfer has finished.
+func WithProgress(ctx context.Context, f ProgressFunc, interval time.Duration) context.Context {
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+
+	return context.WithValue(ctx, progressConfigKey{}, &progressConfig{f: f, interval: interval})
+}
+
+// ProgressFunc sending reports to the channel. Reports are dropped if the
+// channel is not ready, so a slow consumer (e.g. a web socket) doesn't slow
+// down the transfer. The channel is not closed.
+func ProgressChan(ch chan<- Progress) ProgressFunc {
+	return func(p Progress) {
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
+
+type progressTracker struct {
+	config *progressConfig
+	now    func() time.Time
+
+	mu sync.Mutex
+
+	total       int64
+	transferred int64
+
+	// Bytes transferred before the transfer has been resumed
+	initial int64
+
+	started         time.Time
+	lastReport      time.Time
+	lastTransferred int64
+
+	done bool
+}
+
+// Start tracking transfer consisting of several requests. Requests made with
+// the returned context report to the returned tracker. Tracker is nil if
+// progress is not requested.
+func (c *Client) trackProgress(ctx context.Context, total, initial int64) (context.Context, *progressTracker) {
+	config, ok := ctx.Value(progressConfigKey{}).(*progressConfig)
+	if !ok {
+		return ctx, nil
+	}
+
+	tracker := newProgressTracker(config, c.now, total, initial)
+
+	return context.WithValue(ctx, progressTrackerKey{}, tracker), tracker
+}
+
+// Tracker of a single request. It's nil if progress is not requested.
+func (c *Client) requestProgress(ctx context.Context, total int64) *progressTracker {
+	config, ok := ctx.Value(progressConfigKey{}).(*progressConfig)
+	if !ok {
+		return nil
+	}
+
+	return newProgressTracker(config, c.now, total, 0)
+}
+
+func newProgressTracker(config *progressConfig, now func() time.Time, total, initial int64) *progressTracker {
+	t := &progressTracker{
+		config:          config,
+		now:             now,
+		total:           total,
+		transferred:     initial,
+		initial:         initial,
+		lastTransferred: initial,
+	}
+
+	t.started = now()
+	t.lastReport = t.started
+
+	t.config.f(t.progress(t.started))
+
+	return t
+}
+
+func (t *progressTracker) add(n int64) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.transferred += n
+	t.reportIfDue()
+}
+
+// Set number of transferred bytes, e.g. when part of transfer is retried.
+func (t *progressTracker) set(transferred int64) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.transferred = transferred
+	t.reportIfDue()
+}
+
+func (t *progressTracker) setTotal(total int64) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.total = total
+}
+
+// Send the last report. It's safe to call finish several times.
+func (t *progressTracker) finish() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.done {
+		return
+	}
+	t.done = true
+
+	t.config.f(t.progress(t.now()))
+}
+
+func (t *progressTracker) reportIfDue() {
+	if t.done {
+		return
+	}
+
+	now := t.now()
+	if now.Sub(t.lastReport) < t.config.interval {
+		return
+	}
+
+	t.config.f(t.progress(now))
+}
+
+// Build report and remember it as the last one. Must be called with lock held.
+func (t *progressTracker) progress(now time.Time) Progress {
+	p := Progress{
+		Transferred: t.transferred,
+		Total:       t.total,
+		Elapsed:     now.Sub(t.started),
+		ETA:         -1,
+		Done:        t.done,
+	}
+
+	if sinceLast := now.Sub(t.lastReport).Seconds(); sinceLast > 0 {
+		p.Rate = float64(t.transferred-t.lastTransferred) / sinceLast
+	}
+
+	if elapsed := p.Elapsed.Seconds(); elapsed > 0 {
+		p.AverageRate = float64(t.transferred-t.initial) / elapsed
+	}
+
+	if t.done {
+		p.ETA = 0
+	} else if t.total >= 0 && p.AverageRate > 0 {
+		remaining := t.total - t.transferred
+		if remaining < 0 {
+			remaining = 0
+		}
+		p.ETA = time.Duration(float64(remaining) / p.AverageRate * float64(time.Second))
+	}
+
+	t.lastReport = now
+	t.lastTransferred = t.transferred
+
+	return p
+}
+
+// Body which reports number of read bytes to the tracker.
+type progressBody struct {
+	io.ReadCloser
+	tracker *progressTracker
+
+	// Whether to finish tracking when body is read or closed
+	finish bool
+}
+
+func (b *progressBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	b.tracker.add(int64(n))
+	if err == io.EOF && b.finish {
+		b.tracker.finish()
+	}
+
+	return n, err
+}
+
+func (b *progressBody) Close() error {
+	if b.finish {
+		b.tracker.finish()
+	}
+
+	return b.ReadCloser.Close()
+}
+
+// Track progress of the request body if it's sent, otherwise - of the
+// response body. Returned function must be called with the response.
+func (c *Client) trackRequestProgress(ctx context.Context, req *http.Request) func(resp *http.Response) {
+	tracker, _ := ctx.Value(progressTrackerKey{}).(*progressTracker)
+
+	if req.Body != nil {
+		if req.Body == http.NoBody {
+			return func(*http.Response) {}
+		}
+
+		owned := false
+		if tracker == nil {
+			total := req.ContentLength
+			if total == 0 {
+				total = -1
+			}
+			tracker = c.requestProgress(ctx, total)
+			owned = tracker != nil
+		}
+
+		if tracker != nil {
+			req.Body = &progressBody{ReadCloser: req.Body, tracker: tracker}
+		}
+
+		return func(*http.Response) {
+			if owned {
+				tracker.finish()
+			}
+		}
+	}
+
+	return func(resp *http.Response) {
+		// Error responses are not considered as transferred content
+		if resp == nil || resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return
+		}
+
+		owned := false
+		if tracker == nil {
+			tracker = c.requestProgress(ctx, resp.ContentLength)
+			owned = tracker != nil
+		}
+
+		if tracker != nil {
+			resp.Body = &progressBody{ReadCloser: resp.Body, tracker: tracker, finish: owned}
+		}
+	}
+}
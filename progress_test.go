@@ -0,0 +1,202 @@
+package yadisk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Collects progress reports and fails the test if they're reported
+// concurrently.
+type progressRecorder struct {
+	t *testing.T
+
+	mu      sync.Mutex
+	reports []Progress
+	running bool
+}
+
+func (r *progressRecorder) report(p Progress) {
+	r.mu.Lock()
+	if r.running {
+		r.t.Error("progress reported concurrently")
+	}
+	r.running = true
+	r.reports = append(r.reports, p)
+	r.mu.Unlock()
+
+	time.Sleep(time.Millisecond)
+
+	r.mu.Lock()
+	r.running = false
+	r.mu.Unlock()
+}
+
+func (r *progressRecorder) first() Progress {
+	return r.reports[0]
+}
+
+func (r *progressRecorder) last() Progress {
+	return r.reports[len(r.reports)-1]
+}
+
+func TestProgressTracker(t *testing.T) {
+	now := testNow
+	var reports []Progress
+
+	config := &progressConfig{f: func(p Progress) { reports = append(reports, p) }, interval: time.Second}
+	tracker := newProgressTracker(config, func() time.Time { return now }, 100, 10)
+
+	now = now.Add(500 * time.Millisecond)
+	tracker.add(10)
+
+	now = now.Add(500 * time.Millisecond)
+	tracker.add(20)
+
+	now = now.Add(time.Second)
+	tracker.add(10)
+
+	now = now.Add(time.Second)
+	tracker.finish()
+	tracker.finish()
+
+	assert.Equal(t, []Progress{
+		{Transferred: 10, Total: 100, ETA: -1},
+		{Transferred: 40, Total: 100, Rate: 30, AverageRate: 30, Elapsed: time.Second, ETA: 2 * time.Second},
+		{Transferred: 50, Total: 100, Rate: 10, AverageRate: 20, Elapsed: 2 * time.Second, ETA: 2500 * time.Millisecond},
+		{Transferred: 50, Total: 100, Rate: 0, AverageRate: 40.0 / 3, Elapsed: 3 * time.Second, ETA: 0, Done: true},
+	}, reports)
+}
+
+func TestProgressChan(t *testing.T) {
+	ch := make(chan Progress, 1)
+	f := ProgressChan(ch)
+
+	f(Progress{Transferred: 1})
+	f(Progress{Transferred: 2})
+
+	assert.Equal(t, Progress{Transferred: 1}, <-ch)
+	assert.Len(t, ch, 0)
+}
+
+func TestWithProgress(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+
+	tests := []struct {
+		name     string
+		transfer func(ctx context.Context, client *Client, dir string) error
+
+		first Progress
+		last  Progress
+	}{
+		{
+			name: "Upload",
+			transfer: func(ctx context.Context, client *Client, dir string) error {
+				link, err := client.RequestUploadLink(ctx, "/uploaded.bin", false)
+				if err != nil {
+					return err
+				}
+				_, err = client.Upload(ctx, link, bytes.NewReader([]byte(content)))
+				return err
+			},
+
+			first: Progress{Transferred: 0, Total: 100, ETA: -1},
+			last:  Progress{Transferred: 100, Total: 100, Done: true},
+		},
+
+		{
+			name: "UploadReader of unknown size",
+			transfer: func(ctx context.Context, client *Client, dir string) error {
+				return client.UploadReader(ctx, ioutil.NopCloser(strings.NewReader(content)), "/uploaded.bin", nil)
+			},
+
+			first: Progress{Transferred: 0, Total: -1, ETA: -1},
+			last:  Progress{Transferred: 100, Total: -1, Done: true},
+		},
+
+		{
+			name: "DownloadTo",
+			transfer: func(ctx context.Context, client *Client, dir string) error {
+				_, err := client.DownloadTo(ctx, "/file.bin", ioutil.Discard)
+				return err
+			},
+
+			first: Progress{Transferred: 0, Total: 100, ETA: -1},
+			last:  Progress{Transferred: 100, Total: 100, Done: true},
+		},
+
+		{
+			name: "DownloadParallel",
+			transfer: func(ctx context.Context, client *Client, dir string) error {
+				link, err := client.RequestDownloadLink(ctx, "/file.bin")
+				if err != nil {
+					return err
+				}
+
+				f, err := os.Create(filepath.Join(dir, "file.bin"))
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+
+				_, err = client.DownloadParallel(ctx, link, f, &ParallelDownloadOptions{ChunkSize: 7, Workers: 4})
+				return err
+			},
+
+			first: Progress{Transferred: 0, Total: -1, ETA: -1},
+			last:  Progress{Transferred: 100, Total: 100, Done: true},
+		},
+
+		{
+			name: "UploadFileResumable",
+			transfer: func(ctx context.Context, client *Client, dir string) error {
+				localPath := filepath.Join(dir, "file.bin")
+				if err := ioutil.WriteFile(localPath, []byte(content), 0644); err != nil {
+					return err
+				}
+
+				return client.UploadFileResumable(ctx, localPath, "/uploaded.bin", &ResumableUploadOptions{ChunkSize: 30})
+			},
+
+			first: Progress{Transferred: 0, Total: 100, ETA: -1},
+			last:  Progress{Transferred: 100, Total: 100, Done: true},
+		},
+
+		{
+			name: "DownloadFileResumable",
+			transfer: func(ctx context.Context, client *Client, dir string) error {
+				return client.DownloadFileResumable(ctx, "/file.bin", filepath.Join(dir, "file.bin"), nil)
+			},
+
+			first: Progress{Transferred: 0, Total: -1, ETA: -1},
+			last:  Progress{Transferred: 100, Total: 100, Done: true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+
+			client, disk := newFakeDiskClient()
+			client.now = func() time.Time { return testNow }
+			disk.PutFile("/file.bin", []byte(content))
+
+			recorder := &progressRecorder{t: t}
+			ctx := WithProgress(context.Background(), recorder.report, time.Nanosecond)
+
+			err := test.transfer(ctx, client, dir)
+			assert.Nil(t, err)
+
+			assert.Equal(t, test.first, recorder.first())
+			assert.Equal(t, test.last, recorder.last())
+		})
+	}
+}
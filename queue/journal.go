@@ -0,0 +1,167 @@
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/yurykabanov/go-yandex-disk/internal/atomicfile"
+)
+
+// Line of the journal: either snapshot of a job or ID of removed job.
+type record struct {
+	Job     *Job   `json:"job,omitempty"`
+	Removed string `json:"removed,omitempty"`
+}
+
+// Minimum number of lines appended since the last compaction which makes the
+// journal to be compacted again.
+const compactionThreshold = 1000
+
+// Append-only JSON lines file with snapshots of jobs. The latest snapshot of
+// a job wins, the journal is compacted when it's opened and once appended
+// snapshots considerably outnumber jobs.
+type journal struct {
+	path string
+	f    *os.File
+
+	// Lines appended since the last compaction
+	appended int
+}
+
+// Open the journal and read jobs in the order they've been added.
+func openJournal(path string) (*journal, []*Job, error) {
+	jobs, err := readJournal(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := compactJournal(path, jobs); err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &journal{path: path, f: f}, jobs, nil
+}
+
+func readJournal(path string) ([]*Job, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		jobs  []*Job
+		index = make(map[string]int)
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+
+	for line := 1; scanner.Scan(); line++ {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			// The last line could be written partially before crash
+			if !bytes.HasSuffix(data, []byte("\n")) && line == bytes.Count(data, []byte("\n"))+1 {
+				break
+			}
+			return nil, fmt.Errorf("queue: corrupted journal %s at line %d: %v", path, line, err)
+		}
+
+		switch {
+		case rec.Removed != "":
+			if i, ok := index[rec.Removed]; ok {
+				jobs[i] = nil
+				delete(index, rec.Removed)
+			}
+		case rec.Job != nil:
+			if i, ok := index[rec.Job.ID]; ok {
+				jobs[i] = rec.Job
+			} else {
+				index[rec.Job.ID] = len(jobs)
+				jobs = append(jobs, rec.Job)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var result []*Job
+	for _, job := range jobs {
+		if job != nil {
+			result = append(result, job)
+		}
+	}
+
+	return result, nil
+}
+
+// Replace the journal with the latest snapshots of jobs.
+func compactJournal(path string, jobs []*Job) error {
+	buf := &bytes.Buffer{}
+	for _, job := range jobs {
+		if err := json.NewEncoder(buf).Encode(record{Job: job}); err != nil {
+			return err
+		}
+	}
+
+	return atomicfile.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// Append snapshot of the job. State changes are synced to the storage,
+// progress updates are not.
+func (j *journal) write(rec record, sync bool) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	j.appended++
+
+	if sync {
+		return j.f.Sync()
+	}
+
+	return nil
+}
+
+// Whether the journal should be compacted, jobs is the number of jobs.
+func (j *journal) needsCompaction(jobs int) bool {
+	return j.appended >= compactionThreshold && j.appended >= 2*jobs
+}
+
+// Replace the journal with the latest snapshots of jobs and continue
+// appending to the new file.
+func (j *journal) compact(jobs []*Job) error {
+	if err := compactJournal(j.path, jobs); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	j.f.Close()
+	j.f, j.appended = f, 0
+
+	return nil
+}
+
+func (j *journal) close() error {
+	return j.f.Close()
+}
@@ -0,0 +1,662 @@
+// Package queue runs uploads and downloads in background and keeps them in
+// a journal on local disk, so transfers survive restarts.
+//
+// Jobs are transferred with resumable uploads and downloads
+// (yadisk.Client.UploadFileResumable and DownloadFileResumable), so a job
+// interrupted by crash, sleep or network failure continues from its last
+// persisted chunk once the queue is opened and run again.
+//
+// Journal is a file of JSON lines with snapshots of jobs. It must not be used
+// by several queues at once.
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/yurykabanov/go-yandex-disk"
+)
+
+const (
+	defaultWorkers          = 2
+	defaultRetryDelay       = time.Second
+	defaultProgressInterval = time.Second
+)
+
+var (
+	ErrJobNotFound = errors.New("queue: job not found")
+	ErrJobFinished = errors.New("queue: job has finished")
+	ErrClosed      = errors.New("queue: queue is closed")
+)
+
+// Kind of transfer.
+type Kind string
+
+const (
+	KindUpload   Kind = "upload"
+	KindDownload Kind = "download"
+)
+
+// State of a job.
+type State string
+
+const (
+	// Job waits for a worker.
+	StateQueued State = "queued"
+
+	// Job is being transferred.
+	StateRunning State = "running"
+
+	// Job is not run until it's resumed.
+	StatePaused State = "paused"
+
+	// Job has been transferred.
+	StateDone State = "done"
+
+	// Job has failed after all retries, it could be resumed.
+	StateFailed State = "failed"
+
+	// Job has been cancelled, its partial transfer is discarded.
+	StateCancelled State = "cancelled"
+)
+
+// Transfer job.
+type Job struct {
+	ID   string `json:"id"`
+	Kind Kind   `json:"kind"`
+
+	LocalPath  string `json:"local_path"`
+	RemotePath string `json:"remote_path"`
+
+	// Whether to overwrite existing destination.
+	Overwrite bool `json:"overwrite"`
+
+	State State `json:"state"`
+
+	// Number of started attempts including restarts.
+	Attempts int `json:"attempts"`
+
+	// Progress of the transfer. Total is -1 if it's not known yet.
+	Transferred int64 `json:"transferred"`
+	Total       int64 `json:"total"`
+
+	// Error of the last failed attempt.
+	Error string `json:"error,omitempty"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// Whether job is done, failed or cancelled.
+func (j Job) Finished() bool {
+	return j.State == StateDone || j.State == StateFailed || j.State == StateCancelled
+}
+
+// Options of the queue.
+type Options struct {
+	// Number of simultaneous transfers. 2 by default.
+	Workers int
+
+	// How many times failed job is retried before it's marked as failed.
+	Retries int
+
+	// Delay between retries. 1 second by default.
+	RetryDelay time.Duration
+
+	// Size of the content sent with one upload request, see
+	// yadisk.ResumableUploadOptions.
+	ChunkSize int64
+
+	// How often progress is recorded. 1 second by default.
+	ProgressInterval time.Duration
+
+	// Directory of upload state files. Directory of the journal by default.
+	StateDir string
+}
+
+// Persistent queue of transfers.
+type Queue struct {
+	client *yadisk.Client
+	opts   Options
+
+	mu      sync.Mutex
+	journal *journal
+	jobs    map[string]*Job
+	order   []string
+	running map[string]*runningJob
+	closed  bool
+
+	// Closed and replaced whenever a job changes
+	changed chan struct{}
+
+	// The first journal error of workers, it stops Run
+	err error
+
+	now func() time.Time
+}
+
+type runningJob struct {
+	cancel context.CancelFunc
+
+	// State requested by Pause or Cancel, empty if Run is stopped
+	stop State
+}
+
+// Open queue with the given journal, it's created if it doesn't exist. Jobs
+// which have been running when the queue was stopped are queued again.
+//
+// opts - Queue options, nil means default options.
+func Open(client *yadisk.Client, journalPath string, opts *Options) (*Queue, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	q := &Queue{
+		client:  client,
+		opts:    *opts,
+		jobs:    make(map[string]*Job),
+		running: make(map[string]*runningJob),
+		changed: make(chan struct{}),
+		now:     time.Now,
+	}
+
+	if q.opts.Workers <= 0 {
+		q.opts.Workers = defaultWorkers
+	}
+	if q.opts.RetryDelay <= 0 {
+		q.opts.RetryDelay = defaultRetryDelay
+	}
+	if q.opts.ProgressInterval <= 0 {
+		q.opts.ProgressInterval = defaultProgressInterval
+	}
+	if q.opts.StateDir == "" {
+		q.opts.StateDir = filepath.Dir(journalPath)
+	}
+
+	j, jobs, err := openJournal(journalPath)
+	if err != nil {
+		return nil, err
+	}
+	q.journal = j
+
+	for _, job := range jobs {
+		if job.State == StateRunning {
+			job.State = StateQueued
+		}
+
+		q.jobs[job.ID] = job
+		q.order = append(q.order, job.ID)
+	}
+
+	return q, nil
+}
+
+// Close the journal. Run must have returned before the queue is closed.
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+
+	return q.journal.close()
+}
+
+// Add upload of local file to the given remote path.
+func (q *Queue) AddUpload(localPath, remotePath string, overwrite bool) (Job, error) {
+	return q.add(KindUpload, localPath, remotePath, overwrite)
+}
+
+// Add download of remote file to the given local path.
+func (q *Queue) AddDownload(remotePath, localPath string, overwrite bool) (Job, error) {
+	return q.add(KindDownload, localPath, remotePath, overwrite)
+}
+
+func (q *Queue) add(kind Kind, localPath, remotePath string, overwrite bool) (Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return Job{}, ErrClosed
+	}
+
+	now := q.now().UTC()
+
+	job := &Job{
+		ID:         id,
+		Kind:       kind,
+		LocalPath:  localPath,
+		RemotePath: remotePath,
+		Overwrite:  overwrite,
+		State:      StateQueued,
+		Total:      -1,
+		Created:    now,
+		Updated:    now,
+	}
+
+	if err := q.record(record{Job: job}, true); err != nil {
+		return Job{}, err
+	}
+
+	q.jobs[id] = job
+	q.order = append(q.order, id)
+	q.notify()
+
+	return *job, nil
+}
+
+// All jobs in the order they've been added.
+func (q *Queue) Jobs() []Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	jobs := make([]Job, 0, len(q.order))
+	for _, id := range q.order {
+		jobs = append(jobs, *q.jobs[id])
+	}
+
+	return jobs
+}
+
+// Job with the given ID.
+func (q *Queue) Job(id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+
+	return *job, nil
+}
+
+// Wait until the job is finished.
+func (q *Queue) Wait(ctx context.Context, id string) (Job, error) {
+	for {
+		q.mu.Lock()
+		job, ok := q.jobs[id]
+		if !ok {
+			q.mu.Unlock()
+			return Job{}, ErrJobNotFound
+		}
+		snapshot, changed := *job, q.changed
+		q.mu.Unlock()
+
+		if snapshot.Finished() {
+			return snapshot, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return snapshot, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Pause the job. Running job is stopped asynchronously, its partial transfer
+// is kept to be resumed.
+func (q *Queue) Pause(id string) error {
+	return q.stop(id, StatePaused)
+}
+
+// Cancel the job. Running job is stopped asynchronously, its partial transfer
+// is discarded.
+func (q *Queue) Cancel(id string) error {
+	return q.stop(id, StateCancelled)
+}
+
+func (q *Queue) stop(id string, state State) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+
+	switch job.State {
+	case state:
+		return nil
+	case StateRunning:
+		if r := q.running[id]; r != nil {
+			r.stop = state
+			r.cancel()
+		}
+		return nil
+	case StateDone, StateCancelled:
+		return ErrJobFinished
+	case StateFailed:
+		if state == StatePaused {
+			return ErrJobFinished
+		}
+	}
+
+	if state == StateCancelled {
+		q.discard(job)
+	}
+
+	return q.setState(job, state, "")
+}
+
+// Resume paused or failed job.
+func (q *Queue) Resume(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+
+	switch job.State {
+	case StatePaused, StateFailed:
+		return q.setState(job, StateQueued, "")
+	case StateDone, StateCancelled:
+		return ErrJobFinished
+	}
+
+	return nil
+}
+
+// Remove finished job from the queue.
+func (q *Queue) Remove(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if !job.Finished() {
+		return errors.New("queue: job has not finished")
+	}
+
+	if err := q.record(record{Removed: id}, true); err != nil {
+		return err
+	}
+
+	delete(q.jobs, id)
+	for i, other := range q.order {
+		if other == id {
+			q.order = append(q.order[:i], q.order[i+1:]...)
+			break
+		}
+	}
+	q.notify()
+
+	return nil
+}
+
+// Run queued jobs until context is done. Running jobs are stopped when Run
+// returns, they're resumed by the next call (or after the queue is opened
+// again).
+//
+// Method returns context's error or error of the journal.
+func (q *Queue) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < q.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx, cancel)
+		}()
+	}
+	wg.Wait()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.err != nil {
+		return q.err
+	}
+
+	return ctx.Err()
+}
+
+func (q *Queue) work(ctx context.Context, stopRun context.CancelFunc) {
+	for {
+		job, jobCtx, changed := q.next(ctx)
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+				continue
+			}
+		}
+
+		if err := q.run(jobCtx, job); err != nil {
+			q.mu.Lock()
+			if q.err == nil {
+				q.err = err
+			}
+			q.mu.Unlock()
+
+			stopRun()
+			return
+		}
+	}
+}
+
+// Start the first queued job. If there is no such job, method returns channel
+// which is closed once jobs are changed.
+func (q *Queue) next(ctx context.Context) (*Job, context.Context, chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if ctx.Err() != nil || q.closed {
+		return nil, nil, q.changed
+	}
+
+	for _, id := range q.order {
+		job := q.jobs[id]
+		if job.State != StateQueued {
+			continue
+		}
+
+		jobCtx, cancel := context.WithCancel(ctx)
+		q.running[id] = &runningJob{cancel: cancel}
+
+		job.Attempts++
+		if err := q.setState(job, StateRunning, ""); err != nil {
+			cancel()
+			delete(q.running, id)
+			q.err = err
+			return nil, nil, q.changed
+		}
+
+		snapshot := *job
+		return &snapshot, jobCtx, q.changed
+	}
+
+	return nil, nil, q.changed
+}
+
+// Transfer the job with retries and record the result.
+func (q *Queue) run(ctx context.Context, job *Job) error {
+	for attempt := 0; ; attempt++ {
+		err := q.transfer(ctx, job)
+		if err == nil {
+			return q.finish(job.ID, StateDone, nil)
+		}
+
+		if ctx.Err() != nil {
+			return q.finish(job.ID, "", nil)
+		}
+
+		if attempt >= q.opts.Retries {
+			return q.finish(job.ID, StateFailed, err)
+		}
+
+		if err := q.recordError(job.ID, err); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return q.finish(job.ID, "", nil)
+		case <-time.After(q.opts.RetryDelay):
+		}
+	}
+}
+
+func (q *Queue) transfer(ctx context.Context, job *Job) error {
+	ctx = yadisk.WithProgress(ctx, func(p yadisk.Progress) {
+		q.progress(job.ID, p)
+	}, q.opts.ProgressInterval)
+
+	if job.Kind == KindDownload {
+		overwrite := yadisk.OverwriteNever
+		if job.Overwrite {
+			overwrite = yadisk.OverwriteAlways
+		}
+
+		return q.client.DownloadFileResumable(ctx, job.RemotePath, job.LocalPath, &yadisk.ResumableDownloadOptions{
+			Overwrite: overwrite,
+		})
+	}
+
+	return q.client.UploadFileResumable(ctx, job.LocalPath, job.RemotePath, &yadisk.ResumableUploadOptions{
+		Overwrite: job.Overwrite,
+		StatePath: q.uploadStatePath(job.ID),
+		ChunkSize: q.opts.ChunkSize,
+	})
+}
+
+// Record result of the job. Empty state means job has been stopped, it gets
+// state requested by Pause or Cancel or it's queued again if Run is stopped.
+func (q *Queue) finish(id string, state State, err error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	r := q.running[id]
+	delete(q.running, id)
+	r.cancel()
+
+	job := q.jobs[id]
+
+	if state == "" {
+		state = r.stop
+		if state == "" {
+			state = StateQueued
+		}
+	}
+
+	if state == StateCancelled {
+		q.discard(job)
+	}
+
+	var message string
+	if err != nil {
+		message = err.Error()
+	}
+
+	if q.closed {
+		return nil
+	}
+
+	return q.setState(job, state, message)
+}
+
+func (q *Queue) recordError(id string, err error) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job := q.jobs[id]
+	job.Attempts++
+
+	return q.setState(job, job.State, err.Error())
+}
+
+func (q *Queue) progress(id string, p yadisk.Progress) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.jobs[id]
+	if !ok || job.State != StateRunning || q.closed {
+		return
+	}
+
+	job.Transferred, job.Total = p.Transferred, p.Total
+	job.Updated = q.now().UTC()
+
+	// Progress is informational, it's not worth failing the transfer
+	q.record(record{Job: job}, false)
+	q.notify()
+}
+
+// Change state of the job and record it. Must be called with mutex held.
+func (q *Queue) setState(job *Job, state State, message string) error {
+	job.State = state
+	job.Error = message
+	job.Updated = q.now().UTC()
+
+	q.notify()
+
+	return q.record(record{Job: job}, true)
+}
+
+// Append the record to the journal. Journal which has grown enough (e.g. with
+// progress of long transfers) is compacted before that, while jobs match
+// the records which have been written. Must be called with mutex held.
+func (q *Queue) record(rec record, sync bool) error {
+	if q.journal.needsCompaction(len(q.jobs)) {
+		jobs := make([]*Job, 0, len(q.order))
+		for _, id := range q.order {
+			jobs = append(jobs, q.jobs[id])
+		}
+
+		if err := q.journal.compact(jobs); err != nil {
+			return err
+		}
+	}
+
+	return q.journal.write(rec, sync)
+}
+
+// Remove partial transfer of the job. Must be called with mutex held.
+func (q *Queue) discard(job *Job) {
+	if job.Kind == KindDownload {
+		yadisk.RemovePartialDownload(job.LocalPath, "")
+		return
+	}
+
+	os.Remove(q.uploadStatePath(job.ID))
+}
+
+func (q *Queue) uploadStatePath(id string) string {
+	return filepath.Join(q.opts.StateDir, id+".yadisk-upload")
+}
+
+// Wake up everyone waiting for changes. Must be called with mutex held.
+func (q *Queue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
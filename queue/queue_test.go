@@ -0,0 +1,304 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/go-yandex-disk"
+	"github.com/yurykabanov/go-yandex-disk/internal/testhelpers"
+)
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "yadisk-queue-test")
+	assert.Nil(t, err)
+
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// Run the queue in background, returned function stops it.
+func runQueue(t *testing.T, q *Queue) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		assert.Equal(t, context.Canceled, q.Run(ctx))
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func waitJob(t *testing.T, q *Queue, id string) Job {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	job, err := q.Wait(ctx, id)
+	assert.Nil(t, err)
+
+	return job
+}
+
+func TestQueue(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	disk := testhelpers.NewFakeDisk()
+	disk.PutFile("/remote.txt", []byte("REMOTE"))
+	client := yadisk.New(disk.Client())
+
+	localPath := filepath.Join(dir, "local.txt")
+	assert.Nil(t, ioutil.WriteFile(localPath, []byte("LOCAL"), 0644))
+
+	journalPath := filepath.Join(dir, "journal")
+
+	q, err := Open(client, journalPath, &Options{ProgressInterval: time.Millisecond})
+	assert.Nil(t, err)
+
+	upload, err := q.AddUpload(localPath, "/uploaded.txt", false)
+	assert.Nil(t, err)
+	download, err := q.AddDownload("/remote.txt", filepath.Join(dir, "downloaded.txt"), false)
+	assert.Nil(t, err)
+
+	assert.Equal(t, StateQueued, upload.State)
+	assert.Equal(t, int64(-1), upload.Total)
+
+	stop := runQueue(t, q)
+
+	job := waitJob(t, q, upload.ID)
+	assert.Equal(t, StateDone, job.State)
+	assert.Equal(t, 1, job.Attempts)
+	assert.Equal(t, "LOCAL", string(disk.File("/uploaded.txt").Content))
+
+	job = waitJob(t, q, download.ID)
+	assert.Equal(t, StateDone, job.State)
+	content, _ := ioutil.ReadFile(filepath.Join(dir, "downloaded.txt"))
+	assert.Equal(t, "REMOTE", string(content))
+
+	stop()
+	assert.Nil(t, q.Close())
+
+	// Finished jobs are kept in the journal
+	q, err = Open(client, journalPath, nil)
+	assert.Nil(t, err)
+	defer q.Close()
+
+	jobs := q.Jobs()
+	assert.Len(t, jobs, 2)
+	assert.Equal(t, upload.ID, jobs[0].ID)
+	assert.Equal(t, StateDone, jobs[0].State)
+	assert.Equal(t, StateDone, jobs[1].State)
+
+	assert.Nil(t, q.Remove(upload.ID))
+	assert.Equal(t, ErrJobNotFound, q.Remove(upload.ID))
+	assert.Len(t, q.Jobs(), 1)
+}
+
+func TestQueue_Restart(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	disk := testhelpers.NewFakeDisk()
+	client := yadisk.New(disk.Client())
+
+	localPath := filepath.Join(dir, "local.txt")
+	assert.Nil(t, ioutil.WriteFile(localPath, []byte("LOCAL"), 0644))
+
+	// Process has crashed while the job was running and while the journal
+	// was being written
+	journalPath := filepath.Join(dir, "journal")
+	journal := `{"job":{"id":"1","kind":"upload","local_path":"` + localPath + `","remote_path":"/uploaded.txt","state":"queued","total":-1}}
+{"job":{"id":"1","kind":"upload","local_path":"` + localPath + `","remote_path":"/uploaded.txt","state":"running","attempts":1,"total":-1}}
+{"job":{"id":"1","kind":"upl`
+	assert.Nil(t, ioutil.WriteFile(journalPath, []byte(journal), 0600))
+
+	q, err := Open(client, journalPath, nil)
+	assert.Nil(t, err)
+	defer q.Close()
+
+	job, err := q.Job("1")
+	assert.Nil(t, err)
+	assert.Equal(t, StateQueued, job.State)
+
+	stop := runQueue(t, q)
+	defer stop()
+
+	job = waitJob(t, q, "1")
+	assert.Equal(t, StateDone, job.State)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Equal(t, "LOCAL", string(disk.File("/uploaded.txt").Content))
+}
+
+func TestQueue_JournalCompaction(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	journalPath := filepath.Join(dir, "journal")
+	client := yadisk.New(nil)
+
+	q, err := Open(client, journalPath, nil)
+	assert.Nil(t, err)
+
+	removed, err := q.AddUpload("/local/removed.txt", "/removed.txt", false)
+	assert.Nil(t, err)
+	kept, err := q.AddUpload("/local/kept.txt", "/kept.txt", false)
+	assert.Nil(t, err)
+
+	// Every change appends a snapshot
+	for i := 0; i < 2*compactionThreshold; i++ {
+		assert.Nil(t, q.Pause(kept.ID))
+		assert.Nil(t, q.Resume(kept.ID))
+	}
+
+	assert.Nil(t, q.Cancel(removed.ID))
+	assert.Nil(t, q.Remove(removed.ID))
+	assert.Nil(t, q.Close())
+
+	data, err := ioutil.ReadFile(journalPath)
+	assert.Nil(t, err)
+	assert.True(t, bytes.Count(data, []byte("\n")) <= compactionThreshold+1)
+
+	q, err = Open(client, journalPath, nil)
+	assert.Nil(t, err)
+	defer q.Close()
+
+	jobs := q.Jobs()
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, kept.ID, jobs[0].ID)
+	assert.Equal(t, StateQueued, jobs[0].State)
+}
+
+func TestQueue_CorruptedJournal(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	journalPath := filepath.Join(dir, "journal")
+	assert.Nil(t, ioutil.WriteFile(journalPath, []byte("{\"job\":\n{}\n"), 0600))
+
+	_, err := Open(yadisk.New(nil), journalPath, nil)
+	assert.NotNil(t, err)
+}
+
+func TestQueue_PauseResumeCancel(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	disk := testhelpers.NewFakeDisk()
+	client := yadisk.New(disk.Client())
+
+	localPath := filepath.Join(dir, "local.txt")
+	assert.Nil(t, ioutil.WriteFile(localPath, []byte("LOCAL"), 0644))
+
+	q, err := Open(client, filepath.Join(dir, "journal"), nil)
+	assert.Nil(t, err)
+	defer q.Close()
+
+	paused, _ := q.AddUpload(localPath, "/paused.txt", false)
+	cancelled, _ := q.AddUpload(localPath, "/cancelled.txt", false)
+	done, _ := q.AddUpload(localPath, "/done.txt", false)
+
+	assert.Nil(t, q.Pause(paused.ID))
+	assert.Nil(t, q.Cancel(cancelled.ID))
+	assert.Equal(t, ErrJobNotFound, q.Pause("missing"))
+
+	stop := runQueue(t, q)
+	defer stop()
+
+	assert.Equal(t, StateDone, waitJob(t, q, done.ID).State)
+
+	job, _ := q.Job(paused.ID)
+	assert.Equal(t, StatePaused, job.State)
+	assert.Equal(t, []string{"/done.txt"}, disk.Files())
+
+	assert.Nil(t, q.Resume(paused.ID))
+	assert.Equal(t, StateDone, waitJob(t, q, paused.ID).State)
+
+	assert.Equal(t, ErrJobFinished, q.Resume(cancelled.ID))
+	assert.Equal(t, ErrJobFinished, q.Cancel(done.ID))
+	assert.Equal(t, []string{"/done.txt", "/paused.txt"}, disk.Files())
+}
+
+func TestQueue_CancelRunning(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	disk := testhelpers.NewFakeDisk()
+	client := yadisk.New(disk.Client())
+
+	// Uploads hang until they're cancelled
+	started := make(chan struct{}, 1)
+	disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if !strings.HasPrefix(r.URL.Path, "/upload/") {
+			return false
+		}
+
+		started <- struct{}{}
+		<-r.Context().Done()
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return true
+	}
+
+	localPath := filepath.Join(dir, "local.txt")
+	assert.Nil(t, ioutil.WriteFile(localPath, []byte("LOCAL"), 0644))
+
+	q, err := Open(client, filepath.Join(dir, "journal"), &Options{StateDir: dir})
+	assert.Nil(t, err)
+	defer q.Close()
+
+	job, _ := q.AddUpload(localPath, "/uploaded.txt", false)
+
+	stop := runQueue(t, q)
+	defer stop()
+
+	<-started
+	assert.Nil(t, q.Cancel(job.ID))
+
+	job = waitJob(t, q, job.ID)
+	assert.Equal(t, StateCancelled, job.State)
+
+	_, err = os.Stat(filepath.Join(dir, job.ID+".yadisk-upload"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestQueue_Retries(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	disk := testhelpers.NewFakeDisk()
+	client := yadisk.New(disk.Client())
+
+	q, err := Open(client, filepath.Join(dir, "journal"), &Options{Retries: 2, RetryDelay: time.Millisecond})
+	assert.Nil(t, err)
+	defer q.Close()
+
+	job, _ := q.AddDownload("/missing.txt", filepath.Join(dir, "missing.txt"), false)
+
+	stop := runQueue(t, q)
+	defer stop()
+
+	job = waitJob(t, q, job.ID)
+	assert.Equal(t, StateFailed, job.State)
+	assert.Equal(t, 3, job.Attempts)
+	assert.NotEmpty(t, job.Error)
+
+	// Failed job could be retried once the problem is fixed
+	disk.PutFile("/missing.txt", []byte("FOUND"))
+	assert.Nil(t, q.Resume(job.ID))
+
+	job = waitJob(t, q, job.ID)
+	assert.Equal(t, StateDone, job.State)
+	assert.Empty(t, job.Error)
+}
@@ -0,0 +1,143 @@
+package yadisk
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+const (
+	methodGetResource = http.MethodGet
+	urlGetResource    = "resources"
+)
+
+// Meta information about file or directory.
+//
+// path - The path to the resource relative to the Disk root.
+// limit - The number of resources in the directory that should be returned.
+// Zero means default API limit (20 resources).
+// offset - The number of resources from the top of the list that should be
+// skipped.
+//
+// Method returns Resource or error. For directories Resource.Embedded contains
+// the requested part of directory contents.
+//
+// See: https://tech.yandex.com/disk/api/reference/meta-docpage/
+func (c *Client) GetResource(ctx context.Context, path string, limit, offset int) (*Resource, error) {
+	if c.cache != nil {
+		return c.cache.resource(ctx, path, limit, offset, func(ctx context.Context) (*Resource, error) {
+			return c.getResource(ctx, path, limit, offset)
+		})
+	}
+
+	return c.getResource(ctx, path, limit, offset)
+}
+
+func (c *Client) getResource(ctx context.Context, path string, limit, offset int) (*Resource, error) {
+	var resource Resource
+
+	params := map[string]string{
+		"path": path,
+	}
+	if limit > 0 {
+		params["limit"] = strconv.Itoa(limit)
+	}
+	if offset > 0 {
+		params["offset"] = strconv.Itoa(offset)
+	}
+
+	_, err := c.doRequestAndDecode(ctx, methodGetResource, urlGetResource, params, nil, &resource)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resource, nil
+}
+
+// Number of resources requested at once when directory is listed.
+const dirListLimit = 100
+
+// All resources of the directory. Unlike GetResource, the whole content is
+// requested page by page.
+//
+// path - The path to the directory.
+//
+// Method returns NotDirectoryError if the path is a file.
+func (c *Client) ListDirectory(ctx context.Context, path string) ([]Resource, error) {
+	var items []Resource
+
+	for {
+		resource, err := c.GetResource(ctx, path, dirListLimit, len(items))
+		if err != nil {
+			return nil, err
+		}
+		if resource.Type != ResourceTypeDirectory {
+			return nil, NotDirectoryError{Path: path}
+		}
+
+		items = append(items, resource.Embedded.Items...)
+
+		if len(resource.Embedded.Items) == 0 || int64(len(items)) >= resource.Embedded.Total {
+			return items, nil
+		}
+	}
+}
+
+const (
+	methodUpdateResource = http.MethodPatch
+	urlUpdateResource    = "resources"
+)
+
+// Set custom properties of the resource. Properties with other keys are kept.
+//
+// path - The path to the resource relative to the Disk root.
+// properties - Properties to set.
+//
+// Method returns updated Resource or error.
+//
+// See: https://tech.yandex.com/disk/api/reference/meta-add-docpage/
+func (c *Client) SetCustomProperties(ctx context.Context, path string, properties map[string]string) (*Resource, error) {
+	values := make(map[string]interface{}, len(properties))
+	for k, v := range properties {
+		values[k] = v
+	}
+
+	return c.updateCustomProperties(ctx, path, values)
+}
+
+// Remove custom properties of the resource.
+//
+// path - The path to the resource relative to the Disk root.
+// keys - Keys of properties to remove.
+//
+// Method returns updated Resource or error.
+//
+// See: https://tech.yandex.com/disk/api/reference/meta-add-docpage/
+func (c *Client) RemoveCustomProperties(ctx context.Context, path string, keys ...string) (*Resource, error) {
+	// Properties are removed by setting them to null
+	values := make(map[string]interface{}, len(keys))
+	for _, k := range keys {
+		values[k] = nil
+	}
+
+	return c.updateCustomProperties(ctx, path, values)
+}
+
+func (c *Client) updateCustomProperties(ctx context.Context, path string, values map[string]interface{}) (*Resource, error) {
+	var resource Resource
+
+	params := map[string]string{
+		"path": path,
+	}
+	body := map[string]interface{}{
+		"custom_properties": values,
+	}
+
+	_, err := c.doRequestAndDecode(ctx, methodUpdateResource, urlUpdateResource, params, body, &resource)
+	c.invalidateCache(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resource, nil
+}
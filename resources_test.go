@@ -0,0 +1,205 @@
+package yadisk
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/go-yandex-disk/internal/testhelpers"
+)
+
+func TestClient_GetResource(t *testing.T) {
+	tests := []struct {
+		name string
+
+		responseStatusCode int
+		responseBody       string
+
+		path   string
+		limit  int
+		offset int
+		params map[string]string
+
+		response *Resource
+		error    error
+	}{
+		{
+			name: "file",
+
+			responseStatusCode: 200,
+			responseBody:       `{"name":"some_file.ext","path":"disk:/some_path/some_file.ext","type":"file","size":123,"md5":"some_md5"}`, // NOTE: some fields are omitted
+
+			path:   "/some_path/some_file.ext",
+			params: map[string]string{"path": "/some_path/some_file.ext"},
+
+			response: &Resource{Name: "some_file.ext", Path: "disk:/some_path/some_file.ext", Type: ResourceTypeFile, Size: 123, Md5: "some_md5"},
+			error:    nil,
+		},
+
+		{
+			name: "directory with limit and offset",
+
+			responseStatusCode: 200,
+			responseBody:       `{"name":"some_path","path":"disk:/some_path","type":"dir","_embedded":{"path":"disk:/some_path","limit":1,"offset":2,"total":3,"items":[{"name":"c","path":"disk:/some_path/c","type":"file"}]}}`,
+
+			path:   "/some_path",
+			limit:  1,
+			offset: 2,
+			params: map[string]string{"path": "/some_path", "limit": "1", "offset": "2"},
+
+			response: &Resource{
+				Name: "some_path",
+				Path: "disk:/some_path",
+				Type: ResourceTypeDirectory,
+				Embedded: ResourceList{
+					Path:   "disk:/some_path",
+					Limit:  1,
+					Offset: 2,
+					Total:  3,
+					Items:  []Resource{{Name: "c", Path: "disk:/some_path/c", Type: ResourceTypeFile}},
+				},
+			},
+			error: nil,
+		},
+
+		{
+			name: "error resource not found",
+
+			responseStatusCode: 404,
+			responseBody:       `{"message":"Не удалось найти запрошенный ресурс.","description":"Resource not found.","error":"DiskNotFoundError"}`,
+
+			path:   "/some_path/some_file.ext",
+			params: map[string]string{"path": "/some_path/some_file.ext"},
+
+			response: nil,
+			error:    ApiError{StatusCode: 404, Message: "Не удалось найти запрошенный ресурс.", Description: "Resource not found.", ErrorID: "DiskNotFoundError"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectedUrl := testhelpers.BuildUrl("https://cloud-api.yandex.net/v1/disk/resources", test.params)
+
+			client := New(testhelpers.NewTestClient(func(req *http.Request) *http.Response {
+				assert.Equal(t, http.MethodGet, req.Method)
+				assert.Equal(t, expectedUrl, req.URL.String())
+
+				return &http.Response{
+					StatusCode: test.responseStatusCode,
+					Body:       ioutil.NopCloser(bytes.NewBufferString(test.responseBody)),
+				}
+			}))
+
+			resource, err := client.GetResource(context.Background(), test.path, test.limit, test.offset)
+
+			assert.Equal(t, test.response, resource)
+			assert.Equal(t, test.error, err)
+		})
+	}
+}
+
+func TestClient_UpdateCustomProperties(t *testing.T) {
+	tests := []struct {
+		name string
+
+		update func(client *Client) (*Resource, error)
+
+		requestBody        string
+		responseStatusCode int
+		responseBody       string
+
+		response *Resource
+		error    error
+	}{
+		{
+			name: "set",
+
+			update: func(client *Client) (*Resource, error) {
+				return client.SetCustomProperties(context.Background(), "/file.txt", map[string]string{"key": "value"})
+			},
+
+			requestBody:        `{"custom_properties":{"key":"value"}}`,
+			responseStatusCode: 200,
+			responseBody:       `{"name":"file.txt","path":"disk:/file.txt","type":"file","custom_properties":{"key":"value"}}`,
+
+			response: &Resource{Name: "file.txt", Path: "disk:/file.txt", Type: ResourceTypeFile, CustomProperties: map[string]string{"key": "value"}},
+		},
+
+		{
+			name: "remove",
+
+			update: func(client *Client) (*Resource, error) {
+				return client.RemoveCustomProperties(context.Background(), "/file.txt", "key")
+			},
+
+			requestBody:        `{"custom_properties":{"key":null}}`,
+			responseStatusCode: 200,
+			responseBody:       `{"name":"file.txt","path":"disk:/file.txt","type":"file"}`,
+
+			response: &Resource{Name: "file.txt", Path: "disk:/file.txt", Type: ResourceTypeFile},
+		},
+
+		{
+			name: "error resource not found",
+
+			update: func(client *Client) (*Resource, error) {
+				return client.RemoveCustomProperties(context.Background(), "/file.txt", "key")
+			},
+
+			requestBody:        `{"custom_properties":{"key":null}}`,
+			responseStatusCode: 404,
+			responseBody:       `{"message":"Не удалось найти запрошенный ресурс.","description":"Resource not found.","error":"DiskNotFoundError"}`,
+
+			error: ApiError{StatusCode: 404, Message: "Не удалось найти запрошенный ресурс.", Description: "Resource not found.", ErrorID: "DiskNotFoundError"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expectedUrl := testhelpers.BuildUrl("https://cloud-api.yandex.net/v1/disk/resources", map[string]string{"path": "/file.txt"})
+
+			client := New(testhelpers.NewTestClient(func(req *http.Request) *http.Response {
+				assert.Equal(t, http.MethodPatch, req.Method)
+				assert.Equal(t, expectedUrl, req.URL.String())
+
+				body, _ := ioutil.ReadAll(req.Body)
+				assert.JSONEq(t, test.requestBody, string(body))
+
+				return &http.Response{
+					StatusCode: test.responseStatusCode,
+					Body:       ioutil.NopCloser(bytes.NewBufferString(test.responseBody)),
+				}
+			}))
+
+			resource, err := test.update(client)
+
+			if test.response != nil {
+				assert.Equal(t, test.response, resource)
+			} else {
+				assert.Nil(t, resource)
+			}
+			assert.Equal(t, test.error, err)
+		})
+	}
+}
+
+func TestClient_ListDirectory(t *testing.T) {
+	client, disk := newFakeDiskClient()
+	for i := 0; i < dirListLimit+10; i++ {
+		disk.PutFile(fmt.Sprintf("/dir/file-%03d.txt", i), nil)
+	}
+
+	items, err := client.ListDirectory(context.Background(), "/dir")
+	assert.Nil(t, err)
+	assert.Len(t, items, dirListLimit+10)
+	assert.Equal(t, 2, countRequests(disk, 0, "GET /v1/disk/resources"))
+
+	_, err = client.ListDirectory(context.Background(), "/dir/file-000.txt")
+	assert.Equal(t, NotDirectoryError{Path: "/dir/file-000.txt"}, err)
+
+	_, err = client.ListDirectory(context.Background(), "/missing")
+	assert.True(t, isNotFound(err))
+}
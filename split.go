@@ -0,0 +1,495 @@
+package yadisk
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Files larger than Disk.MaxFileSize are stored split into parts. Split file
+// "/path/name" is a directory "/path/name.yadisk-split" with parts
+// "part-00000", "part-00001", ... and "manifest.json" which describes them.
+// Manifest is uploaded last, so split file without manifest is an incomplete
+// upload. Complete split file is overwritten by uploading the new one to
+// "/path/name.yadisk-split.replacement" which replaces the old one once it's
+// complete.
+const (
+	SplitSuffix = ".yadisk-split"
+
+	splitReplacementSuffix = ".replacement"
+
+	splitManifestName    = "manifest.json"
+	splitManifestVersion = 1
+)
+
+// ErrSplitIncomplete is returned when split file has no manifest, i.e. its
+// upload hasn't been completed.
+var ErrSplitIncomplete = errors.New("yadisk: split file is incomplete")
+
+// Manifest of split file.
+type SplitManifest struct {
+	Version int `json:"version"`
+
+	// Size of the whole file.
+	Size int64 `json:"size"`
+
+	// Modification time of the uploaded local file.
+	Modified time.Time `json:"modified"`
+
+	// Checksums of the whole file.
+	Md5    string `json:"md5"`
+	Sha256 string `json:"sha256"`
+
+	// Parts in the order of content.
+	Parts []SplitPart `json:"parts"`
+}
+
+// Part of split file.
+type SplitPart struct {
+	// Name of the part in the directory of split file.
+	Name string `json:"name"`
+
+	Size   int64  `json:"size"`
+	Md5    string `json:"md5"`
+	Sha256 string `json:"sha256"`
+}
+
+// Options of UploadFileSplit.
+type SplitOptions struct {
+	// What to do if remote file (either regular or split) already exists.
+	// OverwriteNever by default.
+	Overwrite OverwritePolicy
+
+	// Maximum size of a part. Disk.MaxFileSize by default.
+	PartSize int64
+}
+
+// Upload local file splitting it into parts if it's larger than the part
+// size.
+//
+// localPath - The path to the local file.
+// remotePath - The path where you want to upload the file.
+// opts - Upload options, nil means default options.
+//
+// File which fits into a single part is uploaded as a regular file. Larger
+// file is uploaded as split file (see SplitSuffix), use DownloadFileSplit,
+// DownloadSplitTo and ListSplit to access it as a single file.
+//
+// Method returns ErrDestinationExists if remote file exists and overwrite
+// policy is OverwriteNever. Incomplete split file is not considered existing:
+// upload continues it, parts which have already been uploaded and match the
+// local file are not uploaded again.
+//
+// Existing file is replaced only once the new one has been uploaded, so
+// failed upload keeps it.
+func (c *Client) UploadFileSplit(ctx context.Context, localPath, remotePath string, opts *SplitOptions) error {
+	if opts == nil {
+		opts = &SplitOptions{}
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	partSize := opts.PartSize
+	if partSize <= 0 {
+		disk, err := c.GetDisk(ctx)
+		if err != nil {
+			return err
+		}
+		partSize = disk.MaxFileSize
+	}
+
+	skip, dest, err := c.prepareSplitDestination(ctx, remotePath, opts.Overwrite)
+	if err != nil || skip {
+		return err
+	}
+
+	if partSize <= 0 || info.Size() <= partSize {
+		if _, err := c.uploadReader(ctx, f, remotePath, &UploadOptions{Overwrite: OverwriteAlways}); err != nil {
+			return err
+		}
+
+		return c.deleteAll(ctx, dest.dirs...)
+	}
+
+	if dest.uploaded == nil {
+		if err := c.MkdirAll(ctx, dest.dir); err != nil {
+			return err
+		}
+	}
+
+	manifest := &SplitManifest{
+		Version:  splitManifestVersion,
+		Size:     info.Size(),
+		Modified: info.ModTime().UTC(),
+	}
+	hashes := newTransferHashes()
+
+	for offset := int64(0); offset < info.Size(); offset += partSize {
+		size := partSize
+		if offset+size > info.Size() {
+			size = info.Size() - offset
+		}
+
+		part, err := c.uploadSplitPart(ctx, f, offset, size, dest.dir, len(manifest.Parts), dest.uploaded, hashes)
+		if err != nil {
+			return err
+		}
+
+		manifest.Parts = append(manifest.Parts, part)
+	}
+
+	manifest.Md5 = hex.EncodeToString(hashes.md5.Sum(nil))
+	manifest.Sha256 = hex.EncodeToString(hashes.sha256.Sum(nil))
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.uploadReader(ctx, bytes.NewReader(data), path.Join(dest.dir, splitManifestName), &UploadOptions{Overwrite: OverwriteAlways}); err != nil {
+		return err
+	}
+
+	// New version is complete, replace the old one with it
+	dir := remotePath + SplitSuffix
+	if dest.dir != dir {
+		link, statusCode, err := c.Move(ctx, dest.dir, dir, true)
+		if err != nil {
+			return err
+		}
+		if statusCode == http.StatusAccepted {
+			if err := c.WaitOperation(ctx, link, 0); err != nil {
+				return err
+			}
+		}
+	}
+
+	var stale []string
+	if dest.file {
+		stale = append(stale, remotePath)
+	}
+	for _, p := range dest.dirs {
+		if p != dir && p != dest.dir {
+			stale = append(stale, p)
+		}
+	}
+
+	return c.deleteAll(ctx, stale...)
+}
+
+// Upload part of the file unless it has already been uploaded. Content of
+// the part is fed to the hashes of the whole file.
+func (c *Client) uploadSplitPart(ctx context.Context, f *os.File, offset, size int64, dir string, index int, uploaded map[string]Resource, hashes *transferHashes) (SplitPart, error) {
+	part := SplitPart{Name: fmt.Sprintf("part-%05d", index), Size: size}
+
+	partHashes := newTransferHashes()
+	r := io.TeeReader(io.NewSectionReader(f, offset, size), io.MultiWriter(hashes, partHashes))
+
+	if existing, ok := uploaded[part.Name]; ok && existing.Size == size && existing.Md5 != "" {
+		// Hash the part first to check whether it's the same
+		if _, err := io.Copy(ioutil.Discard, r); err != nil {
+			return part, err
+		}
+
+		part.Md5 = hex.EncodeToString(partHashes.md5.Sum(nil))
+		part.Sha256 = hex.EncodeToString(partHashes.sha256.Sum(nil))
+		if strings.EqualFold(part.Md5, existing.Md5) {
+			return part, nil
+		}
+
+		r = io.NewSectionReader(f, offset, size)
+	}
+
+	if _, err := c.uploadReader(ctx, r, path.Join(dir, part.Name), &UploadOptions{Overwrite: OverwriteAlways}); err != nil {
+		return part, err
+	}
+
+	part.Md5 = hex.EncodeToString(partHashes.md5.Sum(nil))
+	part.Sha256 = hex.EncodeToString(partHashes.sha256.Sum(nil))
+
+	return part, nil
+}
+
+// Destination of split upload.
+type splitDestination struct {
+	// Directory to upload parts to. It's the directory of replacement (see
+	// splitReplacementSuffix) if complete split file is overwritten.
+	dir string
+
+	// Parts which have already been uploaded to dir by their names, nil if
+	// dir doesn't exist.
+	uploaded map[string]Resource
+
+	// Whether regular file exists.
+	file bool
+
+	// Existing directories of split file and its replacement.
+	dirs []string
+}
+
+// Check existing remote file according to overwrite policy. Existing file is
+// not deleted, it's replaced once the new one is uploaded.
+func (c *Client) prepareSplitDestination(ctx context.Context, remotePath string, overwrite OverwritePolicy) (bool, *splitDestination, error) {
+	dest := &splitDestination{dir: remotePath + SplitSuffix}
+
+	if _, err := c.GetResource(ctx, remotePath, 0, 0); err == nil {
+		dest.file = true
+	} else if !isNotFound(err) {
+		return false, nil, err
+	}
+
+	uploaded, complete, err := c.listSplitDirectory(ctx, dest.dir)
+	if err != nil {
+		return false, nil, err
+	}
+	if uploaded != nil {
+		dest.dirs = append(dest.dirs, dest.dir)
+	}
+
+	replacement := dest.dir + splitReplacementSuffix
+	replaced, _, err := c.listSplitDirectory(ctx, replacement)
+	if err != nil {
+		return false, nil, err
+	}
+	if replaced != nil {
+		dest.dirs = append(dest.dirs, replacement)
+	}
+
+	if complete {
+		// Keep the complete split file until its replacement is uploaded
+		dest.dir, dest.uploaded = replacement, replaced
+	} else {
+		dest.uploaded = uploaded
+	}
+
+	if !dest.file && !complete {
+		return false, dest, nil
+	}
+
+	switch overwrite {
+	case OverwriteSkip:
+		return true, nil, nil
+	case OverwriteNever:
+		return false, nil, ErrDestinationExists
+	}
+
+	return false, dest, nil
+}
+
+// List directory of split file. Method returns its items by their names (nil
+// if directory doesn't exist) and whether it has manifest.
+func (c *Client) listSplitDirectory(ctx context.Context, dir string) (map[string]Resource, bool, error) {
+	items, err := c.ListDirectory(ctx, dir)
+	if isNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	uploaded := make(map[string]Resource)
+	for _, item := range items {
+		uploaded[item.Name] = item
+	}
+
+	_, complete := uploaded[splitManifestName]
+
+	return uploaded, complete, nil
+}
+
+func (c *Client) deleteAll(ctx context.Context, paths ...string) error {
+	for _, p := range paths {
+		if err := c.deletePermanently(ctx, p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) deletePermanently(ctx context.Context, p string) error {
+	link, statusCode, err := c.Delete(ctx, p, true)
+	if err != nil {
+		return err
+	}
+
+	if statusCode == http.StatusAccepted {
+		return c.WaitOperation(ctx, link, 0)
+	}
+
+	return nil
+}
+
+// Get manifest of split file.
+//
+// remotePath - The path of the file without SplitSuffix.
+//
+// Method returns ApiError with "404 Not Found" status code if there's no split
+// file and ErrSplitIncomplete if its upload hasn't been completed.
+func (c *Client) GetSplitManifest(ctx context.Context, remotePath string) (*SplitManifest, error) {
+	dir := remotePath + SplitSuffix
+
+	buf := &bytes.Buffer{}
+	if _, err := c.DownloadTo(ctx, path.Join(dir, splitManifestName), buf); err != nil {
+		if isNotFound(err) {
+			if _, dirErr := c.GetResource(ctx, dir, 0, 0); dirErr == nil {
+				return nil, ErrSplitIncomplete
+			}
+		}
+		return nil, err
+	}
+
+	manifest := &SplitManifest{}
+	if err := json.Unmarshal(buf.Bytes(), manifest); err != nil {
+		return nil, fmt.Errorf("yadisk: invalid manifest of split file %s: %v", remotePath, err)
+	}
+	if manifest.Version != splitManifestVersion {
+		return nil, fmt.Errorf("yadisk: unsupported version %d of split file %s", manifest.Version, remotePath)
+	}
+
+	return manifest, nil
+}
+
+// Download file, regular or split, and write its content to w.
+//
+// remotePath - The path of the file without SplitSuffix.
+//
+// Parts of split file are downloaded one by one and written as a single
+// stream. Method returns ChecksumMismatchError if content of a part doesn't
+// match the manifest. Number of written bytes is returned.
+func (c *Client) DownloadSplitTo(ctx context.Context, remotePath string, w io.Writer) (int64, error) {
+	manifest, err := c.GetSplitManifest(ctx, remotePath)
+	if isNotFound(err) {
+		return c.DownloadTo(ctx, remotePath, w)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return c.downloadSplitParts(ctx, remotePath, manifest, w)
+}
+
+func (c *Client) downloadSplitParts(ctx context.Context, remotePath string, manifest *SplitManifest, w io.Writer) (int64, error) {
+	var written int64
+
+	for _, part := range manifest.Parts {
+		hashes := newTransferHashes()
+
+		n, err := c.DownloadTo(ctx, path.Join(remotePath+SplitSuffix, part.Name), io.MultiWriter(w, hashes))
+		written += n
+		if err != nil {
+			return written, err
+		}
+
+		if n != part.Size {
+			return written, fmt.Errorf("yadisk: part %s of split file %s has size %d, expected %d", part.Name, remotePath, n, part.Size)
+		}
+
+		if err := compareChecksums(&Resource{Md5: part.Md5, Sha256: part.Sha256}, hashes.md5, hashes.sha256); err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// Download file, regular or split, to the given local path.
+//
+// remotePath - The path of the file without SplitSuffix.
+// localPath - The path to the local file.
+// opts - Download options, nil means default options.
+//
+// Modification time of split file is the modification time of the uploaded
+// local file. See DownloadSplitTo and DownloadFile for details.
+func (c *Client) DownloadFileSplit(ctx context.Context, remotePath, localPath string, opts *DownloadOptions) error {
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+
+	if opts.Overwrite != OverwriteAlways {
+		if _, err := os.Lstat(localPath); err == nil {
+			if opts.Overwrite == OverwriteSkip {
+				return nil
+			}
+			return ErrDestinationExists
+		}
+	}
+
+	manifest, err := c.GetSplitManifest(ctx, remotePath)
+	if isNotFound(err) {
+		return c.DownloadFile(ctx, remotePath, localPath, opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	resource := &Resource{Size: manifest.Size, Modified: manifest.Modified}
+
+	_, err = writeLocalFile(localPath, resource, opts, func(w io.Writer) error {
+		_, err := c.downloadSplitParts(ctx, remotePath, manifest, w)
+		return err
+	})
+
+	return err
+}
+
+// List directory presenting split files as regular files.
+//
+// dir - The path to the directory.
+//
+// Split file is listed with its name, path, size and checksums of the whole
+// file, its modification time is the modification time of the uploaded local
+// file. Incomplete split files are listed as directories.
+func (c *Client) ListSplit(ctx context.Context, dir string) ([]Resource, error) {
+	items, err := c.ListDirectory(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, item := range items {
+		if item.Type != ResourceTypeDirectory || !strings.HasSuffix(item.Name, SplitSuffix) {
+			continue
+		}
+
+		name := strings.TrimSuffix(item.Name, SplitSuffix)
+
+		manifest, err := c.GetSplitManifest(ctx, path.Join(dir, name))
+		if err == ErrSplitIncomplete {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		item.Name = name
+		item.Path = strings.TrimSuffix(item.Path, SplitSuffix)
+		item.Type = ResourceTypeFile
+		item.Size = manifest.Size
+		item.Md5 = manifest.Md5
+		item.Sha256 = manifest.Sha256
+		item.Modified = manifest.Modified
+		item.MimeType = ""
+		items[i] = item
+	}
+
+	return items, nil
+}
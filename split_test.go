@@ -0,0 +1,249 @@
+package yadisk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_UploadFileSplit(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	content := strings.Repeat("0123456789", 250)
+
+	localPath := filepath.Join(dir, "image.img")
+	assert.Nil(t, ioutil.WriteFile(localPath, []byte(content), 0644))
+	modified := time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC)
+	assert.Nil(t, os.Chtimes(localPath, modified, modified))
+
+	// Manifest must fit too
+	client, disk := newFakeDiskClient()
+	disk.MaxFileSize = 1024
+	disk.PutDir("/vm")
+	disk.PutFile("/vm/small.txt", []byte("SMALL"))
+
+	ctx := context.Background()
+
+	assert.Nil(t, client.UploadFileSplit(ctx, localPath, "/vm/image.img", nil))
+	assert.Equal(t, []string{
+		"/vm/image.img.yadisk-split/manifest.json",
+		"/vm/image.img.yadisk-split/part-00000",
+		"/vm/image.img.yadisk-split/part-00001",
+		"/vm/image.img.yadisk-split/part-00002",
+		"/vm/small.txt",
+	}, disk.Files())
+	assert.Equal(t, content[2048:], string(disk.File("/vm/image.img.yadisk-split/part-00002").Content))
+
+	manifest, err := client.GetSplitManifest(ctx, "/vm/image.img")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2500), manifest.Size)
+	assert.Equal(t, md5Hex(content), manifest.Md5)
+	assert.Equal(t, md5Hex(content[1024:2048]), manifest.Parts[1].Md5)
+	assert.True(t, modified.Equal(manifest.Modified))
+
+	items, err := client.ListSplit(ctx, "/vm")
+	assert.Nil(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, "image.img", items[0].Name)
+	assert.Equal(t, "disk:/vm/image.img", items[0].Path)
+	assert.Equal(t, ResourceTypeFile, items[0].Type)
+	assert.Equal(t, int64(2500), items[0].Size)
+	assert.Equal(t, "small.txt", items[1].Name)
+
+	buf := &bytes.Buffer{}
+	n, err := client.DownloadSplitTo(ctx, "/vm/image.img", buf)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2500), n)
+	assert.Equal(t, content, buf.String())
+
+	// Regular files are downloaded as is
+	buf.Reset()
+	_, err = client.DownloadSplitTo(ctx, "/vm/small.txt", buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "SMALL", buf.String())
+
+	downloadPath := filepath.Join(dir, "downloaded.img")
+	assert.Nil(t, client.DownloadFileSplit(ctx, "/vm/image.img", downloadPath, &DownloadOptions{Atomic: true, PreserveModTime: true}))
+	downloaded, _ := ioutil.ReadFile(downloadPath)
+	assert.Equal(t, content, string(downloaded))
+	info, _ := os.Stat(downloadPath)
+	assert.True(t, modified.Equal(info.ModTime()))
+
+	assert.Equal(t, ErrDestinationExists, client.DownloadFileSplit(ctx, "/vm/image.img", downloadPath, nil))
+
+	// Modified part is detected
+	tampered := strings.Repeat("X", 1024)
+	disk.PutFile("/vm/image.img.yadisk-split/part-00001", []byte(tampered))
+	_, err = client.DownloadSplitTo(ctx, "/vm/image.img", ioutil.Discard)
+	assert.Equal(t, ChecksumMismatchError{Algorithm: "md5", Expected: md5Hex(content[1024:2048]), Actual: md5Hex(tampered)}, err)
+
+	disk.PutFile("/vm/image.img.yadisk-split/part-00001", []byte("XXXX"))
+	_, err = client.DownloadSplitTo(ctx, "/vm/image.img", ioutil.Discard)
+	assert.NotNil(t, err)
+}
+
+func TestClient_UploadFileSplit_Overwrite(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	localPath := filepath.Join(dir, "file.txt")
+	assert.Nil(t, ioutil.WriteFile(localPath, []byte("NEW"), 0644))
+
+	tests := []struct {
+		name      string
+		overwrite OverwritePolicy
+		partSize  int64
+
+		files []string
+		error error
+	}{
+		{
+			name:      "never",
+			overwrite: OverwriteNever,
+
+			files: []string{"/file.txt", "/file.txt.yadisk-split/manifest.json", "/file.txt.yadisk-split/part-00000"},
+			error: ErrDestinationExists,
+		},
+		{
+			name:      "skip",
+			overwrite: OverwriteSkip,
+
+			files: []string{"/file.txt", "/file.txt.yadisk-split/manifest.json", "/file.txt.yadisk-split/part-00000"},
+		},
+		{
+			name:      "always with regular file",
+			overwrite: OverwriteAlways,
+
+			files: []string{"/file.txt"},
+		},
+		{
+			name:      "always with split file",
+			overwrite: OverwriteAlways,
+			partSize:  2,
+
+			files: []string{"/file.txt.yadisk-split/manifest.json", "/file.txt.yadisk-split/part-00000", "/file.txt.yadisk-split/part-00001"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, disk := newFakeDiskClient()
+			disk.AsyncOperations = 1
+			disk.PutFile("/file.txt", []byte("OLD"))
+			disk.PutFile("/file.txt.yadisk-split/manifest.json", []byte(`{"version":1}`))
+			disk.PutFile("/file.txt.yadisk-split/part-00000", []byte("OLD"))
+
+			err := client.UploadFileSplit(context.Background(), localPath, "/file.txt", &SplitOptions{Overwrite: test.overwrite, PartSize: test.partSize})
+			assert.Equal(t, test.error, err)
+			assert.Equal(t, test.files, disk.Files())
+		})
+	}
+}
+
+func TestClient_UploadFileSplit_OverwriteFailure(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	oldPath := filepath.Join(dir, "old.txt")
+	assert.Nil(t, ioutil.WriteFile(oldPath, []byte("OLD CONTENT"), 0644))
+
+	tests := []struct {
+		name    string
+		content string
+
+		// Number of uploaded parts before the failure and after resume
+		uploaded int
+		resumed  int
+	}{
+		{name: "regular file", content: "NEW", resumed: 1},
+		{name: "split file", content: "NEW CONTENT!", uploaded: 1, resumed: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			localPath := filepath.Join(dir, "new.txt")
+			assert.Nil(t, ioutil.WriteFile(localPath, []byte(test.content), 0644))
+
+			opts := &SplitOptions{Overwrite: OverwriteAlways, PartSize: 4}
+
+			client, disk := newFakeDiskClient()
+			disk.AsyncOperations = 1
+			assert.Nil(t, client.UploadFileSplit(context.Background(), oldPath, "/file.txt", opts))
+
+			uploads := 0
+			disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+				if r.Method != http.MethodPut || !strings.HasPrefix(r.URL.Path, "/upload/") {
+					return false
+				}
+
+				if uploads++; uploads <= test.uploaded {
+					return false
+				}
+
+				w.WriteHeader(http.StatusInternalServerError)
+				return true
+			}
+
+			assert.NotNil(t, client.UploadFileSplit(context.Background(), localPath, "/file.txt", opts))
+
+			// The old file is kept
+			buf := &bytes.Buffer{}
+			_, err := client.DownloadSplitTo(context.Background(), "/file.txt", buf)
+			assert.Nil(t, err)
+			assert.Equal(t, "OLD CONTENT", buf.String())
+
+			disk.Intercept = nil
+			since := len(disk.Requests)
+			assert.Nil(t, client.UploadFileSplit(context.Background(), localPath, "/file.txt", opts))
+			assert.Equal(t, test.resumed, countRequests(disk, since, "PUT /upload/"))
+
+			buf.Reset()
+			_, err = client.DownloadSplitTo(context.Background(), "/file.txt", buf)
+			assert.Nil(t, err)
+			assert.Equal(t, test.content, buf.String())
+
+			for _, p := range disk.Files() {
+				assert.False(t, strings.Contains(p, splitReplacementSuffix), p)
+			}
+		})
+	}
+}
+
+func TestClient_UploadFileSplit_Resume(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	localPath := filepath.Join(dir, "image.img")
+	assert.Nil(t, ioutil.WriteFile(localPath, []byte("0123456789"), 0644))
+
+	client, disk := newFakeDiskClient()
+
+	// Previous upload has been interrupted while the second part was uploaded
+	disk.PutFile("/image.img.yadisk-split/part-00000", []byte("0123"))
+	disk.PutFile("/image.img.yadisk-split/part-00001", []byte("45"))
+
+	_, err := client.GetSplitManifest(context.Background(), "/image.img")
+	assert.Equal(t, ErrSplitIncomplete, err)
+
+	items, err := client.ListSplit(context.Background(), "/")
+	assert.Nil(t, err)
+	assert.Equal(t, ResourceTypeDirectory, items[0].Type)
+
+	since := len(disk.Requests)
+	assert.Nil(t, client.UploadFileSplit(context.Background(), localPath, "/image.img", &SplitOptions{PartSize: 4}))
+
+	// The second and the third parts, the manifest
+	assert.Equal(t, 3, countRequests(disk, since, "PUT /upload/"))
+
+	buf := &bytes.Buffer{}
+	_, err = client.DownloadSplitTo(context.Background(), "/image.img", buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "0123456789", buf.String())
+}
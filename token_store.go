@@ -0,0 +1,158 @@
+package yadisk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrTokenNotFound is returned by TokenStore implementations when there is
+// no stored token yet.
+var ErrTokenNotFound = errors.New("yadisk: token not found")
+
+// TokenStore persists OAuth tokens between application restarts.
+type TokenStore interface {
+	// Load returns previously saved token or ErrTokenNotFound.
+	Load() (*oauth2.Token, error)
+
+	// Save replaces stored token with the given one.
+	Save(token *oauth2.Token) error
+}
+
+// TokenStoreLocker is implemented by token stores that could be shared
+// between several processes. The lock is held while the token is being
+// refreshed, so only one process performs the refresh and the others pick
+// up its result.
+type TokenStoreLocker interface {
+	Lock() error
+	Unlock() error
+}
+
+// In-memory token store. It is safe for concurrent use, but obviously it
+// doesn't survive restarts, so it is mostly useful for tests and short-lived
+// processes.
+type MemoryTokenStore struct {
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+// Create in-memory token store with an optional initial token.
+func NewMemoryTokenStore(token *oauth2.Token) *MemoryTokenStore {
+	s := &MemoryTokenStore{}
+	if token != nil {
+		s.token = copyToken(token)
+	}
+	return s
+}
+
+func (s *MemoryTokenStore) Load() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == nil {
+		return nil, ErrTokenNotFound
+	}
+
+	return copyToken(s.token), nil
+}
+
+func (s *MemoryTokenStore) Save(token *oauth2.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = copyToken(token)
+
+	return nil
+}
+
+// Create token source which loads token from the store and saves every
+// refreshed token back to it.
+//
+// If store implements TokenStoreLocker, refresh is performed under the lock
+// and the store is re-read first: when another process has already refreshed
+// the token, its result is used instead of refreshing once again.
+func NewStoreTokenSource(ctx context.Context, config *oauth2.Config, store TokenStore) oauth2.TokenSource {
+	return &storeTokenSource{
+		ctx:    ctx,
+		config: config,
+		store:  store,
+	}
+}
+
+// Create client which loads its token from the store and persists every
+// refreshed token.
+func NewFromConfigAndTokenStore(config *oauth2.Config, store TokenStore, ctx context.Context) *Client {
+	httpClient := &http.Client{
+		Transport: &oauth2.Transport{
+			Source: NewStoreTokenSource(ctx, config, store),
+		},
+	}
+
+	return New(httpClient)
+}
+
+type storeTokenSource struct {
+	ctx    context.Context
+	config *oauth2.Config
+	store  TokenStore
+
+	mu    sync.Mutex
+	token *oauth2.Token
+}
+
+func (s *storeTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Valid() {
+		return s.token, nil
+	}
+
+	if locker, ok := s.store.(TokenStoreLocker); ok {
+		if err := locker.Lock(); err != nil {
+			return nil, err
+		}
+		defer locker.Unlock()
+	}
+
+	stored, err := s.store.Load()
+	if err != nil && err != ErrTokenNotFound {
+		return nil, err
+	}
+
+	// Someone else (possibly another process) has already refreshed the token
+	if stored.Valid() {
+		s.token = stored
+		return stored, nil
+	}
+
+	current := s.token
+	if stored != nil {
+		// Stored refresh token is the most recent one
+		current = stored
+	}
+	if current == nil {
+		return nil, ErrTokenNotFound
+	}
+
+	token, err := s.config.TokenSource(s.ctx, current).Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Save(token); err != nil {
+		return nil, err
+	}
+
+	s.token = token
+
+	return token, nil
+}
+
+func copyToken(token *oauth2.Token) *oauth2.Token {
+	t := *token
+	return &t
+}
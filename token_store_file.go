@@ -0,0 +1,216 @@
+package yadisk
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/oauth2"
+
+	"github.com/yurykabanov/go-yandex-disk/internal/atomicfile"
+)
+
+const (
+	tokenFileMode = 0600
+
+	encryptedTokenVersion = 1
+
+	// scrypt parameters recommended for interactive logins (as of 2017)
+	scryptN      = 32768
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+	scryptSalt   = 16
+)
+
+// ErrTokenDecrypt is returned when encrypted token file could not be
+// decrypted: either passphrase is wrong or the file is corrupted.
+var ErrTokenDecrypt = errors.New("yadisk: unable to decrypt token file")
+
+// File-backed token store.
+//
+// Token is written to a temporary file which then replaces the target file,
+// so readers never see partially written token. The file is only readable
+// and writable by its owner. Refreshes from several processes are serialized
+// with an advisory lock on the neighbouring "<path>.lock" file.
+type FileTokenStore struct {
+	path       string
+	passphrase []byte
+
+	// Guards lockFile, so goroutines of the same process are serialized too
+	mu       sync.Mutex
+	lockFile *os.File
+}
+
+// Create token store which keeps the token as plain JSON in the given file.
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{
+		path: path,
+	}
+}
+
+// Create token store which keeps the token in the given file encrypted with
+// AES-GCM using a key derived from the passphrase with scrypt.
+func NewEncryptedFileTokenStore(path, passphrase string) *FileTokenStore {
+	return &FileTokenStore{
+		path:       path,
+		passphrase: []byte(passphrase),
+	}
+}
+
+func (s *FileTokenStore) Load() (*oauth2.Token, error) {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if s.passphrase != nil {
+		data, err = s.decrypt(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var token oauth2.Token
+
+	err = json.Unmarshal(data, &token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (s *FileTokenStore) Save(token *oauth2.Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	if s.passphrase != nil {
+		data, err = s.encrypt(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return atomicfile.WriteFile(s.path, data, tokenFileMode)
+}
+
+// Lock acquires an exclusive lock shared between processes. It blocks until
+// the lock is acquired.
+func (s *FileTokenStore) Lock() error {
+	s.mu.Lock()
+
+	f, err := os.OpenFile(s.path+".lock", os.O_CREATE|os.O_RDWR, tokenFileMode)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	if err := lockFile(f); err != nil {
+		f.Close()
+		s.mu.Unlock()
+		return err
+	}
+
+	s.lockFile = f
+
+	return nil
+}
+
+func (s *FileTokenStore) Unlock() error {
+	f := s.lockFile
+	if f == nil {
+		return nil
+	}
+
+	s.lockFile = nil
+	defer s.mu.Unlock()
+
+	err := unlockFile(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+type encryptedToken struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+func (s *FileTokenStore) encrypt(plaintext []byte) ([]byte, error) {
+	salt := make([]byte, scryptSalt)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := s.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(encryptedToken{
+		Version: encryptedTokenVersion,
+		Salt:    salt,
+		Nonce:   nonce,
+		Data:    aead.Seal(nil, nonce, plaintext, nil),
+	})
+}
+
+func (s *FileTokenStore) decrypt(data []byte) ([]byte, error) {
+	var enc encryptedToken
+
+	if err := json.Unmarshal(data, &enc); err != nil || enc.Version != encryptedTokenVersion {
+		return nil, ErrTokenDecrypt
+	}
+
+	aead, err := s.aead(enc.Salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(enc.Nonce) != aead.NonceSize() {
+		return nil, ErrTokenDecrypt
+	}
+
+	plaintext, err := aead.Open(nil, enc.Nonce, enc.Data, nil)
+	if err != nil {
+		return nil, ErrTokenDecrypt
+	}
+
+	return plaintext, nil
+}
+
+func (s *FileTokenStore) aead(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(s.passphrase, salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,177 @@
+package yadisk
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestMemoryTokenStore(t *testing.T) {
+	store := NewMemoryTokenStore(nil)
+
+	token, err := store.Load()
+	assert.Nil(t, token)
+	assert.Equal(t, ErrTokenNotFound, err)
+
+	saved := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", TokenType: "OAuth"}
+	assert.Nil(t, store.Save(saved))
+
+	token, err = store.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, saved.AccessToken, token.AccessToken)
+	assert.Equal(t, saved.RefreshToken, token.RefreshToken)
+
+	// Store must not share the token with caller
+	token.AccessToken = "modified"
+	token, _ = store.Load()
+	assert.Equal(t, "access", token.AccessToken)
+}
+
+func TestFileTokenStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "yadisk-token-store")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	expiry := time.Date(2019, 4, 1, 12, 0, 0, 0, time.UTC)
+	saved := &oauth2.Token{AccessToken: "access", RefreshToken: "refresh", TokenType: "OAuth", Expiry: expiry}
+
+	tests := []struct {
+		name  string
+		store *FileTokenStore
+	}{
+		{
+			name:  "plain",
+			store: NewFileTokenStore(filepath.Join(dir, "plain.json")),
+		},
+		{
+			name:  "encrypted",
+			store: NewEncryptedFileTokenStore(filepath.Join(dir, "encrypted.json"), "secret"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, err := test.store.Load()
+			assert.Nil(t, token)
+			assert.Equal(t, ErrTokenNotFound, err)
+
+			assert.Nil(t, test.store.Save(saved))
+
+			token, err = test.store.Load()
+			assert.Nil(t, err)
+			assert.Equal(t, saved.AccessToken, token.AccessToken)
+			assert.Equal(t, saved.RefreshToken, token.RefreshToken)
+			assert.True(t, saved.Expiry.Equal(token.Expiry))
+
+			if runtime.GOOS != "windows" {
+				info, err := os.Stat(test.store.path)
+				assert.Nil(t, err)
+				assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+			}
+
+			assert.Nil(t, test.store.Lock())
+			assert.Nil(t, test.store.Unlock())
+		})
+	}
+
+	t.Run("encrypted file doesn't contain token", func(t *testing.T) {
+		data, err := ioutil.ReadFile(filepath.Join(dir, "encrypted.json"))
+		assert.Nil(t, err)
+		assert.NotContains(t, string(data), "access")
+		assert.NotContains(t, string(data), "refresh")
+	})
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		store := NewEncryptedFileTokenStore(filepath.Join(dir, "encrypted.json"), "wrong")
+
+		token, err := store.Load()
+		assert.Nil(t, token)
+		assert.Equal(t, ErrTokenDecrypt, err)
+	})
+}
+
+func newFakeTokenEndpoint(t *testing.T, refreshes *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Nil(t, r.ParseForm())
+		assert.Equal(t, "refresh_token", r.PostForm.Get("grant_type"))
+
+		n := atomic.AddInt32(refreshes, 1)
+
+		w.Header().Set("Content-Type", "application/json")
+		suffix := strconv.Itoa(int(n))
+		w.Write([]byte(`{"access_token":"access-` + suffix + `","token_type":"OAuth","refresh_token":"refresh-` + suffix + `","expires_in":3600}`))
+	}))
+}
+
+func TestStoreTokenSource(t *testing.T) {
+	var refreshes int32
+
+	server := newFakeTokenEndpoint(t, &refreshes)
+	defer server.Close()
+
+	config := &oauth2.Config{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		Endpoint:     oauth2.Endpoint{TokenURL: server.URL, AuthStyle: oauth2.AuthStyleInParams},
+	}
+
+	t.Run("empty store", func(t *testing.T) {
+		source := NewStoreTokenSource(context.Background(), config, NewMemoryTokenStore(nil))
+
+		token, err := source.Token()
+		assert.Nil(t, token)
+		assert.Equal(t, ErrTokenNotFound, err)
+	})
+
+	t.Run("valid stored token is used as is", func(t *testing.T) {
+		store := NewMemoryTokenStore(&oauth2.Token{AccessToken: "valid", RefreshToken: "refresh", Expiry: time.Now().Add(time.Hour)})
+		source := NewStoreTokenSource(context.Background(), config, store)
+
+		token, err := source.Token()
+		assert.Nil(t, err)
+		assert.Equal(t, "valid", token.AccessToken)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&refreshes))
+	})
+
+	t.Run("refreshed token is written back", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "yadisk-token-source")
+		assert.Nil(t, err)
+		defer os.RemoveAll(dir)
+
+		store := NewFileTokenStore(filepath.Join(dir, "token.json"))
+		assert.Nil(t, store.Save(&oauth2.Token{AccessToken: "expired", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)}))
+
+		source := NewStoreTokenSource(context.Background(), config, store)
+
+		token, err := source.Token()
+		assert.Nil(t, err)
+		assert.Equal(t, "access-1", token.AccessToken)
+
+		stored, err := store.Load()
+		assert.Nil(t, err)
+		assert.Equal(t, "access-1", stored.AccessToken)
+		assert.Equal(t, "refresh-1", stored.RefreshToken)
+
+		// Token is still valid, no more refreshes
+		token, err = source.Token()
+		assert.Nil(t, err)
+		assert.Equal(t, "access-1", token.AccessToken)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&refreshes))
+
+		// Another source (e.g. another process) picks up the refreshed token
+		token, err = NewStoreTokenSource(context.Background(), config, store).Token()
+		assert.Nil(t, err)
+		assert.Equal(t, "access-1", token.AccessToken)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&refreshes))
+	})
+}
@@ -0,0 +1,350 @@
+package yadisk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/yurykabanov/go-yandex-disk/internal/atomicfile"
+)
+
+// What to do when transfer destination already exists.
+type OverwritePolicy int
+
+const (
+	// Fail if destination already exists. Remote destination produces
+	// ApiError with "409 Conflict" status code, local one - ErrDestinationExists.
+	OverwriteNever OverwritePolicy = iota
+
+	// Replace existing destination.
+	OverwriteAlways
+
+	// Skip transfer silently if destination already exists.
+	OverwriteSkip
+)
+
+// ErrDestinationExists is returned when local destination file already
+// exists and overwrite policy is OverwriteNever. UploadFileSplit returns it
+// when remote destination exists.
+var ErrDestinationExists = errors.New("yadisk: destination already exists")
+
+// Error returned when storage host responds to upload or download with
+// unexpected HTTP status code.
+type TransferError struct {
+	StatusCode int
+}
+
+func (err TransferError) Error() string {
+	return fmt.Sprintf("yadisk: transfer failed: %d %s", err.StatusCode, http.StatusText(err.StatusCode))
+}
+
+// Options of UploadFile and UploadReader.
+type UploadOptions struct {
+	// What to do if remote file already exists. OverwriteNever by default.
+	Overwrite OverwritePolicy
+
+	// Codecs which encode content before upload, the first one is applied
+	// first. Their names and size of the original content are recorded in
+	// custom properties (see PropertyCodecs and PropertyOriginalSize), so
+	// content is decoded by DownloadDecodedTo and DownloadOptions.Decode.
+	// Uploaded file is deleted if its properties can't be recorded.
+	Codecs []Codec
+}
+
+// ErrInsufficientSpace is returned when there's not enough free space for
+// the downloaded file.
+var ErrInsufficientSpace = errors.New("yadisk: insufficient free space")
+
+// Options of DownloadFile.
+type DownloadOptions struct {
+	// What to do if local file already exists. OverwriteNever by default.
+	Overwrite OverwritePolicy
+
+	// Download to a temporary file in the destination directory, sync it and
+	// rename it to the destination once download is completed, so the
+	// destination is never seen partially written.
+	Atomic bool
+
+	// Set modification time of local file to Resource.Modified.
+	PreserveModTime bool
+
+	// Check free space before download and fail with ErrInsufficientSpace if
+	// it's not enough. It's not checked on platforms where free space could
+	// not be determined.
+	CheckFreeSpace bool
+
+	// Decode content according to codecs recorded in custom properties, see
+	// DownloadDecodedTo.
+	Decode bool
+}
+
+// Upload local file to the given remote path.
+//
+// localPath - The path to the local file.
+// remotePath - The path where you want to upload the file.
+// opts - Upload options, nil means default options.
+//
+// Unlike Upload, method returns TransferError if upload has failed.
+func (c *Client) UploadFile(ctx context.Context, localPath, remotePath string, opts *UploadOptions) error {
+	_, err := c.uploadFile(ctx, localPath, remotePath, opts)
+
+	return err
+}
+
+// Method returns true if upload has been skipped due to overwrite policy.
+func (c *Client) uploadFile(ctx context.Context, localPath, remotePath string, opts *UploadOptions) (bool, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	return c.uploadReader(ctx, f, remotePath, opts)
+}
+
+// Upload content of the reader to the given remote path.
+//
+// r - io.Reader of file contents.
+// remotePath - The path where you want to upload the file.
+// opts - Upload options, nil means default options.
+//
+// Unlike Upload, method returns TransferError if upload has failed.
+func (c *Client) UploadReader(ctx context.Context, r io.Reader, remotePath string, opts *UploadOptions) error {
+	_, err := c.uploadReader(ctx, r, remotePath, opts)
+
+	return err
+}
+
+// Method returns true if upload has been skipped due to overwrite policy.
+func (c *Client) uploadReader(ctx context.Context, r io.Reader, remotePath string, opts *UploadOptions) (bool, error) {
+	if opts == nil {
+		opts = &UploadOptions{}
+	}
+
+	if len(opts.Codecs) > 0 {
+		return c.uploadEncoded(ctx, r, remotePath, opts.Codecs, func(r io.Reader) (bool, error) {
+			return c.uploadRaw(ctx, r, remotePath, opts.Overwrite)
+		})
+	}
+
+	return c.uploadRaw(ctx, r, remotePath, opts.Overwrite)
+}
+
+func (c *Client) uploadRaw(ctx context.Context, r io.Reader, remotePath string, overwrite OverwritePolicy) (bool, error) {
+	link, err := c.RequestUploadLink(ctx, remotePath, overwrite == OverwriteAlways)
+	if err != nil {
+		if overwrite == OverwriteSkip && isConflict(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	statusCode, err := c.Upload(ctx, link, r)
+	if err != nil {
+		return false, err
+	}
+
+	if statusCode != http.StatusCreated && statusCode != http.StatusAccepted {
+		return false, TransferError{StatusCode: statusCode}
+	}
+
+	return false, nil
+}
+
+// Download remote file to the given local path.
+//
+// remotePath - The path to the file to download.
+// localPath - The path to the local file.
+// opts - Download options, nil means default options.
+//
+// Existing local file is replaced only once download succeeds (content is
+// downloaded to a temporary file first, see DownloadOptions.Atomic). Local
+// file created by download is removed if download fails or is cancelled.
+func (c *Client) DownloadFile(ctx context.Context, remotePath, localPath string, opts *DownloadOptions) error {
+	_, err := c.downloadFile(ctx, remotePath, localPath, nil, opts)
+
+	return err
+}
+
+// Method returns true if download has been skipped due to overwrite policy.
+// Resource is requested only if it's nil and it's needed.
+func (c *Client) downloadFile(ctx context.Context, remotePath, localPath string, resource *Resource, opts *DownloadOptions) (bool, error) {
+	if opts == nil {
+		opts = &DownloadOptions{}
+	}
+
+	if opts.Overwrite != OverwriteAlways {
+		if _, err := os.Lstat(localPath); err == nil {
+			if opts.Overwrite == OverwriteSkip {
+				return true, nil
+			}
+			return false, ErrDestinationExists
+		}
+	}
+
+	if resource == nil && (opts.PreserveModTime || opts.CheckFreeSpace || opts.Decode) {
+		var err error
+		if resource, err = c.GetResource(ctx, remotePath, 0, 0); err != nil {
+			return false, err
+		}
+	}
+
+	if opts.Decode {
+		codecs, size, err := resourceCodecs(resource)
+		if err != nil {
+			return false, err
+		}
+
+		if len(codecs) > 0 {
+			// Free space is needed for the decoded content
+			decoded := *resource
+			decoded.Size = size
+
+			return writeLocalFile(localPath, &decoded, opts, func(w io.Writer) error {
+				_, err := c.downloadDecodedTo(ctx, remotePath, resource, w)
+				return err
+			})
+		}
+	}
+
+	return writeLocalFile(localPath, resource, opts, func(w io.Writer) error {
+		_, err := c.DownloadTo(ctx, remotePath, w)
+		return err
+	})
+}
+
+// Write downloaded content to the local file according to download options.
+// Resource is used for its size and modification time, it could be nil unless
+// they're needed.
+//
+// Method returns true if download has been skipped due to overwrite policy.
+func writeLocalFile(localPath string, resource *Resource, opts *DownloadOptions, download func(w io.Writer) error) (bool, error) {
+	if opts.CheckFreeSpace {
+		available, err := availableSpace(filepath.Dir(localPath))
+		if err != nil {
+			return false, err
+		}
+		if available >= 0 && available < resource.Size {
+			return false, ErrInsufficientSpace
+		}
+	}
+
+	var modified time.Time
+	if opts.PreserveModTime {
+		modified = resource.Modified
+	}
+
+	atomic := opts.Atomic
+	if !atomic && opts.Overwrite == OverwriteAlways {
+		// Existing file is kept until the new content is downloaded
+		_, err := os.Lstat(localPath)
+		atomic = err == nil
+	}
+
+	if atomic {
+		return replaceFile(localPath, modified, opts.Overwrite, download)
+	}
+
+	f, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		// File has been created meanwhile
+		switch opts.Overwrite {
+		case OverwriteAlways:
+			return replaceFile(localPath, modified, opts.Overwrite, download)
+		case OverwriteSkip:
+			return true, nil
+		}
+		return false, ErrDestinationExists
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = download(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && !modified.IsZero() {
+		err = os.Chtimes(localPath, modified, modified)
+	}
+
+	if err != nil {
+		// File has been created by this call, don't leave it partially written
+		os.Remove(localPath)
+		return false, err
+	}
+
+	return false, nil
+}
+
+// Replace the destination atomically (see package atomicfile), so it's never
+// seen partially written. Modification time is set unless it's zero.
+//
+// Method returns true if destination has been created meanwhile and it's
+// skipped due to overwrite policy.
+func replaceFile(localPath string, modified time.Time, overwrite OverwritePolicy, write func(w io.Writer) error) (bool, error) {
+	skipped := false
+
+	err := atomicfile.Write(localPath, &atomicfile.Options{
+		Perm:    0644,
+		ModTime: modified,
+		BeforeRename: func() error {
+			if overwrite == OverwriteAlways {
+				return nil
+			}
+
+			// Destination could have been created during download
+			if _, err := os.Lstat(localPath); err == nil {
+				skipped = overwrite == OverwriteSkip
+				return ErrDestinationExists
+			}
+
+			return nil
+		},
+	}, write)
+	if skipped {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// Download remote file and write its content to w.
+//
+// Method returns number of written bytes. Unlike Download, it returns
+// TransferError if download has failed.
+func (c *Client) DownloadTo(ctx context.Context, remotePath string, w io.Writer) (int64, error) {
+	link, err := c.RequestDownloadLink(ctx, remotePath)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.Download(ctx, link)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return 0, TransferError{StatusCode: resp.StatusCode}
+	}
+
+	return io.Copy(w, resp.Body)
+}
+
+func isConflict(err error) bool {
+	apiErr, ok := err.(ApiError)
+	return ok && apiErr.StatusCode == http.StatusConflict
+}
+
+func isExistingDirectory(err error) bool {
+	apiErr, ok := err.(ApiError)
+	return ok && apiErr.StatusCode == http.StatusConflict && apiErr.ErrorID == "DiskPathPointsToExistentDirectoryError"
+}
@@ -0,0 +1,394 @@
+package yadisk
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/go-yandex-disk/internal/testhelpers"
+)
+
+func newFakeDiskClient() (*Client, *testhelpers.FakeDisk) {
+	disk := testhelpers.NewFakeDisk()
+	return New(disk.Client()), disk
+}
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "yadisk-test")
+	assert.Nil(t, err)
+
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestClient_UploadFile(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	localPath := filepath.Join(dir, "file.txt")
+	assert.Nil(t, ioutil.WriteFile(localPath, []byte("NEW CONTENT"), 0644))
+
+	tests := []struct {
+		name string
+
+		existing  string
+		overwrite OverwritePolicy
+
+		content string
+		error   error
+	}{
+		{
+			name:      "new file",
+			overwrite: OverwriteNever,
+
+			content: "NEW CONTENT",
+		},
+
+		{
+			name:      "existing file with OverwriteNever",
+			existing:  "OLD CONTENT",
+			overwrite: OverwriteNever,
+
+			content: "OLD CONTENT",
+			error:   ApiError{StatusCode: 409, Message: "DiskResourceAlreadyExistsError", Description: "fake DiskResourceAlreadyExistsError", ErrorID: "DiskResourceAlreadyExistsError"},
+		},
+
+		{
+			name:      "existing file with OverwriteAlways",
+			existing:  "OLD CONTENT",
+			overwrite: OverwriteAlways,
+
+			content: "NEW CONTENT",
+		},
+
+		{
+			name:      "existing file with OverwriteSkip",
+			existing:  "OLD CONTENT",
+			overwrite: OverwriteSkip,
+
+			content: "OLD CONTENT",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, disk := newFakeDiskClient()
+			disk.PutDir("/dir")
+			if test.existing != "" {
+				disk.PutFile("/dir/file.txt", []byte(test.existing))
+			}
+
+			err := client.UploadFile(context.Background(), localPath, "/dir/file.txt", &UploadOptions{Overwrite: test.overwrite})
+
+			assert.Equal(t, test.error, err)
+			assert.Equal(t, test.content, string(disk.File("/dir/file.txt").Content))
+		})
+	}
+}
+
+func TestClient_UploadReader(t *testing.T) {
+	client, disk := newFakeDiskClient()
+
+	err := client.UploadReader(context.Background(), strings.NewReader("CONTENT"), "/file.txt", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "CONTENT", string(disk.File("/file.txt").Content))
+
+	t.Run("storage failure", func(t *testing.T) {
+		disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+			if r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/upload/") {
+				w.WriteHeader(http.StatusInsufficientStorage)
+				return true
+			}
+			return false
+		}
+
+		err := client.UploadReader(context.Background(), strings.NewReader("CONTENT"), "/another.txt", nil)
+		assert.Equal(t, TransferError{StatusCode: 507}, err)
+	})
+}
+
+func TestClient_DownloadFile(t *testing.T) {
+	tests := []struct {
+		name string
+
+		existing  string
+		overwrite OverwritePolicy
+		remote    string
+
+		content string
+		exists  bool
+		error   error
+	}{
+		{
+			name:   "new file",
+			remote: "/dir/file.txt",
+
+			content: "REMOTE CONTENT",
+			exists:  true,
+		},
+
+		{
+			name:      "existing file with OverwriteNever",
+			existing:  "LOCAL CONTENT",
+			overwrite: OverwriteNever,
+			remote:    "/dir/file.txt",
+
+			content: "LOCAL CONTENT",
+			exists:  true,
+			error:   ErrDestinationExists,
+		},
+
+		{
+			name:      "existing file with OverwriteAlways",
+			existing:  "LOCAL CONTENT",
+			overwrite: OverwriteAlways,
+			remote:    "/dir/file.txt",
+
+			content: "REMOTE CONTENT",
+			exists:  true,
+		},
+
+		{
+			name:      "existing file with OverwriteSkip",
+			existing:  "LOCAL CONTENT",
+			overwrite: OverwriteSkip,
+			remote:    "/dir/file.txt",
+
+			content: "LOCAL CONTENT",
+			exists:  true,
+		},
+
+		{
+			name:   "missing remote file",
+			remote: "/dir/missing.txt",
+
+			exists: false,
+			error:  ApiError{StatusCode: 404, Message: "DiskNotFoundError", Description: "fake DiskNotFoundError", ErrorID: "DiskNotFoundError"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+
+			localPath := filepath.Join(dir, "file.txt")
+			if test.existing != "" {
+				assert.Nil(t, ioutil.WriteFile(localPath, []byte(test.existing), 0644))
+			}
+
+			client, disk := newFakeDiskClient()
+			disk.PutFile("/dir/file.txt", []byte("REMOTE CONTENT"))
+
+			err := client.DownloadFile(context.Background(), test.remote, localPath, &DownloadOptions{Overwrite: test.overwrite})
+			assert.Equal(t, test.error, err)
+
+			content, err := ioutil.ReadFile(localPath)
+			assert.Equal(t, test.exists, err == nil)
+			assert.Equal(t, test.content, string(content))
+		})
+	}
+}
+
+func TestClient_DownloadFile_FailedOverwrite(t *testing.T) {
+	tests := []struct {
+		name       string
+		remotePath string
+		intercept  func(w http.ResponseWriter, r *http.Request) bool
+
+		error error
+	}{
+		{
+			name:       "missing remote file",
+			remotePath: "/missing.txt",
+		},
+		{
+			name:       "failed download",
+			remotePath: "/file.txt",
+			intercept: func(w http.ResponseWriter, r *http.Request) bool {
+				if strings.HasPrefix(r.URL.Path, "/download/") {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return true
+				}
+				return false
+			},
+
+			error: TransferError{StatusCode: 503},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+
+			client, disk := newFakeDiskClient()
+			disk.PutFile("/file.txt", []byte("REMOTE CONTENT"))
+			disk.Intercept = test.intercept
+
+			localPath := filepath.Join(dir, "file.txt")
+			assert.Nil(t, ioutil.WriteFile(localPath, []byte("LOCAL CONTENT"), 0644))
+
+			err := client.DownloadFile(context.Background(), test.remotePath, localPath, &DownloadOptions{Overwrite: OverwriteAlways})
+			if test.error != nil {
+				assert.Equal(t, test.error, err)
+			} else {
+				assert.True(t, isNotFound(err), "%v", err)
+			}
+
+			// Existing file is kept and no temporary file is left
+			content, err := ioutil.ReadFile(localPath)
+			assert.Nil(t, err)
+			assert.Equal(t, "LOCAL CONTENT", string(content))
+
+			infos, _ := ioutil.ReadDir(dir)
+			assert.Len(t, infos, 1)
+		})
+	}
+}
+
+func TestClient_DownloadTo(t *testing.T) {
+	client, disk := newFakeDiskClient()
+	disk.PutFile("/file.txt", []byte("REMOTE CONTENT"))
+
+	buf := &bytes.Buffer{}
+
+	n, err := client.DownloadTo(context.Background(), "/file.txt", buf)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(14), n)
+	assert.Equal(t, "REMOTE CONTENT", buf.String())
+
+	t.Run("storage failure", func(t *testing.T) {
+		disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+			if strings.HasPrefix(r.URL.Path, "/download/") {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return true
+			}
+			return false
+		}
+
+		n, err := client.DownloadTo(context.Background(), "/file.txt", ioutil.Discard)
+		assert.Equal(t, int64(0), n)
+		assert.Equal(t, TransferError{StatusCode: 503}, err)
+	})
+}
+
+func TestClient_DownloadFile_Atomic(t *testing.T) {
+	modified := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+
+		opts      DownloadOptions
+		intercept func(w http.ResponseWriter, r *http.Request) bool
+
+		files   []string
+		content string
+		modTime time.Time
+		error   error
+	}{
+		{
+			name: "atomic download",
+			opts: DownloadOptions{Atomic: true},
+
+			files:   []string{"file.txt"},
+			content: "REMOTE CONTENT",
+		},
+
+		{
+			name: "atomic download preserving modification time",
+			opts: DownloadOptions{Atomic: true, PreserveModTime: true},
+
+			files:   []string{"file.txt"},
+			content: "REMOTE CONTENT",
+			modTime: modified,
+		},
+
+		{
+			name: "download preserving modification time",
+			opts: DownloadOptions{PreserveModTime: true},
+
+			files:   []string{"file.txt"},
+			content: "REMOTE CONTENT",
+			modTime: modified,
+		},
+
+		{
+			name: "failed atomic download",
+			opts: DownloadOptions{Atomic: true},
+			intercept: func(w http.ResponseWriter, r *http.Request) bool {
+				if strings.HasPrefix(r.URL.Path, "/download/") {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return true
+				}
+				return false
+			},
+
+			files: nil,
+			error: TransferError{StatusCode: 503},
+		},
+
+		{
+			name: "insufficient free space",
+			opts: DownloadOptions{Atomic: true, CheckFreeSpace: true},
+			intercept: func(w http.ResponseWriter, r *http.Request) bool {
+				if r.URL.Path == "/v1/disk/resources" {
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(`{"path": "disk:/file.txt", "type": "file", "size": 1152921504606846976}`))
+					return true
+				}
+				return false
+			},
+
+			files: nil,
+			error: ErrInsufficientSpace,
+		},
+
+		{
+			name: "sufficient free space",
+			opts: DownloadOptions{Atomic: true, CheckFreeSpace: true},
+
+			files:   []string{"file.txt"},
+			content: "REMOTE CONTENT",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+
+			client, disk := newFakeDiskClient()
+			disk.PutFile("/file.txt", []byte("REMOTE CONTENT"))
+			disk.File("/file.txt").Modified = modified
+			disk.Intercept = test.intercept
+
+			localPath := filepath.Join(dir, "file.txt")
+
+			err := client.DownloadFile(context.Background(), "/file.txt", localPath, &test.opts)
+			assert.Equal(t, test.error, err)
+
+			var files []string
+			infos, _ := ioutil.ReadDir(dir)
+			for _, info := range infos {
+				files = append(files, info.Name())
+			}
+			assert.Equal(t, test.files, files)
+
+			if test.error == nil {
+				content, err := ioutil.ReadFile(localPath)
+				assert.Nil(t, err)
+				assert.Equal(t, test.content, string(content))
+			}
+
+			if !test.modTime.IsZero() {
+				info, err := os.Stat(localPath)
+				assert.Nil(t, err)
+				assert.True(t, test.modTime.Equal(info.ModTime()), "modification time is %s", info.ModTime())
+			}
+		})
+	}
+}
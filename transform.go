@@ -0,0 +1,289 @@
+package yadisk
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Custom properties of files uploaded with UploadOptions.Codecs.
+const (
+	// Comma separated names of codecs in the order they've been applied.
+	PropertyCodecs = "yadisk_codecs"
+
+	// Size of content before it's been encoded.
+	PropertyOriginalSize = "yadisk_original_size"
+)
+
+// ErrUnknownCodec is returned when downloaded file has been encoded with
+// a codec which hasn't been registered.
+var ErrUnknownCodec = errors.New("yadisk: unknown codec")
+
+// Codec transforms content on upload and restores it on download, e.g.
+// compresses it.
+type Codec interface {
+	// Name of the codec recorded in custom properties of uploaded file.
+	Name() string
+
+	// Writer which encodes content and writes it to w. Close must flush
+	// encoded content, but it must not close w.
+	Encode(w io.Writer) (io.WriteCloser, error)
+
+	// Reader which decodes content read from r.
+	Decode(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[string]Codec)
+)
+
+func init() {
+	RegisterCodec(GzipCodec)
+}
+
+// Register codec to decode downloaded files by its name. Gzip is registered
+// by default. Codec registered with the same name replaces the previous one.
+func RegisterCodec(codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	codecs[codec.Name()] = codec
+}
+
+func lookupCodec(name string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	codec, ok := codecs[name]
+	return codec, ok
+}
+
+// GzipCodec compresses content with gzip with default compression level.
+var GzipCodec Codec = NewGzipCodec(gzip.DefaultCompression)
+
+type gzipCodec struct {
+	level int
+}
+
+// Create gzip codec with the given compression level, see compress/gzip.
+// Content is decoded the same way regardless of the level.
+func NewGzipCodec(level int) Codec {
+	return gzipCodec{level: level}
+}
+
+func (c gzipCodec) Name() string {
+	return "gzip"
+}
+
+func (c gzipCodec) Encode(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, c.level)
+}
+
+func (c gzipCodec) Decode(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// Upload content encoded with the codecs and record them in custom
+// properties.
+func (c *Client) uploadEncoded(ctx context.Context, r io.Reader, remotePath string, codecs []Codec, upload func(r io.Reader) (bool, error)) (bool, error) {
+	er := newEncodingReader(r, codecs)
+
+	skipped, err := upload(er)
+	er.Close()
+
+	if err != nil || skipped {
+		return skipped, err
+	}
+
+	names := make([]string, len(codecs))
+	for i, codec := range codecs {
+		names[i] = codec.Name()
+	}
+
+	_, err = c.SetCustomProperties(ctx, remotePath, map[string]string{
+		PropertyCodecs:       strings.Join(names, ","),
+		PropertyOriginalSize: strconv.FormatInt(er.sourceSize(), 10),
+	})
+	if err != nil {
+		// Encoded content can't be decoded without properties, don't keep it
+		c.deletePermanently(ctx, remotePath)
+		return false, err
+	}
+
+	return false, nil
+}
+
+// Content of the reader encoded with the codecs. It's encoded in background
+// once it's read for the first time, so the source isn't consumed if upload
+// fails or is skipped before content is sent. Close stops encoding, it
+// doesn't wait for the source which has no data yet.
+type encodingReader struct {
+	pr    *io.PipeReader
+	start sync.Once
+	done  chan struct{}
+
+	encode func()
+
+	// Size of the source content, it's known once encoded content is read
+	// till the end
+	size int64
+}
+
+func newEncodingReader(r io.Reader, codecs []Codec) *encodingReader {
+	pr, pw := io.Pipe()
+	er := &encodingReader{pr: pr, done: make(chan struct{})}
+
+	er.encode = func() {
+		defer close(er.done)
+
+		pw.CloseWithError(er.encodeTo(pw, r, codecs))
+	}
+
+	return er
+}
+
+func (er *encodingReader) encodeTo(w io.Writer, r io.Reader, codecs []Codec) error {
+	// The first codec is applied first, so it writes to the next one
+	writers := make([]io.WriteCloser, len(codecs))
+	for i := len(codecs) - 1; i >= 0; i-- {
+		cw, err := codecs[i].Encode(w)
+		if err != nil {
+			return err
+		}
+		writers[i], w = cw, cw
+	}
+
+	n, err := io.Copy(w, r)
+	if err != nil {
+		return err
+	}
+
+	for _, cw := range writers {
+		if err := cw.Close(); err != nil {
+			return err
+		}
+	}
+
+	er.size = n
+	return nil
+}
+
+func (er *encodingReader) Read(p []byte) (int, error) {
+	er.start.Do(func() { go er.encode() })
+
+	return er.pr.Read(p)
+}
+
+func (er *encodingReader) Close() error {
+	// Encoding is never started after Close
+	er.start.Do(func() { close(er.done) })
+
+	// Encoding goroutine stops at its next write
+	return er.pr.Close()
+}
+
+// Size of the source content. Encoded content must have been read till the
+// end, then encoding is finished (or it's about to finish).
+func (er *encodingReader) sourceSize() int64 {
+	<-er.done
+
+	return er.size
+}
+
+// Codecs recorded in custom properties of the resource, nil if content hasn't
+// been encoded.
+func resourceCodecs(resource *Resource) ([]Codec, int64, error) {
+	value := resource.CustomProperties[PropertyCodecs]
+	if value == "" {
+		return nil, resource.Size, nil
+	}
+
+	var result []Codec
+	for _, name := range strings.Split(value, ",") {
+		codec, ok := lookupCodec(name)
+		if !ok {
+			return nil, 0, ErrUnknownCodec
+		}
+		result = append(result, codec)
+	}
+
+	size, err := strconv.ParseInt(resource.CustomProperties[PropertyOriginalSize], 10, 64)
+	if err != nil {
+		size = -1
+	}
+
+	return result, size, nil
+}
+
+// Download remote file, decode it according to codecs recorded in its custom
+// properties and write its content to w.
+//
+// remotePath - The path to the file to download.
+//
+// Files which haven't been uploaded with UploadOptions.Codecs are written as
+// is. Method returns ErrUnknownCodec if a codec hasn't been registered (see
+// RegisterCodec) and number of written bytes otherwise.
+func (c *Client) DownloadDecodedTo(ctx context.Context, remotePath string, w io.Writer) (int64, error) {
+	resource, err := c.GetResource(ctx, remotePath, 0, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.downloadDecodedTo(ctx, remotePath, resource, w)
+}
+
+func (c *Client) downloadDecodedTo(ctx context.Context, remotePath string, resource *Resource, w io.Writer) (int64, error) {
+	codecs, size, err := resourceCodecs(resource)
+	if err != nil {
+		return 0, err
+	}
+	if len(codecs) == 0 {
+		return c.DownloadTo(ctx, remotePath, w)
+	}
+
+	link, err := c.RequestDownloadLink(ctx, remotePath)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.Download(ctx, link)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(ioutil.Discard, resp.Body)
+		return 0, TransferError{StatusCode: resp.StatusCode}
+	}
+
+	// The last codec has been applied last, so it's decoded first
+	var r io.Reader = resp.Body
+	for i := len(codecs) - 1; i >= 0; i-- {
+		cr, err := codecs[i].Decode(r)
+		if err != nil {
+			return 0, err
+		}
+		defer cr.Close()
+		r = cr
+	}
+
+	n, err := io.Copy(w, r)
+	if err != nil {
+		return n, err
+	}
+
+	if size >= 0 && n != size {
+		return n, fmt.Errorf("yadisk: decoded size %d doesn't match original size %d", n, size)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,204 @@
+package yadisk
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Codec which inverts bits of content.
+type invertCodec struct{}
+
+func (invertCodec) Name() string {
+	return "invert"
+}
+
+func (invertCodec) Encode(w io.Writer) (io.WriteCloser, error) {
+	return invertWriter{w}, nil
+}
+
+func (invertCodec) Decode(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(invertReader{r}), nil
+}
+
+type invertWriter struct {
+	w io.Writer
+}
+
+func (w invertWriter) Write(p []byte) (int, error) {
+	inverted := make([]byte, len(p))
+	for i, b := range p {
+		inverted[i] = ^b
+	}
+	return w.w.Write(inverted)
+}
+
+func (w invertWriter) Close() error {
+	return nil
+}
+
+type invertReader struct {
+	r io.Reader
+}
+
+func (r invertReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	for i := range p[:n] {
+		p[i] = ^p[i]
+	}
+	return n, err
+}
+
+func TestClient_UploadReader_Codecs(t *testing.T) {
+	RegisterCodec(invertCodec{})
+
+	content := strings.Repeat("2019-03-01 12:00:00 INFO request handled\n", 100)
+
+	tests := []struct {
+		name   string
+		codecs []Codec
+
+		property string
+	}{
+		{
+			name:   "gzip",
+			codecs: []Codec{GzipCodec},
+
+			property: "gzip",
+		},
+		{
+			name:   "pipeline",
+			codecs: []Codec{NewGzipCodec(9), invertCodec{}},
+
+			property: "gzip,invert",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+
+			client, disk := newFakeDiskClient()
+			ctx := context.Background()
+
+			err := client.UploadReader(ctx, strings.NewReader(content), "/app.log", &UploadOptions{Codecs: test.codecs})
+			assert.Nil(t, err)
+
+			file := disk.File("/app.log")
+			assert.True(t, len(file.Content) < len(content)/10)
+			assert.Equal(t, map[string]string{
+				PropertyCodecs:       test.property,
+				PropertyOriginalSize: "4100",
+			}, file.CustomProperties)
+
+			buf := &bytes.Buffer{}
+			n, err := client.DownloadDecodedTo(ctx, "/app.log", buf)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(len(content)), n)
+			assert.Equal(t, content, buf.String())
+
+			localPath := filepath.Join(dir, "app.log")
+			assert.Nil(t, client.DownloadFile(ctx, "/app.log", localPath, &DownloadOptions{Decode: true, CheckFreeSpace: true}))
+			downloaded, _ := ioutil.ReadFile(localPath)
+			assert.Equal(t, content, string(downloaded))
+		})
+	}
+}
+
+func TestClient_UploadReader_CodecsPropertiesFailure(t *testing.T) {
+	client, disk := newFakeDiskClient()
+	disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPatch {
+			return false
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	}
+
+	err := client.UploadReader(context.Background(), strings.NewReader("CONTENT"), "/app.log", &UploadOptions{Codecs: []Codec{GzipCodec}})
+	assert.NotNil(t, err)
+	assert.Empty(t, disk.Files())
+}
+
+func TestClient_UploadReader_CodecsBlockingSource(t *testing.T) {
+	client, disk := newFakeDiskClient()
+	disk.PutFile("/app.log", []byte("OLD"))
+
+	tests := []struct {
+		name      string
+		overwrite OverwritePolicy
+	}{
+		{name: "conflict", overwrite: OverwriteNever},
+		{name: "skip", overwrite: OverwriteSkip},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Source which has no data yet, like stdin
+			pr, pw := io.Pipe()
+			defer pw.Close()
+
+			done := make(chan error)
+			go func() {
+				done <- client.UploadReader(context.Background(), pr, "/app.log", &UploadOptions{Overwrite: test.overwrite, Codecs: []Codec{GzipCodec}})
+			}()
+
+			select {
+			case err := <-done:
+				if test.overwrite == OverwriteSkip {
+					assert.Nil(t, err)
+				} else {
+					assert.True(t, isConflict(err), "%v", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("upload hasn't returned")
+			}
+
+			// Source hasn't been consumed
+			go pw.Write([]byte("NEW"))
+			buf := make([]byte, 3)
+			_, err := io.ReadFull(pr, buf)
+			assert.Nil(t, err)
+			assert.Equal(t, "NEW", string(buf))
+		})
+	}
+}
+
+func TestClient_DownloadDecodedTo(t *testing.T) {
+	client, disk := newFakeDiskClient()
+	ctx := context.Background()
+
+	// Files without codecs are downloaded as is
+	disk.PutFile("/plain.txt", []byte("PLAIN"))
+	buf := &bytes.Buffer{}
+	_, err := client.DownloadDecodedTo(ctx, "/plain.txt", buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "PLAIN", buf.String())
+
+	disk.PutFile("/unknown.zst", []byte("ZSTD"))
+	_, err = client.SetCustomProperties(ctx, "/unknown.zst", map[string]string{PropertyCodecs: "zstd"})
+	assert.Nil(t, err)
+	_, err = client.DownloadDecodedTo(ctx, "/unknown.zst", ioutil.Discard)
+	assert.Equal(t, ErrUnknownCodec, err)
+
+	// Encoded content is truncated
+	assert.Nil(t, client.UploadReader(ctx, strings.NewReader("CONTENT"), "/file.txt", &UploadOptions{Codecs: []Codec{GzipCodec}}))
+	file := disk.File("/file.txt")
+	disk.PutFile("/file.txt", file.Content[:len(file.Content)-4])
+	_, err = client.SetCustomProperties(ctx, "/file.txt", file.CustomProperties)
+	assert.Nil(t, err)
+	_, err = client.DownloadDecodedTo(ctx, "/file.txt", ioutil.Discard)
+	assert.NotNil(t, err)
+
+	// Skipped upload doesn't change properties
+	assert.Nil(t, client.UploadReader(ctx, strings.NewReader("NEW"), "/plain.txt", &UploadOptions{Overwrite: OverwriteSkip, Codecs: []Codec{GzipCodec}}))
+	assert.Nil(t, disk.File("/plain.txt").CustomProperties)
+}
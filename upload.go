@@ -20,7 +20,7 @@ const (
 // uploaded to a folder that already contains a file with the same name.
 //
 // Method returns a Link if it has succeeded. Note that link is accessible
-// only for 30 minutes.
+// only for 30 minutes, see Link.Expired and UploadWithFreshLink.
 //
 // See: https://tech.yandex.com/disk/api/reference/upload-docpage/
 func (c *Client) RequestUploadLink(ctx context.Context, path string, overwrite bool) (*Link, error) {
@@ -39,6 +39,11 @@ func (c *Client) RequestUploadLink(ctx context.Context, path string, overwrite b
 		return nil, err
 	}
 
+	link.IssuedAt = c.now()
+	link.path = path
+	link.kind = linkKindUpload
+	link.overwrite = overwrite
+
 	return &link, nil
 }
 
@@ -54,11 +59,19 @@ func (c *Client) RequestUploadLink(ctx context.Context, path string, overwrite b
 // successful request with 4xx-5xx HTTP response codes. The application
 // MUST check response code by itself.
 //
+// If verification is requested (see WithVerification), method returns
+// ChecksumMismatchError if uploaded file doesn't match the uploaded content.
+//
 // See: https://tech.yandex.com/disk/api/reference/upload-docpage/
 func (c *Client) Upload(ctx context.Context, link *Link, r io.Reader) (int, error) {
 	statusCode := 0
 
+	ctx, hashes := startUploadVerification(ctx, link)
+
 	resp, err := c.doRawRequest(ctx, link.Method, link.Href, r)
+	if link.path != "" {
+		c.invalidateCache(link.path)
+	}
 	if resp != nil {
 		statusCode = resp.StatusCode
 	}
@@ -66,7 +79,13 @@ func (c *Client) Upload(ctx context.Context, link *Link, r io.Reader) (int, erro
 		return statusCode, err
 	}
 
-	defer resp.Body.Close()
+	resp.Body.Close()
+
+	if hashes != nil && (statusCode == http.StatusCreated || statusCode == http.StatusAccepted) {
+		if err := c.verifyUpload(ctx, link.path, hashes); err != nil {
+			return statusCode, err
+		}
+	}
 
 	return statusCode, nil
 }
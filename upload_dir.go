@@ -0,0 +1,269 @@
+package yadisk
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+const defaultDirTransferWorkers = 4
+
+// ErrPartialTransfer is returned by directory transfers when some of the
+// entries have failed, see DirTransferReport for details.
+var ErrPartialTransfer = errors.New("yadisk: some of the files have not been transferred")
+
+// Result of transfer of a single directory entry.
+type FileTransferResult struct {
+	LocalPath  string
+	RemotePath string
+
+	// Size of the file.
+	Size int64
+
+	// Transfer has been skipped due to overwrite policy.
+	Skipped bool
+
+	// Error of the transfer, nil if transfer has succeeded or been skipped.
+	Err error
+}
+
+// Report of directory transfer.
+type DirTransferReport struct {
+	// Results of transferred files in the order of walk. Directories which
+	// could not be read or created are reported as well, their content is
+	// not transferred.
+	Files []FileTransferResult
+}
+
+// Results of entries which have failed.
+func (r *DirTransferReport) Failed() []FileTransferResult {
+	var failed []FileTransferResult
+	for _, result := range r.Files {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+
+	return failed
+}
+
+// Options of UploadDir.
+type UploadDirOptions struct {
+	// What to do if remote file already exists. OverwriteNever by default.
+	Overwrite OverwritePolicy
+
+	// Number of simultaneous uploads. 4 by default.
+	Workers int
+
+	// Only files matching any of these patterns are uploaded, all files are
+	// uploaded if it's empty. Pattern syntax is the one of path.Match, pattern
+	// is matched against both the slash-separated path relative to the
+	// uploaded directory and the base name.
+	Include []string
+
+	// Files and directories matching any of these patterns are not uploaded.
+	Exclude []string
+}
+
+// Upload local directory tree to the given remote path.
+//
+// localDir - The path to the local directory.
+// remoteDir - The path of the remote directory, it's created along with its
+// parents if it doesn't exist.
+// opts - Upload options, nil means default options.
+//
+// Remote directories are created before their content is uploaded (even if
+// no file is uploaded to them), existing directories are reused. Only regular
+// files are uploaded, symbolic links are not followed.
+//
+// Method returns report of every uploaded file. If some of the files have
+// failed, the report is returned along with ErrPartialTransfer. Other errors
+// mean that upload hasn't been started or has been interrupted.
+func (c *Client) UploadDir(ctx context.Context, localDir, remoteDir string, opts *UploadDirOptions) (*DirTransferReport, error) {
+	if opts == nil {
+		opts = &UploadDirOptions{}
+	}
+
+	filter, err := newPathFilter(opts.Include, opts.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(localDir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, &os.PathError{Op: "upload", Path: localDir, Err: errors.New("not a directory")}
+	}
+
+	if err := c.MkdirAll(ctx, remoteDir); err != nil {
+		return nil, err
+	}
+
+	report := &DirTransferReport{}
+
+	// Directories are created while walking, so they're created in dependency
+	// order before any file is uploaded
+	err = filepath.Walk(localDir, func(localPath string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if localPath == localDir {
+			return err
+		}
+
+		rel, relErr := filepath.Rel(localDir, localPath)
+		if relErr != nil {
+			return relErr
+		}
+		rel = filepath.ToSlash(rel)
+
+		result := FileTransferResult{LocalPath: localPath, RemotePath: path.Join(remoteDir, rel)}
+
+		if err != nil {
+			result.Err = err
+			report.Files = append(report.Files, result)
+			return nil
+		}
+
+		if info.IsDir() {
+			if filter.excluded(rel) {
+				return filepath.SkipDir
+			}
+
+			if err := c.createDirectory(ctx, result.RemotePath); err != nil {
+				result.Err = err
+				report.Files = append(report.Files, result)
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !info.Mode().IsRegular() || !filter.matches(rel) {
+			return nil
+		}
+
+		result.Size = info.Size()
+		report.Files = append(report.Files, result)
+
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = defaultDirTransferWorkers
+	}
+
+	uploadOpts := &UploadOptions{Overwrite: opts.Overwrite}
+
+	jobs := make(chan *FileTransferResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for result := range jobs {
+				result.Skipped, result.Err = c.uploadFile(ctx, result.LocalPath, result.RemotePath, uploadOpts)
+			}
+		}()
+	}
+
+feed:
+	for i := range report.Files {
+		if report.Files[i].Err != nil {
+			continue
+		}
+
+		select {
+		case jobs <- &report.Files[i]:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return report, ctx.Err()
+	}
+
+	if len(report.Failed()) > 0 {
+		return report, ErrPartialTransfer
+	}
+
+	return report, nil
+}
+
+// Create remote directory unless it already exists.
+func (c *Client) createDirectory(ctx context.Context, remotePath string) error {
+	_, err := c.CreateDirectory(ctx, remotePath)
+	if err != nil && !isExistingDirectory(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Include and exclude patterns of directory transfers.
+type pathFilter struct {
+	include []string
+	exclude []string
+}
+
+func newPathFilter(include, exclude []string) (*pathFilter, error) {
+	for _, pattern := range append(append([]string{}, include...), exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return &pathFilter{include: include, exclude: exclude}, nil
+}
+
+// Whether file with the given slash-separated relative path is transferred.
+func (f *pathFilter) matches(rel string) bool {
+	if f.excluded(rel) {
+		return false
+	}
+
+	return len(f.include) == 0 || matchAny(f.include, rel)
+}
+
+func (f *pathFilter) excluded(rel string) bool {
+	return matchAny(f.exclude, rel)
+}
+
+// Whether the path or any of its parent directories is excluded.
+func (f *pathFilter) excludedTree(rel string) bool {
+	for ; rel != "." && rel != "/" && rel != ""; rel = path.Dir(rel) {
+		if f.excluded(rel) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+
+	return false
+}
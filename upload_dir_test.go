@@ -0,0 +1,219 @@
+package yadisk
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Create local files (and their directories) with content equal to the path.
+func writeTree(t *testing.T, dir string, paths ...string) {
+	for _, p := range paths {
+		localPath := filepath.Join(dir, filepath.FromSlash(p))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(localPath), 0755))
+		assert.Nil(t, ioutil.WriteFile(localPath, []byte(p), 0644))
+	}
+}
+
+func TestClient_UploadDir(t *testing.T) {
+	tests := []struct {
+		name string
+
+		opts     *UploadDirOptions
+		existing []string
+		failing  string
+
+		files   []string
+		dirs    []string
+		skipped []string
+		failed  []string
+		error   error
+	}{
+		{
+			name: "whole tree",
+
+			files: []string{"/dst/a.txt", "/dst/b.log", "/dst/skip/e.txt", "/dst/sub/c.txt", "/dst/sub/deep/d.txt"},
+			dirs:  []string{"/dst/empty", "/dst/skip", "/dst/sub/deep"},
+		},
+
+		{
+			name: "excluded files and directories",
+			opts: &UploadDirOptions{Exclude: []string{"skip", "*.log"}},
+
+			files: []string{"/dst/a.txt", "/dst/sub/c.txt", "/dst/sub/deep/d.txt"},
+			dirs:  []string{"/dst/empty", "/dst/sub/deep"},
+		},
+
+		{
+			name: "included files",
+			opts: &UploadDirOptions{Include: []string{"sub/*", "*.log"}, Workers: 1},
+
+			files: []string{"/dst/b.log", "/dst/sub/c.txt"},
+			dirs:  []string{"/dst/empty", "/dst/skip", "/dst/sub/deep"},
+		},
+
+		{
+			name:     "existing files are skipped",
+			opts:     &UploadDirOptions{Overwrite: OverwriteSkip},
+			existing: []string{"/dst/a.txt", "/dst/sub/c.txt"},
+
+			files:   []string{"/dst/a.txt", "/dst/b.log", "/dst/skip/e.txt", "/dst/sub/c.txt", "/dst/sub/deep/d.txt"},
+			dirs:    []string{"/dst/empty", "/dst/skip", "/dst/sub/deep"},
+			skipped: []string{"/dst/a.txt", "/dst/sub/c.txt"},
+		},
+
+		{
+			name:     "existing files are overwritten",
+			opts:     &UploadDirOptions{Overwrite: OverwriteAlways},
+			existing: []string{"/dst/a.txt"},
+
+			files: []string{"/dst/a.txt", "/dst/b.log", "/dst/skip/e.txt", "/dst/sub/c.txt", "/dst/sub/deep/d.txt"},
+			dirs:  []string{"/dst/empty", "/dst/skip", "/dst/sub/deep"},
+		},
+
+		{
+			name:     "existing files fail",
+			existing: []string{"/dst/a.txt"},
+
+			files:  []string{"/dst/a.txt", "/dst/b.log", "/dst/skip/e.txt", "/dst/sub/c.txt", "/dst/sub/deep/d.txt"},
+			dirs:   []string{"/dst/empty", "/dst/skip", "/dst/sub/deep"},
+			failed: []string{"/dst/a.txt"},
+			error:  ErrPartialTransfer,
+		},
+
+		{
+			name:    "failed upload",
+			failing: "/dst/sub/c.txt",
+
+			files:  []string{"/dst/a.txt", "/dst/b.log", "/dst/skip/e.txt", "/dst/sub/deep/d.txt"},
+			dirs:   []string{"/dst/empty", "/dst/skip", "/dst/sub/deep"},
+			failed: []string{"/dst/sub/c.txt"},
+			error:  ErrPartialTransfer,
+		},
+
+		{
+			name:    "failed directory",
+			failing: "/dst/sub",
+
+			files:  []string{"/dst/a.txt", "/dst/b.log", "/dst/skip/e.txt"},
+			dirs:   []string{"/dst/empty", "/dst/skip"},
+			failed: []string{"/dst/sub"},
+			error:  ErrPartialTransfer,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+
+			writeTree(t, dir, "a.txt", "b.log", "sub/c.txt", "sub/deep/d.txt", "skip/e.txt")
+			assert.Nil(t, os.Mkdir(filepath.Join(dir, "empty"), 0755))
+
+			client, disk := newFakeDiskClient()
+			for _, p := range test.existing {
+				disk.PutFile(p, []byte("EXISTING"))
+			}
+
+			if test.failing != "" {
+				disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+					if r.URL.Query().Get("path") != test.failing {
+						return false
+					}
+
+					w.WriteHeader(http.StatusInsufficientStorage)
+					w.Write([]byte(`{"error":"DiskOverQuotaError"}`))
+					return true
+				}
+			}
+
+			report, err := client.UploadDir(context.Background(), dir, "/dst", test.opts)
+			assert.Equal(t, test.error, err)
+			assert.Equal(t, test.files, disk.Files())
+
+			for _, p := range test.dirs {
+				assert.True(t, disk.Dir(p), p)
+			}
+
+			var skipped, failed []string
+			for _, result := range report.Files {
+				if result.Skipped {
+					skipped = append(skipped, result.RemotePath)
+				}
+				if result.Err != nil {
+					failed = append(failed, result.RemotePath)
+				}
+			}
+			assert.Equal(t, test.skipped, skipped)
+			assert.Equal(t, test.failed, failed)
+			assert.Len(t, report.Failed(), len(test.failed))
+
+			// Uploaded files have their own content
+			for _, result := range report.Files {
+				if result.Err == nil && !result.Skipped {
+					rel, _ := filepath.Rel(dir, result.LocalPath)
+					assert.Equal(t, filepath.ToSlash(rel), string(disk.File(result.RemotePath).Content))
+				}
+			}
+		})
+	}
+}
+
+func TestClient_UploadDir_Errors(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	writeTree(t, dir, "file.txt")
+
+	client, disk := newFakeDiskClient()
+	disk.PutFile("/file.txt", []byte("EXISTING"))
+
+	_, err := client.UploadDir(context.Background(), dir, "/dst", &UploadDirOptions{Include: []string{"["}})
+	assert.Equal(t, path.ErrBadPattern, err)
+
+	_, err = client.UploadDir(context.Background(), filepath.Join(dir, "missing"), "/dst", nil)
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = client.UploadDir(context.Background(), filepath.Join(dir, "file.txt"), "/dst", nil)
+	assert.NotNil(t, err)
+
+	// Remote destination is a file
+	_, err = client.UploadDir(context.Background(), dir, "/file.txt", nil)
+	assert.Equal(t, NotDirectoryError{Path: "/file.txt"}, err)
+}
+
+func TestClient_UploadDir_Canceled(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	var paths []string
+	for i := 0; i < 20; i++ {
+		paths = append(paths, fmt.Sprintf("file%02d.txt", i))
+	}
+	writeTree(t, dir, paths...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client, disk := newFakeDiskClient()
+	disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/upload/") {
+			cancel()
+		}
+		return false
+	}
+
+	_, err := client.UploadDir(ctx, dir, "/dst", &UploadDirOptions{Workers: 1})
+	assert.Equal(t, context.Canceled, err)
+
+	// Remaining files are not attempted
+	uploads := countRequests(disk, 0, "GET /v1/disk/resources/upload")
+	assert.True(t, uploads <= 2, "%d uploads have been started", uploads)
+}
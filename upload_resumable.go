@@ -0,0 +1,427 @@
+package yadisk
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yurykabanov/go-yandex-disk/internal/atomicfile"
+)
+
+const (
+	defaultResumableChunkSize = 8 << 20
+
+	resumableStateVersion = 1
+	resumableStateSuffix  = ".yadisk-upload"
+
+	// Upload host responds with this status code while upload is incomplete
+	statusResumeIncomplete = 308
+)
+
+// Upload host doesn't know the upload session (it has expired or it's unknown).
+var errUploadSessionLost = errors.New("yadisk: upload session lost")
+
+// Options of UploadFileResumable.
+type ResumableUploadOptions struct {
+	// Whether to overwrite existing remote file.
+	Overwrite bool
+
+	// Path of the file where upload state is persisted.
+	// "<localPath>.yadisk-upload" by default.
+	StatePath string
+
+	// Size of the content sent with one request. 8 MiB by default.
+	ChunkSize int64
+
+	// How many times upload is resumed within the call after a failure before
+	// giving up. The upload could be resumed later by another call anyway.
+	Retries int
+
+	// Delay between retries. 1 second by default.
+	RetryDelay time.Duration
+}
+
+// Persisted state of resumable upload.
+type resumableState struct {
+	Version int `json:"version"`
+
+	RemotePath string    `json:"remote_path"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mod_time"`
+
+	Href     string    `json:"href"`
+	Method   string    `json:"method"`
+	IssuedAt time.Time `json:"issued_at"`
+
+	// Number of bytes received by the upload host and checksums of them
+	Offset      int64  `json:"offset"`
+	Md5State    []byte `json:"md5_state"`
+	Sha256State []byte `json:"sha256_state"`
+}
+
+// Upload local file so that the upload could be resumed after interruption.
+//
+// File is sent in chunks using Content-Range header. After each chunk the
+// upload state (link, offset and checksums of the uploaded part) is saved to
+// the state file, so when the process is restarted and the method is called
+// again, upload continues from the last byte received by the upload host.
+// Upload restarts from the beginning if the local file has been changed or the
+// link has expired. State file is removed once upload is completed.
+//
+// Uploaded file is verified if it's requested with WithVerification.
+//
+// Method returns TransferError if upload host responds with unexpected status.
+func (c *Client) UploadFileResumable(ctx context.Context, localPath, remotePath string, opts *ResumableUploadOptions) error {
+	_, err := c.uploadFileResumable(ctx, localPath, remotePath, opts)
+	return err
+}
+
+// Method returns completed upload, so callers could get checksums of the
+// uploaded content.
+func (c *Client) uploadFileResumable(ctx context.Context, localPath, remotePath string, opts *ResumableUploadOptions) (*resumableUpload, error) {
+	if opts == nil {
+		opts = &ResumableUploadOptions{}
+	}
+
+	u := &resumableUpload{
+		client:     c,
+		statePath:  opts.StatePath,
+		remotePath: remotePath,
+		overwrite:  opts.Overwrite,
+		chunkSize:  opts.ChunkSize,
+	}
+	if u.statePath == "" {
+		u.statePath = localPath + resumableStateSuffix
+	}
+	if u.chunkSize <= 0 {
+		u.chunkSize = defaultResumableChunkSize
+	}
+
+	retryDelay := opts.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = time.Second
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	u.file = f
+	u.size = info.Size()
+	u.modTime = info.ModTime()
+
+	u.restore()
+
+	ctx, u.progress = c.trackProgress(ctx, u.size, u.offset)
+	defer u.progress.finish()
+
+	for attempt := 0; ; attempt++ {
+		err = u.run(ctx)
+		if err == nil {
+			os.Remove(u.statePath)
+
+			hashes := &transferHashes{md5: u.md5, sha256: u.sha256}
+			if err := c.verifyUpload(ctx, remotePath, hashes); err != nil {
+				return nil, err
+			}
+
+			return u, nil
+		}
+
+		if attempt >= opts.Retries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+type resumableUpload struct {
+	client *Client
+
+	statePath  string
+	remotePath string
+	overwrite  bool
+	chunkSize  int64
+
+	file    *os.File
+	size    int64
+	modTime time.Time
+
+	link   *Link
+	offset int64
+	md5    hash.Hash
+	sha256 hash.Hash
+
+	progress *progressTracker
+}
+
+func (u *resumableUpload) run(ctx context.Context) error {
+	if u.link != nil && !u.link.expiredAt(u.client.now()) {
+		received, done, err := u.query(ctx)
+		if err == nil {
+			if done {
+				// Checksums of the whole content are needed anyway
+				return u.seek(u.size)
+			}
+			if received > u.size {
+				return TransferError{StatusCode: statusResumeIncomplete}
+			}
+			if err := u.seek(received); err != nil {
+				return err
+			}
+		} else if err != errUploadSessionLost {
+			return err
+		} else {
+			u.link = nil
+		}
+	} else {
+		u.link = nil
+	}
+
+	if u.link == nil {
+		if err := u.start(ctx); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, u.chunkSize)
+
+	for {
+		n, err := u.file.ReadAt(buf, u.offset)
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		received, done, err := u.send(ctx, buf[:n])
+		if err == errUploadSessionLost {
+			// Next attempt will start over with the new link
+			u.link = nil
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if done {
+			u.md5.Write(buf[:n])
+			u.sha256.Write(buf[:n])
+			u.offset = u.size
+			u.progress.set(u.size)
+			return nil
+		}
+
+		switch {
+		case received > u.offset && received <= u.offset+int64(n):
+			u.md5.Write(buf[:received-u.offset])
+			u.sha256.Write(buf[:received-u.offset])
+			u.offset = received
+			u.progress.set(u.offset)
+		case received == u.offset || received > u.size:
+			// Upload host hasn't accepted the chunk
+			return TransferError{StatusCode: statusResumeIncomplete}
+		default:
+			if err := u.seek(received); err != nil {
+				return err
+			}
+		}
+
+		if err := u.save(); err != nil {
+			return err
+		}
+	}
+}
+
+// Request new upload link and start from the beginning.
+func (u *resumableUpload) start(ctx context.Context) error {
+	link, err := u.client.RequestUploadLink(ctx, u.remotePath, u.overwrite)
+	if err != nil {
+		return err
+	}
+
+	u.link = link
+	u.offset = 0
+	u.md5 = md5.New()
+	u.sha256 = sha256.New()
+	u.progress.set(0)
+
+	return u.save()
+}
+
+// Ask upload host how many bytes it has received.
+func (u *resumableUpload) query(ctx context.Context) (int64, bool, error) {
+	header := http.Header{}
+	header.Set("Content-Range", fmt.Sprintf("bytes */%d", u.size))
+
+	return u.put(ctx, http.NoBody, header)
+}
+
+// Send chunk starting at the current offset.
+func (u *resumableUpload) send(ctx context.Context, chunk []byte) (int64, bool, error) {
+	header := http.Header{}
+	if len(chunk) == 0 {
+		header.Set("Content-Range", fmt.Sprintf("bytes */%d", u.size))
+	} else {
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", u.offset, u.offset+int64(len(chunk))-1, u.size))
+	}
+
+	return u.put(ctx, bytes.NewReader(chunk), header)
+}
+
+// Method returns number of bytes received by the upload host and whether
+// upload has been completed.
+func (u *resumableUpload) put(ctx context.Context, body io.Reader, header http.Header) (int64, bool, error) {
+	resp, err := u.client.doRawRequestWithHeaders(ctx, u.link.Method, u.link.Href, body, header)
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	switch resp.StatusCode {
+	case http.StatusCreated, http.StatusAccepted, http.StatusOK:
+		u.client.invalidateCache(u.remotePath)
+		return u.size, true, nil
+	case statusResumeIncomplete:
+		return parseReceivedRange(resp.Header.Get("Range")), false, nil
+	case http.StatusNotFound, http.StatusGone:
+		return 0, false, errUploadSessionLost
+	default:
+		return 0, false, TransferError{StatusCode: resp.StatusCode}
+	}
+}
+
+// Move offset to the given position. Checksums are continued from the saved
+// state if possible, otherwise the uploaded part is read once again.
+func (u *resumableUpload) seek(offset int64) error {
+	u.progress.set(offset)
+
+	if offset == u.offset && u.md5 != nil {
+		return nil
+	}
+
+	u.md5 = md5.New()
+	u.sha256 = sha256.New()
+
+	_, err := io.Copy(io.MultiWriter(u.md5, u.sha256), io.NewSectionReader(u.file, 0, offset))
+	if err != nil {
+		return err
+	}
+
+	u.offset = offset
+
+	return u.save()
+}
+
+func (u *resumableUpload) save() error {
+	md5State, err := u.md5.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	sha256State, err := u.sha256.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(resumableState{
+		Version:     resumableStateVersion,
+		RemotePath:  u.remotePath,
+		Size:        u.size,
+		ModTime:     u.modTime,
+		Href:        u.link.Href,
+		Method:      u.link.Method,
+		IssuedAt:    u.link.IssuedAt,
+		Offset:      u.offset,
+		Md5State:    md5State,
+		Sha256State: sha256State,
+	})
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(u.statePath, data, 0600)
+}
+
+// Restore state saved by previous run. State is ignored if it's invalid or
+// belongs to another upload.
+func (u *resumableUpload) restore() {
+	data, err := ioutil.ReadFile(u.statePath)
+	if err != nil {
+		return
+	}
+
+	var state resumableState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return
+	}
+
+	if state.Version != resumableStateVersion ||
+		state.RemotePath != u.remotePath ||
+		state.Size != u.size ||
+		!state.ModTime.Equal(u.modTime) ||
+		state.Offset > u.size {
+		return
+	}
+
+	md5Hash, sha256Hash := md5.New(), sha256.New()
+	if md5Hash.(encoding.BinaryUnmarshaler).UnmarshalBinary(state.Md5State) != nil ||
+		sha256Hash.(encoding.BinaryUnmarshaler).UnmarshalBinary(state.Sha256State) != nil {
+		return
+	}
+
+	u.link = &Link{
+		Href:      state.Href,
+		Method:    state.Method,
+		IssuedAt:  state.IssuedAt,
+		path:      u.remotePath,
+		kind:      linkKindUpload,
+		overwrite: u.overwrite,
+	}
+	u.offset = state.Offset
+	u.md5 = md5Hash
+	u.sha256 = sha256Hash
+}
+
+func (u *resumableUpload) checksums() (string, string) {
+	return hex.EncodeToString(u.md5.Sum(nil)), hex.EncodeToString(u.sha256.Sum(nil))
+}
+
+// Parse "bytes=0-N" and return number of received bytes (N+1).
+func parseReceivedRange(header string) int64 {
+	if !strings.HasPrefix(header, "bytes=0-") {
+		return 0
+	}
+
+	last, err := strconv.ParseInt(strings.TrimPrefix(header, "bytes=0-"), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return last + 1
+}
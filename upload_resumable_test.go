@@ -0,0 +1,196 @@
+package yadisk
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/go-yandex-disk/internal/testhelpers"
+)
+
+// Fail n-th chunk (counting from 1) sent to the upload host.
+func failUploadChunk(disk *testhelpers.FakeDisk, n int) {
+	var chunks int
+	disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut || !strings.HasPrefix(r.URL.Path, "/upload/") {
+			return false
+		}
+		if strings.HasPrefix(r.Header.Get("Content-Range"), "bytes */") {
+			return false
+		}
+
+		chunks++
+		if chunks == n {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return true
+		}
+		return false
+	}
+}
+
+// Count requests made since the given index which start with the prefix.
+func countRequests(disk *testhelpers.FakeDisk, since int, prefix string) int {
+	var count int
+	for _, r := range disk.Requests[since:] {
+		if strings.HasPrefix(r, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestClient_UploadFileResumable(t *testing.T) {
+	const content = "0123456789ABCDEFGHIJ"
+
+	tests := []struct {
+		name string
+
+		// Executed between interrupted and resumed uploads
+		between func(t *testing.T, localPath string, disk *testhelpers.FakeDisk)
+
+		content      string
+		linkRequests int
+
+		// Queries of received bytes and sent chunks
+		puts int
+	}{
+		{
+			name: "resume",
+
+			content:      content,
+			linkRequests: 0,
+			puts:         4,
+		},
+
+		{
+			name: "local file changed",
+			between: func(t *testing.T, localPath string, disk *testhelpers.FakeDisk) {
+				assert.Nil(t, ioutil.WriteFile(localPath, []byte(strings.ToLower(content)), 0644))
+				modTime := time.Now().Add(time.Hour)
+				assert.Nil(t, os.Chtimes(localPath, modTime, modTime))
+			},
+
+			content:      strings.ToLower(content),
+			linkRequests: 1,
+			puts:         5,
+		},
+
+		{
+			name: "upload session expired",
+			between: func(t *testing.T, localPath string, disk *testhelpers.FakeDisk) {
+				disk.ExpireUploads()
+			},
+
+			content:      content,
+			linkRequests: 1,
+			puts:         6,
+		},
+
+		{
+			name: "state file corrupted",
+			between: func(t *testing.T, localPath string, disk *testhelpers.FakeDisk) {
+				assert.Nil(t, ioutil.WriteFile(localPath+resumableStateSuffix, []byte("{"), 0600))
+			},
+
+			content:      content,
+			linkRequests: 1,
+			puts:         5,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+
+			localPath := filepath.Join(dir, "file.bin")
+			assert.Nil(t, ioutil.WriteFile(localPath, []byte(content), 0644))
+
+			client, disk := newFakeDiskClient()
+			client.now = func() time.Time { return testNow }
+
+			opts := &ResumableUploadOptions{ChunkSize: 4}
+
+			failUploadChunk(disk, 3)
+			err := client.UploadFileResumable(context.Background(), localPath, "/file.bin", opts)
+			assert.Equal(t, TransferError{StatusCode: 503}, err)
+			assert.Nil(t, disk.File("/file.bin"))
+
+			_, err = os.Stat(localPath + resumableStateSuffix)
+			assert.Nil(t, err)
+
+			if test.between != nil {
+				test.between(t, localPath, disk)
+			}
+
+			disk.Intercept = nil
+			since := len(disk.Requests)
+
+			err = client.UploadFileResumable(context.Background(), localPath, "/file.bin", opts)
+			assert.Nil(t, err)
+			assert.Equal(t, test.content, string(disk.File("/file.bin").Content))
+
+			assert.Equal(t, test.linkRequests, countRequests(disk, since, "GET /v1/disk/resources/upload"))
+			assert.Equal(t, test.puts, countRequests(disk, since, "PUT /upload/"))
+
+			_, err = os.Stat(localPath + resumableStateSuffix)
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestClient_UploadFileResumable_Retries(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	localPath := filepath.Join(dir, "file.bin")
+	statePath := filepath.Join(dir, "state.json")
+	assert.Nil(t, ioutil.WriteFile(localPath, []byte("0123456789"), 0644))
+
+	client, disk := newFakeDiskClient()
+	client.now = func() time.Time { return testNow }
+
+	failUploadChunk(disk, 2)
+
+	err := client.UploadFileResumable(context.Background(), localPath, "/file.bin", &ResumableUploadOptions{
+		StatePath:  statePath,
+		ChunkSize:  4,
+		Retries:    1,
+		RetryDelay: time.Millisecond,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "0123456789", string(disk.File("/file.bin").Content))
+
+	_, err = os.Stat(statePath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestClient_uploadFileResumable_Checksums(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	localPath := filepath.Join(dir, "file.bin")
+	assert.Nil(t, ioutil.WriteFile(localPath, []byte("0123456789"), 0644))
+
+	client, disk := newFakeDiskClient()
+	client.now = func() time.Time { return testNow }
+
+	failUploadChunk(disk, 2)
+	_, err := client.uploadFileResumable(context.Background(), localPath, "/file.bin", &ResumableUploadOptions{ChunkSize: 3})
+	assert.NotNil(t, err)
+
+	disk.Intercept = nil
+
+	upload, err := client.uploadFileResumable(context.Background(), localPath, "/file.bin", &ResumableUploadOptions{ChunkSize: 3})
+	assert.Nil(t, err)
+
+	md5sum, sha256sum := upload.checksums()
+	assert.Equal(t, "781e5e245d69b566979b86e28d23f2c7", md5sum)
+	assert.Equal(t, "84d89877f0d4041efb6bf91a16f0248f2fd573e6af05c19f96bedb9f882f7882", sha256sum)
+}
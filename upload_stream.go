@@ -0,0 +1,332 @@
+package yadisk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const defaultMemorySpoolLimit = 64 << 20
+
+// Where UploadStream keeps the uploaded content to replay it on retry.
+type SpoolMode int
+
+const (
+	// Content is not kept, upload could not be retried.
+	SpoolNone SpoolMode = iota
+
+	// Content is kept in memory.
+	SpoolMemory
+
+	// Content is kept in a temporary file.
+	SpoolFile
+)
+
+// ErrSpoolLimitExceeded is returned when upload has failed and it could not
+// be retried because its content has exceeded the spool limit.
+var ErrSpoolLimitExceeded = errors.New("yadisk: upload could not be retried: spool limit exceeded")
+
+// Options of UploadStream.
+type StreamUploadOptions struct {
+	// What to do if remote file already exists. OverwriteNever by default.
+	Overwrite OverwritePolicy
+
+	// Where the content is kept to be replayed. SpoolNone by default.
+	Spool SpoolMode
+
+	// Maximum size of kept content. If the content is larger, upload could
+	// not be retried once this amount of content has been read. 64 MiB for
+	// SpoolMemory by default, unlimited for SpoolFile.
+	SpoolLimit int64
+
+	// Directory of the temporary file of SpoolFile. Default directory for
+	// temporary files is used if it's empty.
+	SpoolDir string
+
+	// How many times upload is retried if it has failed (or its link has
+	// expired). Upload could be retried only if content is spooled.
+	Retries int
+
+	// Delay between retries. 1 second by default.
+	RetryDelay time.Duration
+}
+
+// Upload content of unknown length (e.g. a pipe or stdin) to the given remote
+// path. Content is sent with chunked transfer encoding as it's read.
+//
+// r - io.Reader of file contents.
+// remotePath - The path where you want to upload the file.
+// opts - Upload options, nil means default options.
+//
+// Read content could be kept in the spool (see StreamUploadOptions), so the
+// upload is retried by replaying the kept content followed by the rest of r.
+// Spool is released when method returns.
+//
+// Method returns TransferError if upload has failed. If upload could not be
+// retried because of spool limit, it returns ErrSpoolLimitExceeded.
+func (c *Client) UploadStream(ctx context.Context, r io.Reader, remotePath string, opts *StreamUploadOptions) error {
+	if opts == nil {
+		opts = &StreamUploadOptions{}
+	}
+
+	retryDelay := opts.RetryDelay
+	if retryDelay <= 0 {
+		retryDelay = time.Second
+	}
+
+	s, err := newSpool(opts)
+	if err != nil {
+		return err
+	}
+	if s != nil {
+		defer s.release()
+	}
+
+	link, err := c.RequestUploadLink(ctx, remotePath, opts.Overwrite == OverwriteAlways)
+	if err != nil {
+		if opts.Overwrite == OverwriteSkip && isConflict(err) {
+			return nil
+		}
+		return err
+	}
+
+	// Hide r's type, so the content is never sent with Content-Length
+	body := io.Reader(&spoolingReader{r: r, spool: s})
+
+	for attempt := 0; ; attempt++ {
+		// Transport could read the body even after the attempt has finished,
+		// it must not send the content which is going to be sent with the
+		// next attempt
+		guarded := &attemptBody{r: body}
+		err = c.uploadStreamAttempt(ctx, link, guarded)
+		guarded.stop()
+		if err == nil {
+			return nil
+		}
+
+		if attempt >= opts.Retries || s == nil || ctx.Err() != nil {
+			return err
+		}
+
+		// Content of the read in progress is kept by the spool, so it's
+		// replayed as well
+		guarded.wait()
+
+		if s.overflowed {
+			return ErrSpoolLimitExceeded
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryDelay):
+		}
+
+		replay, err := s.replay()
+		if err != nil {
+			return err
+		}
+
+		body = io.MultiReader(replay, &spoolingReader{r: r, spool: s})
+	}
+}
+
+func (c *Client) uploadStreamAttempt(ctx context.Context, link *Link, body io.Reader) error {
+	if err := c.renewExpiredLink(ctx, link); err != nil {
+		return err
+	}
+
+	statusCode, err := c.Upload(ctx, link, body)
+	if err != nil {
+		return err
+	}
+
+	switch statusCode {
+	case http.StatusCreated, http.StatusAccepted:
+		return nil
+	case http.StatusNotFound, http.StatusGone:
+		// Upload host doesn't accept the link anymore
+		if err := c.RenewLink(ctx, link); err != nil {
+			return err
+		}
+	}
+
+	return TransferError{StatusCode: statusCode}
+}
+
+// Buffer of the read content.
+type spool struct {
+	// Read which has been started by a finished attempt could write to the
+	// spool after it's released
+	mu sync.Mutex
+
+	limit      int64
+	size       int64
+	overflowed bool
+	released   bool
+
+	// Either buf or file is used
+	buf  *bytes.Buffer
+	file *os.File
+}
+
+// Spool according to options, nil if spooling is disabled.
+func newSpool(opts *StreamUploadOptions) (*spool, error) {
+	switch opts.Spool {
+	case SpoolMemory:
+		limit := opts.SpoolLimit
+		if limit <= 0 {
+			limit = defaultMemorySpoolLimit
+		}
+
+		return &spool{limit: limit, buf: &bytes.Buffer{}}, nil
+	case SpoolFile:
+		f, err := ioutil.TempFile(opts.SpoolDir, "yadisk-spool")
+		if err != nil {
+			return nil, err
+		}
+
+		return &spool{limit: opts.SpoolLimit, file: f}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// Keep p unless spool is overflowed. Kept content is released on overflow.
+func (s *spool) write(p []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.overflowed || s.released {
+		return nil
+	}
+
+	if s.limit > 0 && s.size+int64(len(p)) > s.limit {
+		s.overflowed = true
+		s.releaseLocked()
+		return nil
+	}
+
+	var err error
+	if s.buf != nil {
+		_, err = s.buf.Write(p)
+	} else {
+		_, err = s.file.Write(p)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.size += int64(len(p))
+
+	return nil
+}
+
+// Reader of all kept content.
+func (s *spool) replay() (io.Reader, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.buf != nil {
+		return bytes.NewReader(s.buf.Bytes()[:s.size]), nil
+	}
+
+	return io.NewSectionReader(s.file, 0, s.size), nil
+}
+
+func (s *spool) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.releaseLocked()
+}
+
+func (s *spool) releaseLocked() {
+	s.released = true
+
+	if s.buf != nil {
+		s.buf = &bytes.Buffer{}
+	}
+
+	if s.file != nil {
+		s.file.Close()
+		os.Remove(s.file.Name())
+		s.file = nil
+	}
+}
+
+// Reader which keeps read content in the spool.
+type spoolingReader struct {
+	r     io.Reader
+	spool *spool
+}
+
+func (sr *spoolingReader) Read(p []byte) (int, error) {
+	n, err := sr.r.Read(p)
+
+	if n > 0 && sr.spool != nil {
+		if spoolErr := sr.spool.write(p[:n]); spoolErr != nil {
+			return n, spoolErr
+		}
+	}
+
+	return n, err
+}
+
+var errAttemptFinished = errors.New("yadisk: upload attempt has finished")
+
+// Body of a single upload attempt which could not be read once the attempt
+// has finished.
+type attemptBody struct {
+	mu      sync.Mutex
+	r       io.Reader
+	stopped bool
+
+	// Reads in progress, the lock isn't held while reading, so stop doesn't
+	// wait for the source which has no data yet
+	reading sync.WaitGroup
+}
+
+func (b *attemptBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	if b.stopped {
+		b.mu.Unlock()
+		return 0, errAttemptFinished
+	}
+	b.reading.Add(1)
+	b.mu.Unlock()
+
+	defer b.reading.Done()
+
+	n, err := b.r.Read(p)
+
+	b.mu.Lock()
+	stopped := b.stopped
+	b.mu.Unlock()
+
+	// Content read after the attempt has finished is not sent
+	if stopped {
+		return 0, errAttemptFinished
+	}
+
+	return n, err
+}
+
+// Stop the attempt, it doesn't wait for reads in progress.
+func (b *attemptBody) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stopped = true
+}
+
+// Wait for reads which have been started before stop.
+func (b *attemptBody) wait() {
+	b.reading.Wait()
+}
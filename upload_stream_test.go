@@ -0,0 +1,196 @@
+package yadisk
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/go-yandex-disk/internal/testhelpers"
+)
+
+// Fail first n uploads after reading part of their bodies.
+func failUploads(disk *testhelpers.FakeDisk, n int, statusCode int) {
+	var failed int
+	disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut || !strings.HasPrefix(r.URL.Path, "/upload/") || failed >= n {
+			return false
+		}
+
+		failed++
+		r.Body.Read(make([]byte, 4))
+		w.WriteHeader(statusCode)
+		return true
+	}
+}
+
+func TestClient_UploadStream(t *testing.T) {
+	content := strings.Repeat("0123456789", 10)
+
+	tests := []struct {
+		name string
+
+		opts       StreamUploadOptions
+		failures   int
+		statusCode int
+		expire     bool
+
+		uploads int
+		error   error
+	}{
+		{
+			name: "without failures",
+
+			uploads: 1,
+		},
+
+		{
+			name:       "without spool",
+			opts:       StreamUploadOptions{Retries: 3},
+			failures:   1,
+			statusCode: http.StatusServiceUnavailable,
+
+			uploads: 1,
+			error:   TransferError{StatusCode: 503},
+		},
+
+		{
+			name:       "memory spool",
+			opts:       StreamUploadOptions{Spool: SpoolMemory, Retries: 3},
+			failures:   2,
+			statusCode: http.StatusServiceUnavailable,
+
+			uploads: 3,
+		},
+
+		{
+			name:       "file spool",
+			opts:       StreamUploadOptions{Spool: SpoolFile, Retries: 3},
+			failures:   2,
+			statusCode: http.StatusServiceUnavailable,
+
+			uploads: 3,
+		},
+
+		{
+			name:       "too many failures",
+			opts:       StreamUploadOptions{Spool: SpoolMemory, Retries: 1},
+			failures:   2,
+			statusCode: http.StatusServiceUnavailable,
+
+			uploads: 2,
+			error:   TransferError{StatusCode: 503},
+		},
+
+		{
+			name:       "spool limit exceeded",
+			opts:       StreamUploadOptions{Spool: SpoolMemory, SpoolLimit: 2, Retries: 3},
+			failures:   1,
+			statusCode: http.StatusServiceUnavailable,
+
+			uploads: 1,
+			error:   ErrSpoolLimitExceeded,
+		},
+
+		{
+			name:   "expired link",
+			opts:   StreamUploadOptions{Spool: SpoolMemory, Retries: 1},
+			expire: true,
+
+			uploads: 2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+
+			client, disk := newFakeDiskClient()
+			client.now = func() time.Time { return testNow }
+
+			if test.failures > 0 {
+				failUploads(disk, test.failures, test.statusCode)
+			}
+
+			opts := test.opts
+			opts.SpoolDir = dir
+			opts.RetryDelay = time.Millisecond
+
+			// Link expires before the first upload
+			if test.expire {
+				expired := false
+				disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+					if strings.HasPrefix(r.URL.Path, "/upload/") && !expired {
+						expired = true
+						disk.ExpireUploads()
+					}
+					return false
+				}
+			}
+
+			pr, pw := io.Pipe()
+			go func() {
+				for i := 0; i < len(content); i += 10 {
+					pw.Write([]byte(content[i : i+10]))
+				}
+				pw.Close()
+			}()
+
+			since := len(disk.Requests)
+
+			err := client.UploadStream(context.Background(), pr, "/file.bin", &opts)
+			assert.Equal(t, test.error, err)
+			assert.Equal(t, test.uploads, countRequests(disk, since, "PUT /upload/"))
+
+			if test.error == nil {
+				assert.Equal(t, content, string(disk.File("/file.bin").Content))
+			}
+
+			// Spool is removed
+			files, _ := ioutil.ReadDir(dir)
+			assert.Len(t, files, 0)
+		})
+	}
+}
+
+func TestClient_UploadStream_stalledSource(t *testing.T) {
+	client, disk := newFakeDiskClient()
+
+	// Upload is rejected while transport is still sending its body
+	disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut || !strings.HasPrefix(r.URL.Path, "/upload/") {
+			return false
+		}
+
+		reading := make(chan struct{})
+		go func() {
+			close(reading)
+			io.Copy(ioutil.Discard, r.Body)
+		}()
+		<-reading
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	}
+
+	// Source has no data yet and it's not closed
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.UploadStream(context.Background(), pr, "/file.bin", nil)
+	}()
+
+	select {
+	case err := <-done:
+		assert.Equal(t, TransferError{StatusCode: http.StatusInternalServerError}, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("upload hasn't returned while source is stalled")
+	}
+}
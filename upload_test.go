@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -40,6 +41,10 @@ func TestClient_RequestUploadLink(t *testing.T) {
 				Href:      "some_href",
 				Method:    "PUT",
 				Templated: false,
+				IssuedAt:  testNow,
+				path:      "/some_path/some_file.ext",
+				kind:      linkKindUpload,
+				overwrite: false,
 			},
 
 			error: nil,
@@ -72,6 +77,10 @@ func TestClient_RequestUploadLink(t *testing.T) {
 				Href:      "some_href",
 				Method:    "PUT",
 				Templated: false,
+				IssuedAt:  testNow,
+				path:      "/some_path/some_file_to_overwrite.ext",
+				kind:      linkKindUpload,
+				overwrite: true,
 			},
 
 			error: nil,
@@ -93,6 +102,7 @@ func TestClient_RequestUploadLink(t *testing.T) {
 					Body:       ioutil.NopCloser(bytes.NewBufferString(test.responseBody)),
 				}
 			}))
+			client.now = func() time.Time { return testNow }
 
 			link, err := client.RequestUploadLink(context.Background(), test.path, test.overwrite)
 
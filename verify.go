@@ -0,0 +1,241 @@
+package yadisk
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+const defaultQuarantineDir = "/quarantine"
+
+// ErrChecksumMismatch is matched (with errors.Is) by ChecksumMismatchError.
+var ErrChecksumMismatch = errors.New("yadisk: checksum mismatch")
+
+// Error returned when checksum of the transferred content doesn't match
+// checksum reported by Yandex.Disk.
+type ChecksumMismatchError struct {
+	// "md5" or "sha256"
+	Algorithm string
+
+	Expected string
+	Actual   string
+}
+
+func (err ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("yadisk: %s checksum mismatch: expected %s, got %s", err.Algorithm, err.Expected, err.Actual)
+}
+
+func (err ChecksumMismatchError) Is(target error) bool {
+	return target == ErrChecksumMismatch
+}
+
+// What to do with uploaded file if its checksum doesn't match.
+type MismatchAction int
+
+const (
+	// Leave uploaded file as is.
+	MismatchKeep MismatchAction = iota
+
+	// Move uploaded file to the Trash.
+	MismatchDelete
+
+	// Move uploaded file to the quarantine directory.
+	MismatchQuarantine
+)
+
+// Options of transfer verification.
+type VerifyOptions struct {
+	// What to do with uploaded file if its checksum doesn't match.
+	// MismatchKeep by default.
+	OnUploadMismatch MismatchAction
+
+	// Directory where mismatched uploads are moved to. Uploaded file keeps its
+	// name with time of upload appended. "/quarantine" by default.
+	QuarantineDir string
+}
+
+type verifyOptionsKey struct{}
+
+type uploadHashesKey struct{}
+
+// Verify transfers made with the returned context: MD5 and SHA256 of the
+// transferred content are computed while streaming and compared with
+// Resource.Md5 and Resource.Sha256 once the transfer has finished.
+//
+// It's supported by Upload, Download and all of upload and download helpers,
+// mismatch is reported as ChecksumMismatchError. Upload returns the error
+// after uploaded file is handled according to OnUploadMismatch, Download
+// returns it from response body's Read instead of io.EOF. Checksums missing
+// in metainformation (e.g. while Yandex.Disk is processing uploaded file) are
+// not verified.
+//
+// Note that every transfer makes additional metainformation request.
+func WithVerification(ctx context.Context, opts *VerifyOptions) context.Context {
+	if opts == nil {
+		opts = &VerifyOptions{}
+	}
+
+	return context.WithValue(ctx, verifyOptionsKey{}, opts)
+}
+
+func verifyOptions(ctx context.Context) (*VerifyOptions, bool) {
+	opts, ok := ctx.Value(verifyOptionsKey{}).(*VerifyOptions)
+	return opts, ok
+}
+
+// MD5 and SHA256 computed simultaneously.
+type transferHashes struct {
+	md5    hash.Hash
+	sha256 hash.Hash
+}
+
+func newTransferHashes() *transferHashes {
+	return &transferHashes{md5: md5.New(), sha256: sha256.New()}
+}
+
+func (h *transferHashes) Write(p []byte) (int, error) {
+	h.md5.Write(p)
+	h.sha256.Write(p)
+	return len(p), nil
+}
+
+// Start hashing the upload body, doRawRequest feeds body of the request made
+// with the returned context to the returned hashes. Hashes are nil if
+// verification is not requested or remote path is unknown.
+func startUploadVerification(ctx context.Context, link *Link) (context.Context, *transferHashes) {
+	if _, ok := verifyOptions(ctx); !ok || link.path == "" {
+		return ctx, nil
+	}
+
+	hashes := newTransferHashes()
+
+	return context.WithValue(ctx, uploadHashesKey{}, hashes), hashes
+}
+
+// Feed the request body to the upload hashes if they're requested.
+func hashRequest(ctx context.Context, req *http.Request) {
+	hashes, ok := ctx.Value(uploadHashesKey{}).(*transferHashes)
+	if !ok || req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+
+	req.Body = &hashingBody{ReadCloser: req.Body, hashes: hashes}
+}
+
+// Body which feeds read bytes to the hashes.
+type hashingBody struct {
+	io.ReadCloser
+	hashes *transferHashes
+
+	// Called instead of returning io.EOF
+	onEOF func() error
+}
+
+func (b *hashingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.hashes.Write(p[:n])
+
+	if err == io.EOF && b.onEOF != nil {
+		onEOF := b.onEOF
+		b.onEOF = nil
+
+		if verifyErr := onEOF(); verifyErr != nil {
+			return n, verifyErr
+		}
+	}
+
+	return n, err
+}
+
+// Compare hashes of uploaded content with the remote ones and handle
+// mismatched file according to options.
+func (c *Client) verifyUpload(ctx context.Context, remotePath string, hashes *transferHashes) error {
+	opts, ok := verifyOptions(ctx)
+	if !ok {
+		return nil
+	}
+
+	resource, err := c.GetResource(ctx, remotePath, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	mismatch := compareChecksums(resource, hashes.md5, hashes.sha256)
+	if mismatch == nil {
+		return nil
+	}
+
+	switch opts.OnUploadMismatch {
+	case MismatchDelete:
+		if _, _, err := c.Delete(ctx, remotePath, false); err != nil {
+			return err
+		}
+	case MismatchQuarantine:
+		if err := c.quarantine(ctx, remotePath, opts.QuarantineDir); err != nil {
+			return err
+		}
+	}
+
+	return mismatch
+}
+
+// Compare hashes of downloaded content with the remote ones.
+func (c *Client) verifyDownload(ctx context.Context, remotePath string, hashes *transferHashes) error {
+	resource, err := c.GetResource(ctx, remotePath, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	return compareChecksums(resource, hashes.md5, hashes.sha256)
+}
+
+func (c *Client) quarantine(ctx context.Context, remotePath, dir string) error {
+	if dir == "" {
+		dir = defaultQuarantineDir
+	}
+
+	if err := c.MkdirAll(ctx, dir); err != nil {
+		return err
+	}
+
+	dst := path.Join(dir, path.Base(remotePath)+"."+c.now().UTC().Format("20060102T150405Z"))
+
+	_, _, err := c.Move(ctx, remotePath, dst, false)
+
+	return err
+}
+
+// Compare content checksums with ones reported by Yandex.Disk. Missing
+// checksums are not verified.
+func verifyChecksums(r io.Reader, resource *Resource) error {
+	if resource.Md5 == "" && resource.Sha256 == "" {
+		return nil
+	}
+
+	hashes := newTransferHashes()
+	if _, err := io.Copy(hashes, r); err != nil {
+		return err
+	}
+
+	return compareChecksums(resource, hashes.md5, hashes.sha256)
+}
+
+func compareChecksums(resource *Resource, md5Hash, sha256Hash hash.Hash) error {
+	if actual := hex.EncodeToString(md5Hash.Sum(nil)); resource.Md5 != "" && !strings.EqualFold(actual, resource.Md5) {
+		return ChecksumMismatchError{Algorithm: "md5", Expected: resource.Md5, Actual: actual}
+	}
+
+	if actual := hex.EncodeToString(sha256Hash.Sum(nil)); resource.Sha256 != "" && !strings.EqualFold(actual, resource.Sha256) {
+		return ChecksumMismatchError{Algorithm: "sha256", Expected: resource.Sha256, Actual: actual}
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package yadisk
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/go-yandex-disk/internal/testhelpers"
+)
+
+func md5Hex(content string) string {
+	sum := md5.Sum([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Store corrupted content instead of the uploaded one.
+func corruptUploads(disk *testhelpers.FakeDisk, remotePath, corrupted string) {
+	disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut || !strings.HasPrefix(r.URL.Path, "/upload/") {
+			return false
+		}
+
+		ioutil.ReadAll(r.Body)
+		disk.PutFile(remotePath, []byte(corrupted))
+		w.WriteHeader(http.StatusCreated)
+		return true
+	}
+}
+
+// Serve corrupted content instead of the stored one.
+func corruptDownloads(disk *testhelpers.FakeDisk, corrupted string) {
+	disk.Intercept = func(w http.ResponseWriter, r *http.Request) bool {
+		if !strings.HasPrefix(r.URL.Path, "/download/") {
+			return false
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(corrupted))
+		return true
+	}
+}
+
+func TestWithVerification_Upload(t *testing.T) {
+	mismatch := ChecksumMismatchError{Algorithm: "md5", Expected: md5Hex("CORRUPTED"), Actual: md5Hex("CONTENT")}
+
+	tests := []struct {
+		name string
+
+		corrupted bool
+		opts      *VerifyOptions
+
+		files []string
+		error error
+	}{
+		{
+			name: "matching upload",
+
+			files: []string{"/dir/file.txt"},
+		},
+
+		{
+			name:      "keep mismatched upload",
+			corrupted: true,
+
+			files: []string{"/dir/file.txt"},
+			error: mismatch,
+		},
+
+		{
+			name:      "delete mismatched upload",
+			corrupted: true,
+			opts:      &VerifyOptions{OnUploadMismatch: MismatchDelete},
+
+			files: nil,
+			error: mismatch,
+		},
+
+		{
+			name:      "quarantine mismatched upload",
+			corrupted: true,
+			opts:      &VerifyOptions{OnUploadMismatch: MismatchQuarantine},
+
+			files: []string{"/quarantine/file.txt.20190401T120000Z"},
+			error: mismatch,
+		},
+
+		{
+			name:      "quarantine mismatched upload to custom directory",
+			corrupted: true,
+			opts:      &VerifyOptions{OnUploadMismatch: MismatchQuarantine, QuarantineDir: "/dir"},
+
+			files: []string{"/dir/file.txt.20190401T120000Z"},
+			error: mismatch,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, disk := newFakeDiskClient()
+			client.now = func() time.Time { return testNow }
+			disk.PutDir("/dir")
+
+			if test.corrupted {
+				corruptUploads(disk, "/dir/file.txt", "CORRUPTED")
+			}
+
+			ctx := WithVerification(context.Background(), test.opts)
+
+			err := client.UploadReader(ctx, strings.NewReader("CONTENT"), "/dir/file.txt", nil)
+			assert.Equal(t, test.error, err)
+			assert.Equal(t, test.error != nil, errors.Is(err, ErrChecksumMismatch))
+			assert.Equal(t, test.files, disk.Files())
+		})
+	}
+}
+
+func TestWithVerification_Download(t *testing.T) {
+	mismatch := ChecksumMismatchError{Algorithm: "md5", Expected: md5Hex("CONTENT"), Actual: md5Hex("CORRUPTED")}
+
+	tests := []struct {
+		name     string
+		download func(ctx context.Context, client *Client, localPath string) error
+	}{
+		{
+			name: "DownloadTo",
+			download: func(ctx context.Context, client *Client, localPath string) error {
+				_, err := client.DownloadTo(ctx, "/file.txt", ioutil.Discard)
+				return err
+			},
+		},
+
+		{
+			name: "DownloadFile",
+			download: func(ctx context.Context, client *Client, localPath string) error {
+				return client.DownloadFile(ctx, "/file.txt", localPath, nil)
+			},
+		},
+
+		{
+			name: "DownloadFileResumable",
+			download: func(ctx context.Context, client *Client, localPath string) error {
+				return client.DownloadFileResumable(ctx, "/file.txt", localPath, nil)
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+
+			localPath := filepath.Join(dir, "file.txt")
+
+			client, disk := newFakeDiskClient()
+			disk.PutFile("/file.txt", []byte("CONTENT"))
+
+			ctx := WithVerification(context.Background(), nil)
+
+			err := test.download(ctx, client, localPath)
+			assert.Nil(t, err)
+
+			os.Remove(localPath)
+			corruptDownloads(disk, "CORRUPTED")
+
+			err = test.download(ctx, client, localPath)
+			assert.Equal(t, mismatch, err)
+
+			files, _ := ioutil.ReadDir(dir)
+			assert.Len(t, files, 0)
+		})
+	}
+}